@@ -0,0 +1,147 @@
+package mp4
+
+// MPEG-4 audio object types (ISO/IEC 14496-3, 1.5.1.1)
+const (
+	AacMain = 1
+	AacLC   = 2
+	AacSSR  = 3
+	AacLTP  = 4
+	AacSBR  = 5
+	AacPS   = 29
+)
+
+// Sampling frequencies defined by samplingFrequencyIndex
+var aacSamplingFrequencies = []uint32{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// MPEG-4 AudioSpecificConfig (ISO/IEC 14496-3, 1.6.2.1)
+//
+// ObjectType is the audio object type of the core codec (AacLC for HE-AAC). SBR and PS are set
+// for HE-AAC (v1 and v2), with both explicit (object type 5 or 29) and backward compatible
+// (sync extension) signaling. ExtensionSamplingFrequency is then the output sampling frequency.
+type AudioSpecificConfig struct {
+	ObjectType                 byte
+	SamplingFrequency          uint32
+	ChannelConfiguration       byte
+	SBR                        bool   `json:",omitempty"`
+	PS                         bool   `json:",omitempty"`
+	ExtensionSamplingFrequency uint32 `json:",omitempty"`
+}
+
+// ParseAudioSpecificConfig parses the AudioSpecificConfig stored in the decoder specific info of MPEG-4
+// audio tracks
+func ParseAudioSpecificConfig(data []byte) (*AudioSpecificConfig, error) {
+	r := newBitReader(data)
+	c := &AudioSpecificConfig{}
+	c.ObjectType = readAudioObjectType(r)
+	c.SamplingFrequency = readSamplingFrequency(r)
+	c.ChannelConfiguration = byte(r.readBits(4))
+	explicit := false
+	if c.ObjectType == AacSBR || c.ObjectType == AacPS {
+		explicit = true
+		c.SBR = true
+		c.PS = c.ObjectType == AacPS
+		c.ExtensionSamplingFrequency = readSamplingFrequency(r)
+		c.ObjectType = readAudioObjectType(r)
+		if c.ObjectType == 22 {
+			r.skip(4) // extensionChannelConfiguration
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if c.SamplingFrequency == 0 {
+		return nil, ErrBadFormat
+	}
+	if explicit || !c.skipGASpecificConfig(r) {
+		return c, nil
+	}
+	// Backward compatible signaling of SBR and PS
+	if len(data)*8-r.pos >= 16 && r.readBits(11) == 0x2b7 {
+		if readAudioObjectType(r) == AacSBR && r.readFlag() {
+			c.SBR = true
+			c.ExtensionSamplingFrequency = readSamplingFrequency(r)
+			if len(data)*8-r.pos >= 12 && r.readBits(11) == 0x548 {
+				c.PS = r.readFlag()
+			}
+		}
+		if r.err != nil {
+			c.SBR, c.PS, c.ExtensionSamplingFrequency = false, false, 0
+		}
+	}
+	return c, nil
+}
+
+func readAudioObjectType(r *bitReader) byte {
+	t := r.readBits(5)
+	if t == 31 {
+		t = 32 + r.readBits(6)
+	}
+	return byte(t)
+}
+
+func readSamplingFrequency(r *bitReader) uint32 {
+	i := r.readBits(4)
+	if i == 0xf {
+		return r.readBits(24)
+	}
+	if int(i) < len(aacSamplingFrequencies) {
+		return aacSamplingFrequencies[i]
+	}
+	return 0
+}
+
+// skipGASpecificConfig skips the GASpecificConfig of the AAC object types, and returns false if it
+// cannot be skipped (other object types, program config element, error resilience)
+func (c *AudioSpecificConfig) skipGASpecificConfig(r *bitReader) bool {
+	switch c.ObjectType {
+	case 1, 2, 3, 4, 6, 7:
+	default:
+		return false
+	}
+	if c.ChannelConfiguration == 0 {
+		return false
+	}
+	r.skip(1) // frameLengthFlag
+	if r.readFlag() {
+		r.skip(14) // coreCoderDelay
+	}
+	extension := r.readFlag()
+	if c.ObjectType == 6 {
+		r.skip(3) // layerNr
+	}
+	return !extension && r.err == nil
+}
+
+// Channels returns the number of channels defined by the channel configuration, 0 if it is defined by
+// a program config element
+func (c *AudioSpecificConfig) Channels() int {
+	switch {
+	case c.ChannelConfiguration == 7:
+		return 8
+	case c.ChannelConfiguration < 7:
+		return int(c.ChannelConfiguration)
+	}
+	return 0
+}
+
+// OutputSamplingFrequency returns the sampling frequency of the decoded audio (the SBR one for HE-AAC)
+func (c *AudioSpecificConfig) OutputSamplingFrequency() uint32 {
+	if c.SBR && c.ExtensionSamplingFrequency != 0 {
+		return c.ExtensionSamplingFrequency
+	}
+	return c.SamplingFrequency
+}
+
+// CodecObjectType returns the audio object type used in codec strings : AacPS for HE-AAC v2, AacSBR
+// for HE-AAC, ObjectType otherwise
+func (c *AudioSpecificConfig) CodecObjectType() byte {
+	switch {
+	case c.PS:
+		return AacPS
+	case c.SBR:
+		return AacSBR
+	}
+	return c.ObjectType
+}
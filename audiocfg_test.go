@@ -0,0 +1,136 @@
+package mp4
+
+import (
+	"reflect"
+	"testing"
+)
+
+// heAAC returns the AudioSpecificConfig of an AAC LC stream with the backward compatible signaling of SBR
+// (and of PS if ps is set)
+func heAAC(frequency, channels, extensionFrequency uint64, ps bool) []byte {
+	w := &bitWriter{}
+	w.bits(AacLC, 5).bits(frequency, 4).bits(channels, 4).bits(0, 3)
+	w.bits(0x2b7, 11).bits(AacSBR, 5).flag(true).bits(extensionFrequency, 4)
+	if ps {
+		w.bits(0x548, 11).flag(true)
+	}
+	return w.data
+}
+
+// explicitAAC returns the AudioSpecificConfig of a HE-AAC stream with the explicit signaling of SBR or PS
+func explicitAAC(objectType, frequency, channels, extensionFrequency uint64) []byte {
+	w := &bitWriter{}
+	w.bits(objectType, 5).bits(frequency, 4).bits(channels, 4).bits(extensionFrequency, 4)
+	w.bits(AacLC, 5).bits(0, 3)
+	return w.data
+}
+
+func TestParseAudioSpecificConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		want     AudioSpecificConfig
+		codec    byte
+		channels int
+		rate     uint32
+	}{
+		{
+			// sync extension without SBR (sbrPresentFlag 0)
+			"hls sample", []byte{0x12, 0x10, 0x56, 0xe5, 0x00},
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 44100, ChannelConfiguration: 2},
+			AacLC, 2, 44100,
+		},
+		{
+			"dash sample", []byte{0x13, 0x90},
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 22050, ChannelConfiguration: 2},
+			AacLC, 2, 22050,
+		},
+		{
+			"HE-AAC sync extension", heAAC(6, 2, 3, false),
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 24000, ChannelConfiguration: 2, SBR: true,
+				ExtensionSamplingFrequency: 48000},
+			AacSBR, 2, 48000,
+		},
+		{
+			"HE-AAC v2 sync extension", heAAC(6, 1, 3, true),
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 24000, ChannelConfiguration: 1, SBR: true,
+				PS: true, ExtensionSamplingFrequency: 48000},
+			AacPS, 1, 48000,
+		},
+		{
+			"HE-AAC explicit", explicitAAC(AacSBR, 7, 2, 4),
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 22050, ChannelConfiguration: 2, SBR: true,
+				ExtensionSamplingFrequency: 44100},
+			AacSBR, 2, 44100,
+		},
+		{
+			"HE-AAC v2 explicit", explicitAAC(AacPS, 6, 1, 3),
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 24000, ChannelConfiguration: 1, SBR: true,
+				PS: true, ExtensionSamplingFrequency: 48000},
+			AacPS, 1, 48000,
+		},
+		{
+			"escaped frequency, 7.1", (&bitWriter{}).bits(AacLC, 5).bits(0xf, 4).bits(7350, 24).bits(7, 4).bits(0, 3).data,
+			AudioSpecificConfig{ObjectType: AacLC, SamplingFrequency: 7350, ChannelConfiguration: 7},
+			AacLC, 8, 7350,
+		},
+		{
+			"escaped object type", (&bitWriter{}).bits(31, 5).bits(10, 6).bits(3, 4).bits(2, 4).data,
+			AudioSpecificConfig{ObjectType: 42, SamplingFrequency: 48000, ChannelConfiguration: 2},
+			42, 2, 48000,
+		},
+	}
+	for _, tt := range tests {
+		c, err := ParseAudioSpecificConfig(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*c, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *c, tt.want)
+		}
+		if o := c.CodecObjectType(); o != tt.codec {
+			t.Errorf("%s: codec object type %d, want %d", tt.name, o, tt.codec)
+		}
+		if n := c.Channels(); n != tt.channels {
+			t.Errorf("%s: %d channels, want %d", tt.name, n, tt.channels)
+		}
+		if r := c.OutputSamplingFrequency(); r != tt.rate {
+			t.Errorf("%s: rate %d, want %d", tt.name, r, tt.rate)
+		}
+	}
+	if _, err := ParseAudioSpecificConfig([]byte{0x12}); err != ErrTruncatedBody {
+		t.Errorf("truncated: %v", err)
+	}
+	if _, err := ParseAudioSpecificConfig([]byte{0x16, 0x90}); err != ErrBadFormat {
+		t.Errorf("invalid frequency: %v", err)
+	}
+}
+
+func TestEsdsCodec(t *testing.T) {
+	esds := func(oti byte, dsi []byte) *EsdsBox {
+		dc := &DecoderConfigDescriptor{ObjectTypeIndication: oti, StreamType: 5, MaxBitrate: 128000}
+		if dsi != nil {
+			dc.DecoderSpecificInfo = &DecoderSpecificInfo{Data: dsi}
+		}
+		return &EsdsBox{ES: &ESDescriptor{ESID: 1, DecoderConfig: dc}}
+	}
+	tests := []struct {
+		box  *EsdsBox
+		want string
+	}{
+		{esds(0x40, []byte{0x12, 0x10}), "mp4a.40.2"},
+		{esds(0x40, heAAC(6, 2, 3, false)), "mp4a.40.5"},
+		{esds(0x40, explicitAAC(AacPS, 6, 1, 3)), "mp4a.40.29"},
+		{esds(0x6b, nil), "mp4a.6B"},
+	}
+	for _, tt := range tests {
+		b := reencode(t, tt.box).(*EsdsBox)
+		if c := b.Codec("mp4a"); c != tt.want {
+			t.Errorf("codec %s, want %s", c, tt.want)
+		}
+	}
+	if c := (&EsdsBox{}).Codec("mp4a"); c != "mp4a" {
+		t.Errorf("no ES descriptor: codec %s", c)
+	}
+}
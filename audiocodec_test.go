@@ -0,0 +1,194 @@
+package mp4
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// boxBytes returns a box of type typ holding body
+func boxBytes(typ string, body []byte) []byte {
+	return append([]byte{0, 0, byte((8 + len(body)) >> 8), byte(8 + len(body)), typ[0], typ[1], typ[2], typ[3]}, body...)
+}
+
+// audioEntry returns an audio sample entry of format typ (2 channels, 16 bits, 48 kHz) holding the config boxes
+func audioEntry(typ string, config ...[]byte) []byte {
+	body := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 16, 0, 0, 0, 0, 0xbb, 0x80, 0, 0}
+	for _, c := range config {
+		body = append(body, c...)
+	}
+	return boxBytes(typ, body)
+}
+
+// checkReencode checks that a box decoded from data is encoded back to data
+func checkReencode(t *testing.T, b Box, data []byte) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := b.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("%s: encoded % x, want % x", b.Type(), buf.Bytes(), data)
+	}
+}
+
+func TestDOps(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *DOpsBox
+	}{
+		{
+			"stereo", boxBytes("dOps", []byte{0, 2, 0x01, 0x38, 0, 0, 0xbb, 0x80, 0xff, 0x00, 0}),
+			&DOpsBox{OutputChannelCount: 2, PreSkip: 312, InputSampleRate: 48000, OutputGain: -256},
+		},
+		{
+			"5.1", boxBytes("dOps", []byte{0, 6, 0x01, 0x38, 0, 0, 0xac, 0x44, 0, 0x80, 1, 4, 2, 0, 4, 1, 2, 3, 5}),
+			&DOpsBox{OutputChannelCount: 6, PreSkip: 312, InputSampleRate: 44100, OutputGain: 128,
+				ChannelMappingFamily: 1, StreamCount: 4, CoupledCount: 2, ChannelMapping: []byte{0, 4, 1, 2, 3, 5}},
+		},
+	}
+	for _, tt := range tests {
+		b := decodeBox(t, tt.data)
+		if !reflect.DeepEqual(b, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, b, tt.want)
+		}
+		checkReencode(t, b, tt.data)
+	}
+	body := []byte{0, 6, 0x01, 0x38, 0, 0, 0xbb, 0x80, 0, 0, 1, 4, 2, 0}
+	h := BoxHeader{Type: "dOps", Size: uint32(BoxHeaderSize + len(body))}
+	if _, err := DecodeDOps(h, bytes.NewReader(body)); err != ErrTruncatedBody {
+		t.Errorf("truncated channel mapping: %v", err)
+	}
+}
+
+func TestDfLa(t *testing.T) {
+	w := &bitWriter{}
+	w.bits(4096, 16).bits(4096, 16).bits(16, 24).bits(14000, 24)
+	w.bits(44100, 20).bits(1, 3).bits(23, 5).bits(441000, 36)
+	md5 := []byte{0xde, 0xad, 0xbe, 0xef, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	streamInfo := append(w.data, md5...)
+	body := []byte{0, 0, 0, 0, FlacStreamInfo, 0, 0, 34}
+	body = append(body, streamInfo...)
+	body = append(body, 0x80|FlacPadding, 0, 0, 4, 0, 0, 0, 0)
+	data := boxBytes("dfLa", body)
+	b := decodeBox(t, data).(*DfLaBox)
+	if len(b.Blocks) != 2 || b.Blocks[1].BlockType != FlacPadding {
+		t.Fatalf("got %+v", b)
+	}
+	si, err := b.StreamInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FlacStreamInfoBlock{MinBlockSize: 4096, MaxBlockSize: 4096, MinFrameSize: 16, MaxFrameSize: 14000,
+		SampleRate: 44100, Channels: 2, BitsPerSample: 24, TotalSamples: 441000, MD5: md5}
+	if !reflect.DeepEqual(si, want) {
+		t.Errorf("got %+v, want %+v", si, want)
+	}
+	checkReencode(t, b, data)
+
+	// the last-metadata-block flag is set on the last block only
+	b.Blocks = b.Blocks[:1]
+	buf := &bytes.Buffer{}
+	if err := b.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Bytes()[12] != 0x80|FlacStreamInfo {
+		t.Errorf("block header %#x", buf.Bytes()[12])
+	}
+	if _, err := (&DfLaBox{}).StreamInfo(); err != ErrBadFormat {
+		t.Errorf("no STREAMINFO block: %v", err)
+	}
+}
+
+func TestDac3(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		rate     uint32
+		channels int
+		bitrate  uint32
+	}{
+		// fscod, bsid, bsmod, acmod, lfeon, bit_rate_code, reserved
+		{"5.1", (&bitWriter{}).bits(0, 2).bits(8, 5).bits(0, 3).bits(7, 3).flag(true).bits(15, 5).bits(0, 5).data, 48000, 6, 448000},
+		{"stereo", (&bitWriter{}).bits(1, 2).bits(8, 5).bits(0, 3).bits(2, 3).flag(false).bits(10, 5).bits(0, 5).data, 44100, 2, 192000},
+		{"mono", (&bitWriter{}).bits(2, 2).bits(6, 5).bits(2, 3).bits(1, 3).flag(false).bits(0, 5).bits(0, 5).data, 32000, 1, 32000},
+		{"reserved codes", (&bitWriter{}).bits(3, 2).bits(8, 5).bits(0, 3).bits(0, 3).flag(true).bits(19, 5).bits(0, 5).data, 0, 3, 0},
+	}
+	for _, tt := range tests {
+		data := boxBytes("dac3", tt.data)
+		b := decodeBox(t, data).(*Dac3Box)
+		if b.SampleRate() != tt.rate || b.Channels() != tt.channels || b.Bitrate() != tt.bitrate {
+			t.Errorf("%s: %d Hz, %d channels, %d bit/s", tt.name, b.SampleRate(), b.Channels(), b.Bitrate())
+		}
+		checkReencode(t, b, data)
+	}
+}
+
+// ec3Substream writes an independent substream of a dec3 box
+func ec3Substream(w *bitWriter, fscod, acmod uint64, lfe bool, chanLoc uint64) {
+	w.bits(fscod, 2).bits(16, 5).bits(0, 1).flag(false).bits(0, 3).bits(acmod, 3).flag(lfe).bits(0, 3)
+	if chanLoc != 0 {
+		w.bits(1, 4).bits(chanLoc, 9)
+	} else {
+		w.bits(0, 4).bits(0, 1)
+	}
+}
+
+func TestDec3(t *testing.T) {
+	w := &bitWriter{}
+	w.bits(640, 13).bits(0, 3)
+	ec3Substream(w, 0, 7, true, 0)
+	// Atmos extension, not decoded
+	surround := boxBytes("dec3", append(w.data, 0x01, 0x10))
+	w = &bitWriter{}
+	w.bits(1024, 13).bits(0, 3)
+	// Lrs/Rrs
+	ec3Substream(w, 0, 7, true, 0x80)
+	surround71 := boxBytes("dec3", w.data)
+	w = &bitWriter{}
+	w.bits(256, 13).bits(1, 3)
+	ec3Substream(w, 1, 2, false, 0)
+	ec3Substream(w, 1, 1, false, 0)
+	twoSubstreams := boxBytes("dec3", w.data)
+	tests := []struct {
+		name     string
+		data     []byte
+		rate     uint32
+		channels int
+		bitrate  uint32
+	}{
+		{"5.1", surround, 48000, 6, 640000},
+		{"7.1", surround71, 48000, 8, 1024000},
+		{"2 independent substreams", twoSubstreams, 44100, 2, 256000},
+	}
+	for _, tt := range tests {
+		b := decodeBox(t, tt.data).(*Dec3Box)
+		if b.SampleRate() != tt.rate || b.Channels() != tt.channels || b.Bitrate() != tt.bitrate {
+			t.Errorf("%s: %d Hz, %d channels, %d bit/s", tt.name, b.SampleRate(), b.Channels(), b.Bitrate())
+		}
+		checkReencode(t, b, tt.data)
+	}
+}
+
+func TestAudioSampleEntryCodec(t *testing.T) {
+	dac3 := boxBytes("dac3", (&bitWriter{}).bits(1, 2).bits(8, 5).bits(0, 3).bits(2, 3).flag(false).bits(10, 5).bits(0, 5).data)
+	tests := []struct {
+		data  []byte
+		codec string
+		rate  uint32
+	}{
+		{audioEntry("Opus", boxBytes("dOps", []byte{0, 2, 0x01, 0x38, 0, 0, 0xac, 0x44, 0, 0, 0})), "opus", 48000},
+		{audioEntry("fLaC", boxBytes("dfLa", append([]byte{0, 0, 0, 0, 0x80, 0, 0, 34},
+			append((&bitWriter{}).bits(0, 80).bits(96000, 20).bits(1, 3).bits(23, 5).bits(0, 36).data, make([]byte, 16)...)...))),
+			"flac", 96000},
+		{audioEntry("ac-3", dac3), "ac-3", 44100},
+	}
+	for _, tt := range tests {
+		b := decodeBox(t, tt.data).(*AudioSampleEntry)
+		if b.Codec() != tt.codec || b.Rate() != tt.rate {
+			t.Errorf("%s: codec %s, rate %d", b.Format, b.Codec(), b.Rate())
+		}
+		checkReencode(t, b, tt.data)
+	}
+}
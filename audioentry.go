@@ -0,0 +1,203 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Audio Sample Entry (mp4a, Opus, ac-3, ec-3, fLaC, ... - mandatory for audio tracks)
+//
+// Contained in : Sample Description Box (stsd)
+//
+// Status: decoded
+//
+// Describes the format of the samples of an audio track : codec (Format), channel count, sample rate, ...
+// The codec configuration is stored in the boxes following the entry fields (esds for AAC, dOps for
+// Opus, dfLa for FLAC, dac3/dec3 for AC-3/E-AC-3).
+//
+// SampleRate is a fixed point number (16 bits + 16 bits). Sample rates above 65535 Hz cannot be stored,
+// the actual rate is then defined by the codec configuration.
+//
+// Version is the QuickTime sound description version (0 for ISO files). Versions 1 and 2 add
+// fields that are not decoded.
+type AudioSampleEntry struct {
+	Format             string
+	DataReferenceIndex uint16
+	Version            uint16
+	ChannelCount       uint16
+	SampleSize         uint16
+	SampleRate         Fixed32
+	Esds               *EsdsBox `json:"esds,omitempty"`
+	DOps               *DOpsBox `json:"dOps,omitempty"`
+	DfLa               *DfLaBox `json:"dfLa,omitempty"`
+	Dac3               *Dac3Box `json:"dac3,omitempty"`
+	Dec3               *Dec3Box `json:"dec3,omitempty"`
+	Boxes              []Box    `json:",omitempty"`
+	fields             []byte   // as read from the media, with the reserved values
+	notDecoded         []byte
+	trailing           []byte
+	order              []string
+}
+
+func DecodeAudioSampleEntry(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 28 {
+		return nil, ErrTruncatedBody
+	}
+	b := &AudioSampleEntry{
+		Format:             h.Type,
+		DataReferenceIndex: decodeSampleEntryHeader(data),
+		Version:            binary.BigEndian.Uint16(data[8:10]),
+		ChannelCount:       binary.BigEndian.Uint16(data[16:18]),
+		SampleSize:         binary.BigEndian.Uint16(data[18:20]),
+		SampleRate:         fixed32(data[24:28]),
+	}
+	off := 28
+	switch b.Version {
+	case 1:
+		off += 16
+	case 2:
+		off += 36
+	}
+	if len(data) < off {
+		return nil, ErrTruncatedBody
+	}
+	b.fields, b.notDecoded = data[:28], data[28:off]
+	l, trailing, err := decodeSampleEntryBoxes(r, data[off:])
+	if err != nil {
+		return nil, err
+	}
+	b.trailing = trailing
+	b.order = boxTypes(l)
+	for _, c := range l {
+		if _, ok := c.(*RawBox); ok {
+			b.Boxes = append(b.Boxes, c)
+			continue
+		}
+		switch c.Type() {
+		case "esds":
+			b.Esds = c.(*EsdsBox)
+		case "dOps":
+			b.DOps = c.(*DOpsBox)
+		case "dfLa":
+			b.DfLa = c.(*DfLaBox)
+		case "dac3":
+			b.Dac3 = c.(*Dac3Box)
+		case "dec3":
+			b.Dec3 = c.(*Dec3Box)
+		default:
+			b.Boxes = append(b.Boxes, c.Box())
+		}
+	}
+	return b, nil
+}
+
+func (b *AudioSampleEntry) Box() Box {
+	return b
+}
+
+func (b *AudioSampleEntry) Type() string {
+	return b.Format
+}
+
+// configBoxes returns the decoded codec configuration boxes
+func (b *AudioSampleEntry) configBoxes() []Box {
+	l := []Box{}
+	if b.Esds != nil {
+		l = append(l, b.Esds)
+	}
+	if b.DOps != nil {
+		l = append(l, b.DOps)
+	}
+	if b.DfLa != nil {
+		l = append(l, b.DfLa)
+	}
+	if b.Dac3 != nil {
+		l = append(l, b.Dac3)
+	}
+	if b.Dec3 != nil {
+		l = append(l, b.Dec3)
+	}
+	return l
+}
+
+// boxes returns the child boxes, codec configuration first
+func (b *AudioSampleEntry) boxes() []Box {
+	return orderedBoxes(b.order, append(b.configBoxes(), b.Boxes...))
+}
+
+func (b *AudioSampleEntry) Size() int {
+	return BoxHeaderSize + 28 + len(b.notDecoded) + sampleEntryBoxesSize(b.boxes(), b.trailing)
+}
+
+// Codec returns the RFC 6381 codec string (e.g. mp4a.40.2), or the sample entry format if the codec
+// configuration is unknown
+func (b *AudioSampleEntry) Codec() string {
+	switch {
+	case b.Esds != nil:
+		return b.Esds.Codec(b.Format)
+	case b.Format == "Opus":
+		return "opus"
+	case b.Format == "fLaC":
+		return "flac"
+	}
+	return b.Format
+}
+
+// Rate returns the sample rate in Hz, as defined by the codec configuration if it is known
+func (b *AudioSampleEntry) Rate() uint32 {
+	switch {
+	case b.Esds != nil:
+		if c, err := b.Esds.AudioConfig(); err == nil {
+			return c.OutputSamplingFrequency()
+		}
+	case b.DfLa != nil:
+		if si, err := b.DfLa.StreamInfo(); err == nil {
+			return si.SampleRate
+		}
+	case b.Dac3 != nil:
+		return b.Dac3.SampleRate()
+	case b.Dec3 != nil:
+		return b.Dec3.SampleRate()
+	}
+	return uint32(b.SampleRate) >> 16
+}
+
+func (b *AudioSampleEntry) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 28+len(b.notDecoded))
+	copy(buf, b.fields)
+	encodeSampleEntryHeader(buf, b.DataReferenceIndex)
+	binary.BigEndian.PutUint16(buf[8:], b.Version)
+	binary.BigEndian.PutUint16(buf[16:], b.ChannelCount)
+	binary.BigEndian.PutUint16(buf[18:], b.SampleSize)
+	putFixed32(buf[24:], b.SampleRate)
+	copy(buf[28:], b.notDecoded)
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+	return encodeSampleEntryBoxes(w, b.boxes(), b.trailing)
+}
+
+func (b *AudioSampleEntry) Dump() {
+	fmt.Printf("Audio Sample Entry\n")
+	fmt.Printf("+- Format: %s\n", b.Format)
+	fmt.Printf("+- Codec: %s\n", b.Codec())
+	fmt.Printf("+- Channels: %d\n", b.ChannelCount)
+	fmt.Printf("+- Sample size: %d bits\n", b.SampleSize)
+	fmt.Printf("+- Sample rate: %d Hz\n", b.Rate())
+	for _, c := range b.configBoxes() {
+		c.Dump()
+	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
+}
@@ -0,0 +1,145 @@
+package mp4
+
+import (
+	"fmt"
+	"io"
+)
+
+// AV1 Codec Configuration Box (av1C - mandatory for AV1 video tracks)
+//
+// Contained in : Visual Sample Entry (av01)
+//
+// Status: decoded
+//
+// Contains the AV1CodecConfigurationRecord (AV1 Codec ISO Media File Format Binding) : profile, level
+// and tier of the first operating point, color format and the configuration OBUs (usually the sequence
+// header OBU, see SequenceHeader).
+type Av1CBox struct {
+	Version                          byte
+	SeqProfile                       byte
+	SeqLevelIdx0                     byte
+	SeqTier0                         byte
+	HighBitdepth                     bool
+	TwelveBit                        bool
+	Monochrome                       bool
+	ChromaSubsamplingX               byte
+	ChromaSubsamplingY               byte
+	ChromaSamplePosition             byte
+	InitialPresentationDelayPresent  bool
+	InitialPresentationDelayMinusOne byte
+	ConfigOBUs                       []byte `json:",omitempty"`
+}
+
+func DecodeAv1C(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, ErrTruncatedBody
+	}
+	if data[0]&0x80 == 0 {
+		return nil, ErrBadFormat
+	}
+	return &Av1CBox{
+		Version:                          data[0] & 0x7f,
+		SeqProfile:                       data[1] >> 5,
+		SeqLevelIdx0:                     data[1] & 0x1f,
+		SeqTier0:                         data[2] >> 7,
+		HighBitdepth:                     data[2]&0x40 != 0,
+		TwelveBit:                        data[2]&0x20 != 0,
+		Monochrome:                       data[2]&0x10 != 0,
+		ChromaSubsamplingX:               (data[2] >> 3) & 0x01,
+		ChromaSubsamplingY:               (data[2] >> 2) & 0x01,
+		ChromaSamplePosition:             data[2] & 0x03,
+		InitialPresentationDelayPresent:  data[3]&0x10 != 0,
+		InitialPresentationDelayMinusOne: data[3] & 0x0f,
+		ConfigOBUs:                       data[4:],
+	}, nil
+}
+
+func (b *Av1CBox) Box() Box {
+	return b
+}
+
+func (b *Av1CBox) Type() string {
+	return "av1C"
+}
+
+func (b *Av1CBox) Size() int {
+	return BoxHeaderSize + 4 + len(b.ConfigOBUs)
+}
+
+// BitDepth returns the bit depth of the samples (8, 10 or 12)
+func (b *Av1CBox) BitDepth() int {
+	switch {
+	case b.TwelveBit:
+		return 12
+	case b.HighBitdepth:
+		return 10
+	}
+	return 8
+}
+
+// SequenceHeader parses the sequence header OBU stored in the configuration OBUs
+func (b *Av1CBox) SequenceHeader() (*AV1SequenceHeader, error) {
+	data := b.ConfigOBUs
+	for len(data) > 0 {
+		obuType, payload, n, err := splitOBU(data)
+		if err != nil {
+			return nil, err
+		}
+		if obuType == av1OBUSequenceHeader {
+			return ParseAV1SequenceHeader(payload)
+		}
+		data = data[n:]
+	}
+	return nil, ErrBadFormat
+}
+
+// Codec returns the RFC 6381 codec string (e.g. av01.0.08M.08), as defined by the AV1 Codec ISO Media
+// File Format Binding
+func (b *Av1CBox) Codec(format string) string {
+	tier := "M"
+	if b.SeqTier0 == 1 {
+		tier = "H"
+	}
+	return fmt.Sprintf("%s.%d.%02d%s.%02d", format, b.SeqProfile, b.SeqLevelIdx0, tier, b.BitDepth())
+}
+
+func (b *Av1CBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = 0x80 | b.Version&0x7f
+	buf[1] = b.SeqProfile<<5 | b.SeqLevelIdx0&0x1f
+	buf[2] = b.SeqTier0<<7 | (b.ChromaSubsamplingX&0x01)<<3 | (b.ChromaSubsamplingY&0x01)<<2 | b.ChromaSamplePosition&0x03
+	if b.HighBitdepth {
+		buf[2] |= 0x40
+	}
+	if b.TwelveBit {
+		buf[2] |= 0x20
+	}
+	if b.Monochrome {
+		buf[2] |= 0x10
+	}
+	if b.InitialPresentationDelayPresent {
+		buf[3] = 0x10 | b.InitialPresentationDelayMinusOne&0x0f
+	}
+	copy(buf[4:], b.ConfigOBUs)
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *Av1CBox) Dump() {
+	fmt.Printf("AV1 Configuration Box\n")
+	fmt.Printf("+- Profile: %d\n", b.SeqProfile)
+	fmt.Printf("+- Level: %d\n", b.SeqLevelIdx0)
+	fmt.Printf("+- Tier: %d\n", b.SeqTier0)
+	fmt.Printf("+- Bit depth: %d\n", b.BitDepth())
+	if sh, err := b.SequenceHeader(); err == nil {
+		fmt.Printf("+- Sequence header: %dx%d, %d bits\n", sh.MaxWidth, sh.MaxHeight, sh.BitDepth)
+	}
+}
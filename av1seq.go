@@ -0,0 +1,198 @@
+package mp4
+
+// AV1 OBU types (AV1 Bitstream & Decoding Process Specification, 6.2.2)
+const (
+	av1OBUSequenceHeader = 1
+)
+
+// AV1 Sequence Header OBU (AV1 Bitstream & Decoding Process Specification, 5.5)
+//
+// Only the fields needed to describe the stream are kept. Level and Tier are the ones of the first
+// operating point, MaxWidth and MaxHeight are the maximum frame dimensions.
+type AV1SequenceHeader struct {
+	Profile                 byte
+	StillPicture            bool
+	Level                   byte
+	Tier                    byte
+	MaxWidth, MaxHeight     uint32
+	BitDepth                int
+	Monochrome              bool
+	SubsamplingX            byte
+	SubsamplingY            byte
+	ColorPrimaries          byte
+	TransferCharacteristics byte
+	MatrixCoefficients      byte
+	FullRange               bool
+}
+
+// splitOBU returns the type and the payload of the first OBU of data, and the total size of the OBU
+func splitOBU(data []byte) (byte, []byte, int, error) {
+	if len(data) < 1 {
+		return 0, nil, 0, ErrTruncatedBody
+	}
+	obuType := (data[0] >> 3) & 0x0f
+	off := 1
+	if data[0]&0x04 != 0 { // obu_extension_flag
+		off++
+	}
+	if off > len(data) {
+		return 0, nil, 0, ErrTruncatedBody
+	}
+	if data[0]&0x02 == 0 { // no obu_has_size_field, the OBU fills the data
+		return obuType, data[off:], len(data), nil
+	}
+	sz, n := leb128(data[off:])
+	if n == 0 {
+		return 0, nil, 0, ErrTruncatedBody
+	}
+	off += n
+	if sz > uint64(len(data)-off) {
+		return 0, nil, 0, ErrTruncatedBody
+	}
+	return obuType, data[off : off+int(sz)], off + int(sz), nil
+}
+
+// leb128 decodes an unsigned LEB128 value, and returns it with the number of bytes read (0 on error)
+func leb128(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8 && i < len(data); i++ {
+		v |= uint64(data[i]&0x7f) << (uint(i) * 7)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// ParseAV1SequenceHeader parses the payload of a sequence header OBU (without the OBU header)
+func ParseAV1SequenceHeader(data []byte) (*AV1SequenceHeader, error) {
+	r := newBitReader(data)
+	s := &AV1SequenceHeader{
+		Profile:      byte(r.readBits(3)),
+		StillPicture: r.readFlag(),
+	}
+	reduced := r.readFlag()
+	if reduced {
+		s.Level = byte(r.readBits(5))
+	} else {
+		decoderModelInfo := false
+		bufferDelayLength := 0
+		if r.readFlag() { // timing_info_present_flag
+			r.skip(64) // num_units_in_display_tick, time_scale
+			if r.readFlag() {
+				skipUVLC(r) // num_ticks_per_picture_minus_1
+			}
+			decoderModelInfo = r.readFlag()
+			if decoderModelInfo {
+				bufferDelayLength = int(r.readBits(5)) + 1
+				r.skip(32 + 5 + 5)
+			}
+		}
+		initialDisplayDelay := r.readFlag()
+		n := int(r.readBits(5)) + 1
+		for i := 0; i < n; i++ {
+			r.skip(12) // operating_point_idc
+			level := byte(r.readBits(5))
+			tier := byte(0)
+			if level > 7 {
+				tier = byte(r.readBits(1))
+			}
+			if i == 0 {
+				s.Level, s.Tier = level, tier
+			}
+			if decoderModelInfo && r.readFlag() {
+				r.skip(2*bufferDelayLength + 1)
+			}
+			if initialDisplayDelay && r.readFlag() {
+				r.skip(4)
+			}
+		}
+	}
+	wbits := int(r.readBits(4)) + 1
+	hbits := int(r.readBits(4)) + 1
+	s.MaxWidth = r.readBits(wbits) + 1
+	s.MaxHeight = r.readBits(hbits) + 1
+	if !reduced && r.readFlag() { // frame_id_numbers_present_flag
+		r.skip(7)
+	}
+	r.skip(3) // use_128x128_superblock, enable_filter_intra, enable_intra_edge_filter
+	if !reduced {
+		r.skip(4) // enable_interintra_compound, enable_masked_compound, enable_warped_motion, enable_dual_filter
+		orderHint := r.readFlag()
+		if orderHint {
+			r.skip(2) // enable_jnt_comp, enable_ref_frame_mvs
+		}
+		forceScreenContentTools := uint32(2)
+		if !r.readFlag() { // seq_choose_screen_content_tools
+			forceScreenContentTools = r.readBits(1)
+		}
+		if forceScreenContentTools > 0 && !r.readFlag() { // seq_choose_integer_mv
+			r.skip(1)
+		}
+		if orderHint {
+			r.skip(3)
+		}
+	}
+	r.skip(3) // enable_superres, enable_cdef, enable_restoration
+	s.parseColorConfig(r)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return s, nil
+}
+
+// parseColorConfig parses the color_config structure (5.5.2)
+func (s *AV1SequenceHeader) parseColorConfig(r *bitReader) {
+	s.BitDepth = 8
+	if r.readFlag() { // high_bitdepth
+		s.BitDepth = 10
+		if s.Profile == 2 && r.readFlag() {
+			s.BitDepth = 12
+		}
+	}
+	if s.Profile != 1 {
+		s.Monochrome = r.readFlag()
+	}
+	s.ColorPrimaries, s.TransferCharacteristics, s.MatrixCoefficients = 2, 2, 2
+	if r.readFlag() { // color_description_present_flag
+		s.ColorPrimaries = byte(r.readBits(8))
+		s.TransferCharacteristics = byte(r.readBits(8))
+		s.MatrixCoefficients = byte(r.readBits(8))
+	}
+	switch {
+	case s.Monochrome:
+		s.FullRange = r.readFlag()
+		s.SubsamplingX, s.SubsamplingY = 1, 1
+	case s.ColorPrimaries == 1 && s.TransferCharacteristics == 13 && s.MatrixCoefficients == 0:
+		s.FullRange = true
+	default:
+		s.FullRange = r.readFlag()
+		switch {
+		case s.Profile == 0:
+			s.SubsamplingX, s.SubsamplingY = 1, 1
+		case s.Profile == 2 && s.BitDepth == 12:
+			s.SubsamplingX = byte(r.readBits(1))
+			if s.SubsamplingX == 1 {
+				s.SubsamplingY = byte(r.readBits(1))
+			}
+		case s.Profile == 2:
+			s.SubsamplingX = 1
+		}
+	}
+}
+
+// skipUVLC skips a variable length unsigned value (4.10.3)
+func skipUVLC(r *bitReader) {
+	zeros := 0
+	for !r.readFlag() {
+		if r.err != nil {
+			return
+		}
+		zeros++
+		if zeros >= 32 {
+			r.err = ErrBadFormat
+			return
+		}
+	}
+	r.skip(zeros)
+}
@@ -0,0 +1,165 @@
+package mp4
+
+import (
+	"reflect"
+	"testing"
+)
+
+// reduced_still_picture_header
+func av1StillPicture() []byte {
+	w := &bitWriter{}
+	w.bits(0, 3).flag(true).flag(true).bits(8, 5)
+	w.bits(10, 4).bits(10, 4).bits(1919, 11).bits(1079, 11)
+	w.bits(7, 3).bits(3, 3)
+	w.flag(false).flag(false).flag(false).flag(true) // 8 bits, color, full range
+	return w.data
+}
+
+// timing and decoder model information, 2 operating points, 12 bits 4:2:2
+func av1Professional() []byte {
+	w := &bitWriter{}
+	w.bits(2, 3).flag(false).flag(false)
+	w.flag(true).bits(1001, 32).bits(60000, 32).flag(true).bits(1, 1) // timing info
+	w.flag(true).bits(15, 5).bits(1, 32).bits(31, 5).bits(31, 5)      // decoder model info
+	w.flag(true)                                                      // initial_display_delay_present_flag
+	w.bits(1, 5)
+	w.bits(0, 12).bits(12, 5).bits(1, 1).flag(true).bits(0x1234, 16).bits(0x5678, 16).flag(false).flag(true).bits(9, 4)
+	w.bits(0x101, 12).bits(4, 5).flag(false).flag(false)
+	w.bits(11, 4).bits(11, 4).bits(3839, 12).bits(2159, 12)
+	w.flag(true).bits(0, 7) // frame_id_numbers_present_flag
+	w.bits(0, 3).bits(0, 4)
+	w.flag(true).bits(3, 2)                         // order hint
+	w.flag(false).bits(1, 1).flag(false).bits(1, 1) // screen content tools, integer mv
+	w.bits(6, 3)
+	w.bits(0, 3)
+	w.flag(true).flag(true).flag(false)            // 12 bits, not monochrome
+	w.flag(true).bits(9, 8).bits(16, 8).bits(9, 8) // BT.2020, PQ
+	w.flag(false).bits(1, 1).bits(0, 1)            // 4:2:2
+	return w.data
+}
+
+// monochrome, 10 bits
+func av1Monochrome() []byte {
+	w := &bitWriter{}
+	w.bits(0, 3).flag(false).flag(false).flag(false).flag(false).bits(0, 5)
+	w.bits(0, 12).bits(9, 5).bits(0, 1)
+	w.bits(9, 4).bits(9, 4).bits(639, 10).bits(479, 10)
+	w.flag(false).bits(0, 3).bits(0, 4).flag(false)
+	w.flag(true).flag(true) // seq_choose_screen_content_tools, seq_choose_integer_mv
+	w.bits(0, 3)
+	w.flag(true).flag(true).flag(false).flag(true)
+	return w.data
+}
+
+func TestParseAV1SequenceHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want AV1SequenceHeader
+	}{
+		{
+			"still picture", av1StillPicture(),
+			AV1SequenceHeader{StillPicture: true, Level: 8, MaxWidth: 1920, MaxHeight: 1080, BitDepth: 8,
+				SubsamplingX: 1, SubsamplingY: 1, ColorPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2,
+				FullRange: true},
+		},
+		{
+			"professional", av1Professional(),
+			AV1SequenceHeader{Profile: 2, Level: 12, Tier: 1, MaxWidth: 3840, MaxHeight: 2160, BitDepth: 12,
+				SubsamplingX: 1, ColorPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9},
+		},
+		{
+			"monochrome", av1Monochrome(),
+			AV1SequenceHeader{Level: 9, MaxWidth: 640, MaxHeight: 480, BitDepth: 10, Monochrome: true,
+				SubsamplingX: 1, SubsamplingY: 1, ColorPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2,
+				FullRange: true},
+		},
+	}
+	for _, tt := range tests {
+		s, err := ParseAV1SequenceHeader(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*s, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *s, tt.want)
+		}
+	}
+	if _, err := ParseAV1SequenceHeader(av1Professional()[:20]); err != ErrTruncatedBody {
+		t.Errorf("truncated: %v", err)
+	}
+}
+
+// av1C returns an av1C box holding a sequence header OBU
+func av1C(profile, level, tier byte, high, twelve, mono bool, obu []byte) []byte {
+	w := &bitWriter{}
+	w.bits(0x81, 8).bits(uint64(profile), 3).bits(uint64(level), 5)
+	w.bits(uint64(tier), 1).flag(high).flag(twelve).flag(mono).bits(1, 1).bits(1, 1).bits(0, 2)
+	w.bits(0, 8)
+	body := append(w.data, obu...)
+	return append([]byte{0, 0, 0, byte(8 + len(body)), 'a', 'v', '1', 'C'}, body...)
+}
+
+func TestAv1CCodec(t *testing.T) {
+	tests := []struct {
+		box      []byte
+		want     string
+		bitDepth int
+		width    uint32
+	}{
+		{
+			// OBU with a size field
+			av1C(0, 8, 0, false, false, false, append([]byte{0x0a, byte(len(av1StillPicture()))}, av1StillPicture()...)),
+			"av01.0.08M.08", 8, 1920,
+		},
+		{
+			// OBU with an extension header, without size field
+			av1C(2, 12, 1, true, true, false, append([]byte{0x0c, 0}, av1Professional()...)),
+			"av01.2.12H.12", 12, 3840,
+		},
+		{
+			// a metadata OBU before the sequence header OBU
+			av1C(0, 9, 0, true, false, true, append([]byte{0x2a, 2, 1, 2, 0x0a, byte(len(av1Monochrome()))}, av1Monochrome()...)),
+			"av01.0.09M.10", 10, 640,
+		},
+	}
+	for _, tt := range tests {
+		b := decodeBox(t, tt.box).(*Av1CBox)
+		if c := b.Codec("av01"); c != tt.want {
+			t.Errorf("codec %s, want %s", c, tt.want)
+		}
+		if b.BitDepth() != tt.bitDepth {
+			t.Errorf("%s: bit depth %d", tt.want, b.BitDepth())
+		}
+		s, err := b.SequenceHeader()
+		if err != nil || s.MaxWidth != tt.width || s.BitDepth != tt.bitDepth {
+			t.Errorf("%s: sequence header %+v, %v", tt.want, s, err)
+		}
+	}
+	b := decodeBox(t, av1C(0, 8, 0, false, false, false, []byte{0x2a, 2, 1, 2})).(*Av1CBox)
+	if _, err := b.SequenceHeader(); err != ErrBadFormat {
+		t.Errorf("no sequence header: %v", err)
+	}
+}
+
+func TestVpcCCodec(t *testing.T) {
+	tests := []struct {
+		format string
+		body   []byte
+		want   string
+	}{
+		{"vp09", []byte{1, 0, 0, 0, 0, 10, 8<<4 | 1<<1, 1, 1, 1, 0, 0}, "vp09.00.10.08"},
+		{"vp09", []byte{1, 0, 0, 0, 2, 41, 10<<4 | 1<<1 | 1, 9, 16, 9, 0, 2, 0xab, 0xcd}, "vp09.02.41.10"},
+		{"vp08", []byte{1, 0, 0, 0, 0, 10, 8<<4 | 1<<1, 1, 1, 1, 0, 0}, "vp08.00.10.08"},
+	}
+	for _, tt := range tests {
+		b := decodeBox(t, append([]byte{0, 0, 0, byte(8 + len(tt.body)), 'v', 'p', 'c', 'C'}, tt.body...)).(*VpcCBox)
+		if c := b.Codec(tt.format); c != tt.want {
+			t.Errorf("codec %s, want %s", c, tt.want)
+		}
+	}
+	b := decodeBox(t, []byte{0, 0, 0, 22, 'v', 'p', 'c', 'C', 1, 0, 0, 0, 2, 41, 10<<4 | 1<<1 | 1, 9, 16, 9, 0, 2, 0xab, 0xcd}).(*VpcCBox)
+	if !b.FullRange || b.ChromaSubsampling != 1 || b.ColourPrimaries != 9 || len(b.CodecInitializationData) != 2 {
+		t.Errorf("got %+v", b)
+	}
+}
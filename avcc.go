@@ -0,0 +1,191 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// AVC Configuration Box (avcC - mandatory for H.264 video tracks)
+//
+// Contained in : Visual Sample Entry (avc1, avc3)
+//
+// Status: decoded
+//
+// Contains the AVCDecoderConfigurationRecord (ISO/IEC 14496-15) : profile, level, size of the NAL unit
+// length fields in samples (LengthSizeMinusOne + 1 bytes) and the sequence/picture parameter sets.
+//
+// The high profile fields (chroma format, bit depths and SPS extensions) are only present if
+// HasHighProfileFields is set, as many encoders omit them.
+type AvcCBox struct {
+	ConfigurationVersion byte
+	Profile              byte
+	ProfileCompatibility byte
+	Level                byte
+	LengthSizeMinusOne   byte
+	SPS                  [][]byte
+	PPS                  [][]byte
+	HasHighProfileFields bool     `json:",omitempty"`
+	ChromaFormat         byte     `json:",omitempty"`
+	BitDepthLumaMinus8   byte     `json:",omitempty"`
+	BitDepthChromaMinus8 byte     `json:",omitempty"`
+	SPSExt               [][]byte `json:",omitempty"`
+	notDecoded           []byte
+}
+
+func DecodeAvcC(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 6 {
+		return nil, ErrTruncatedBody
+	}
+	b := &AvcCBox{
+		ConfigurationVersion: data[0],
+		Profile:              data[1],
+		ProfileCompatibility: data[2],
+		Level:                data[3],
+		LengthSizeMinusOne:   data[4] & 0x03,
+	}
+	off := 5
+	b.SPS, off, err = decodeParameterSets(data, off, int(data[5]&0x1f))
+	if err != nil {
+		return nil, err
+	}
+	if off >= len(data) {
+		return nil, ErrTruncatedBody
+	}
+	b.PPS, off, err = decodeParameterSets(data, off, int(data[off]))
+	if err != nil {
+		return nil, err
+	}
+	if isAvcHighProfile(b.Profile) && len(data)-off >= 4 {
+		b.HasHighProfileFields = true
+		b.ChromaFormat = data[off] & 0x03
+		b.BitDepthLumaMinus8 = data[off+1] & 0x07
+		b.BitDepthChromaMinus8 = data[off+2] & 0x07
+		b.SPSExt, off, err = decodeParameterSets(data, off+3, int(data[off+3]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.notDecoded = data[off:]
+	return b, nil
+}
+
+// decodeParameterSets decodes count parameter sets (16 bits length + NAL unit), starting after the
+// count byte found at data[off]
+func decodeParameterSets(data []byte, off, count int) ([][]byte, int, error) {
+	off++
+	l := [][]byte{}
+	for i := 0; i < count; i++ {
+		if off+2 > len(data) {
+			return nil, off, ErrTruncatedBody
+		}
+		sz := int(binary.BigEndian.Uint16(data[off : off+2]))
+		off += 2
+		if off+sz > len(data) {
+			return nil, off, ErrTruncatedBody
+		}
+		l = append(l, data[off:off+sz])
+		off += sz
+	}
+	return l, off, nil
+}
+
+func parameterSetsSize(l [][]byte) int {
+	sz := 1
+	for _, ps := range l {
+		sz += 2 + len(ps)
+	}
+	return sz
+}
+
+func encodeParameterSets(buf []byte, l [][]byte, countMask byte) int {
+	buf[0] = countMask | byte(len(l))
+	off := 1
+	for _, ps := range l {
+		binary.BigEndian.PutUint16(buf[off:], uint16(len(ps)))
+		off += 2 + copy(buf[off+2:], ps)
+	}
+	return off
+}
+
+// isAvcHighProfile returns true for the profiles that define chroma format and bit depths
+func isAvcHighProfile(profile byte) bool {
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		return true
+	}
+	return false
+}
+
+func (b *AvcCBox) Box() Box {
+	return b
+}
+
+func (b *AvcCBox) Type() string {
+	return "avcC"
+}
+
+func (b *AvcCBox) Size() int {
+	sz := BoxHeaderSize + 5 + parameterSetsSize(b.SPS) + parameterSetsSize(b.PPS) + len(b.notDecoded)
+	if b.HasHighProfileFields {
+		sz += 3 + parameterSetsSize(b.SPSExt)
+	}
+	return sz
+}
+
+// NALUnitLength returns the size (in bytes) of the length field preceding each NAL unit in samples
+func (b *AvcCBox) NALUnitLength() int {
+	return int(b.LengthSizeMinusOne) + 1
+}
+
+// Codec returns the RFC 6381 codec string (e.g. avc1.64001f) for a sample entry format (avc1 or avc3)
+func (b *AvcCBox) Codec(format string) string {
+	return fmt.Sprintf("%s.%02x%02x%02x", format, b.Profile, b.ProfileCompatibility, b.Level)
+}
+
+func (b *AvcCBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.ConfigurationVersion
+	buf[1], buf[2], buf[3] = b.Profile, b.ProfileCompatibility, b.Level
+	buf[4] = 0xfc | b.LengthSizeMinusOne&0x03
+	off := 5
+	off += encodeParameterSets(buf[off:], b.SPS, 0xe0)
+	off += encodeParameterSets(buf[off:], b.PPS, 0)
+	if b.HasHighProfileFields {
+		buf[off] = 0xfc | b.ChromaFormat&0x03
+		buf[off+1] = 0xf8 | b.BitDepthLumaMinus8&0x07
+		buf[off+2] = 0xf8 | b.BitDepthChromaMinus8&0x07
+		off += 3
+		off += encodeParameterSets(buf[off:], b.SPSExt, 0)
+	}
+	copy(buf[off:], b.notDecoded)
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *AvcCBox) Dump() {
+	fmt.Printf("AVC Configuration Box\n")
+	fmt.Printf("+- Profile: %d\n", b.Profile)
+	fmt.Printf("+- Level: %d\n", b.Level)
+	fmt.Printf("+- NAL unit length: %d bytes\n", b.NALUnitLength())
+	for _, ps := range b.SPS {
+		sps, err := ParseAvcSPS(ps)
+		if err != nil {
+			fmt.Printf("+- SPS: %s\n", err)
+			continue
+		}
+		fmt.Printf("+- SPS: %dx%d, chroma format %d, %d bits", sps.Width, sps.Height, sps.ChromaFormat, sps.BitDepthLuma)
+		if fr := sps.FrameRate(); fr > 0 {
+			fmt.Printf(", %.3f fps", fr)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,187 @@
+package mp4
+
+// H.264 Sequence Parameter Set (ITU-T H.264, 7.3.2.1.1)
+//
+// Only the fields needed to describe the stream are kept. Width and Height are the displayed
+// dimensions (after cropping). The pixel aspect ratio (SarWidth:SarHeight) and the timing information
+// are only set if the VUI parameters define them.
+type AvcSPS struct {
+	Profile         byte
+	ConstraintFlags byte
+	Level           byte
+	ID              uint32
+	ChromaFormat    uint32 // 0: monochrome, 1: 4:2:0, 2: 4:2:2, 3: 4:4:4
+	BitDepthLuma    uint32
+	BitDepthChroma  uint32
+	FrameMbsOnly    bool
+	Width, Height   uint32
+	SarWidth        uint32 `json:",omitempty"`
+	SarHeight       uint32 `json:",omitempty"`
+	NumUnitsInTick  uint32 `json:",omitempty"`
+	TimeScale       uint32 `json:",omitempty"`
+	FixedFrameRate  bool   `json:",omitempty"`
+}
+
+// Sample aspect ratios defined by aspect_ratio_idc (Table E-1)
+var avcSampleAspectRatios = [][2]uint32{
+	{0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+// ParseAvcSPS parses a SPS NAL unit (starting with the NAL unit header, as stored in the avcC box)
+func ParseAvcSPS(nal []byte) (*AvcSPS, error) {
+	if len(nal) < 4 {
+		return nil, ErrTruncatedBody
+	}
+	if nal[0]&0x1f != 7 {
+		return nil, ErrBadFormat
+	}
+	r := newBitReader(unescapeRBSP(nal[1:]))
+	s := &AvcSPS{
+		Profile:         byte(r.readBits(8)),
+		ConstraintFlags: byte(r.readBits(8)),
+		Level:           byte(r.readBits(8)),
+		ID:              r.readUE(),
+		ChromaFormat:    1,
+		BitDepthLuma:    8,
+		BitDepthChroma:  8,
+	}
+	separateColourPlane := false
+	if isAvcHighProfile(s.Profile) {
+		s.ChromaFormat = r.readUE()
+		if s.ChromaFormat == 3 {
+			separateColourPlane = r.readFlag()
+		}
+		s.BitDepthLuma = r.readUE() + 8
+		s.BitDepthChroma = r.readUE() + 8
+		r.skip(1) // qpprime_y_zero_transform_bypass_flag
+		if r.readFlag() {
+			n := 8
+			if s.ChromaFormat == 3 {
+				n = 12
+			}
+			for i := 0; i < n; i++ {
+				if !r.readFlag() {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipAvcScalingList(r, size)
+			}
+		}
+	}
+	r.readUE() // log2_max_frame_num_minus4
+	switch r.readUE() {
+	case 0:
+		r.readUE() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.skip(1) // delta_pic_order_always_zero_flag
+		r.readSE()
+		r.readSE()
+		n := r.readUE()
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			r.readSE()
+		}
+	}
+	r.readUE() // max_num_ref_frames
+	r.skip(1)  // gaps_in_frame_num_value_allowed_flag
+	widthInMbs := r.readUE() + 1
+	heightInMapUnits := r.readUE() + 1
+	s.FrameMbsOnly = r.readFlag()
+	if !s.FrameMbsOnly {
+		r.skip(1) // mb_adaptive_frame_field_flag
+	}
+	r.skip(1) // direct_8x8_inference_flag
+	frameHeightFactor := uint32(1)
+	if !s.FrameMbsOnly {
+		frameHeightFactor = 2
+	}
+	s.Width = widthInMbs * 16
+	s.Height = frameHeightFactor * heightInMapUnits * 16
+	if r.readFlag() {
+		left, right, top, bottom := r.readUE(), r.readUE(), r.readUE(), r.readUE()
+		cropX, cropY := uint32(1), frameHeightFactor
+		if s.ChromaFormat != 0 && !separateColourPlane {
+			if s.ChromaFormat < 3 {
+				cropX = 2
+			}
+			if s.ChromaFormat == 1 {
+				cropY *= 2
+			}
+		}
+		s.Width -= (left + right) * cropX
+		s.Height -= (top + bottom) * cropY
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.readFlag() {
+		s.parseVUI(r)
+	}
+	// VUI errors are ignored, the main fields have been decoded
+	return s, nil
+}
+
+func skipAvcScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size && r.err == nil; j++ {
+		if next != 0 {
+			next = (last + r.readSE() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// parseVUI parses the beginning of the VUI parameters (aspect ratio and timing information)
+func (s *AvcSPS) parseVUI(r *bitReader) {
+	if r.readFlag() {
+		idc := r.readBits(8)
+		if idc == 255 {
+			s.SarWidth = r.readBits(16)
+			s.SarHeight = r.readBits(16)
+		} else if int(idc) < len(avcSampleAspectRatios) {
+			s.SarWidth, s.SarHeight = avcSampleAspectRatios[idc][0], avcSampleAspectRatios[idc][1]
+		}
+	}
+	if r.readFlag() { // overscan_info_present_flag
+		r.skip(1)
+	}
+	if r.readFlag() { // video_signal_type_present_flag
+		r.skip(4)
+		if r.readFlag() {
+			r.skip(24)
+		}
+	}
+	if r.readFlag() { // chroma_loc_info_present_flag
+		r.readUE()
+		r.readUE()
+	}
+	if r.readFlag() { // timing_info_present_flag
+		numUnitsInTick := r.readBits(32)
+		timeScale := r.readBits(32)
+		fixed := r.readFlag()
+		if r.err == nil {
+			s.NumUnitsInTick, s.TimeScale, s.FixedFrameRate = numUnitsInTick, timeScale, fixed
+		}
+	}
+}
+
+// FrameRate returns the frame rate defined by the VUI timing information, 0 if unknown
+func (s *AvcSPS) FrameRate() float64 {
+	if s.NumUnitsInTick == 0 {
+		return 0
+	}
+	return float64(s.TimeScale) / float64(2*s.NumUnitsInTick)
+}
+
+// PixelAspectRatio returns the pixel aspect ratio (width / height), 1 if unknown
+func (s *AvcSPS) PixelAspectRatio() float64 {
+	if s.SarWidth == 0 || s.SarHeight == 0 {
+		return 1
+	}
+	return float64(s.SarWidth) / float64(s.SarHeight)
+}
@@ -0,0 +1,231 @@
+package mp4
+
+import (
+	"encoding/hex"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// avcSPS returns a SPS NAL unit from its RBSP
+func avcSPS(rbsp []byte) []byte {
+	return append([]byte{0x67}, escapeRBSP(rbsp)...)
+}
+
+// High profile, with scaling lists, cropping and VUI timing information
+func highSPS() []byte {
+	w := &bitWriter{}
+	w.bits(100, 8).bits(0, 8).bits(40, 8).ue(0)
+	w.ue(1).ue(0).ue(0).flag(false) // 4:2:0, 8 bits
+	w.flag(true)                    // seq_scaling_matrix_present_flag
+	// list 0 : 16 deltas
+	w.flag(true)
+	for i := 0; i < 16; i++ {
+		w.se(1)
+	}
+	// list 1 : absent, list 2 : default (the first delta gives 0)
+	w.flag(false).flag(true).se(-8)
+	w.flag(false).flag(false).flag(false)
+	// list 6 : 64 deltas
+	w.flag(true)
+	for i := 0; i < 64; i++ {
+		w.se(0)
+	}
+	w.flag(false)
+	w.ue(0).ue(0).ue(2).ue(4).flag(false) // frame num, pic order count, ref frames
+	w.ue(119).ue(67)                      // 120x68 macroblocks
+	w.flag(true).flag(true)               // frame_mbs_only_flag, direct_8x8_inference_flag
+	w.flag(true).ue(0).ue(0).ue(0).ue(4)  // cropping : 8 lines at the bottom
+	w.flag(true)                          // VUI
+	w.flag(true).bits(1, 8)               // aspect ratio 1:1
+	w.flag(false).flag(false).flag(false) // overscan, video signal, chroma location
+	w.flag(true).bits(1001, 32).bits(60000, 32).flag(true)
+	return avcSPS(w.rbsp())
+}
+
+// High 4:4:4 profile, 10 bits, interlaced, 12 scaling lists
+func high444SPS() []byte {
+	w := &bitWriter{}
+	w.bits(244, 8).bits(0, 8).bits(30, 8).ue(1)
+	w.ue(3).flag(false).ue(2).ue(2).flag(false) // 4:4:4, 10 bits
+	w.flag(true)
+	for i := 0; i < 12; i++ {
+		w.flag(i == 9)
+		if i == 9 {
+			for j := 0; j < 64; j++ {
+				w.se(0)
+			}
+		}
+	}
+	w.ue(0).ue(1).flag(false).se(-1).se(2).ue(2).se(1).se(-1) // pic order count type 1
+	w.ue(2).flag(false)
+	w.ue(44).ue(17)                      // 45x18 macroblocks, in field pairs
+	w.flag(false).flag(true).flag(true)  // frame_mbs_only_flag, mb_adaptive_frame_field_flag, direct_8x8
+	w.flag(true).ue(0).ue(0).ue(0).ue(1) // cropping : 2 lines at the bottom
+	w.flag(false)                        // no VUI
+	return avcSPS(w.rbsp())
+}
+
+// Baseline profile, with all the VUI fields before the timing information
+func baselineSPS() []byte {
+	w := &bitWriter{}
+	w.bits(66, 8).bits(0xc0, 8).bits(30, 8).ue(0)
+	w.ue(0).ue(2).ue(1).flag(false)
+	w.ue(19).ue(14).flag(true).flag(true).flag(false)
+	w.flag(true)
+	w.flag(true).bits(255, 8).bits(4, 16).bits(3, 16) // extended SAR 4:3
+	w.flag(true).flag(false)                          // overscan
+	w.flag(true).bits(5, 4).flag(true).bits(0x010101, 24)
+	w.flag(true).ue(0).ue(0)
+	w.flag(true).bits(1, 32).bits(50, 32).flag(false)
+	return avcSPS(w.rbsp())
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParseAvcSPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		nal       []byte
+		want      AvcSPS
+		frameRate float64
+		par       float64
+	}{
+		{
+			"dash sample", mustHex("674d4033f2007801b3f11808800000030080000018078c1889"),
+			AvcSPS{Profile: 77, ConstraintFlags: 0x40, Level: 51, ChromaFormat: 1, BitDepthLuma: 8, BitDepthChroma: 8,
+				FrameMbsOnly: true, Width: 3840, Height: 1714, SarWidth: 1, SarHeight: 1, NumUnitsInTick: 1, TimeScale: 48},
+			24, 1,
+		},
+		{
+			"hls sample", mustHex("67640032acd940780286ffc14000d644000003000400000300c03c60c658"),
+			AvcSPS{Profile: 100, Level: 50, ChromaFormat: 1, BitDepthLuma: 8, BitDepthChroma: 8,
+				FrameMbsOnly: true, Width: 1920, Height: 1280, SarWidth: 1280, SarHeight: 857, NumUnitsInTick: 1, TimeScale: 48},
+			24, 1280.0 / 857,
+		},
+		{
+			"scaling lists", highSPS(),
+			AvcSPS{Profile: 100, Level: 40, ChromaFormat: 1, BitDepthLuma: 8, BitDepthChroma: 8, FrameMbsOnly: true,
+				Width: 1920, Height: 1080, SarWidth: 1, SarHeight: 1, NumUnitsInTick: 1001, TimeScale: 60000, FixedFrameRate: true},
+			30000.0 / 1001, 1,
+		},
+		{
+			"high 4:4:4 interlaced", high444SPS(),
+			AvcSPS{Profile: 244, Level: 30, ID: 1, ChromaFormat: 3, BitDepthLuma: 10, BitDepthChroma: 10,
+				Width: 720, Height: 574},
+			0, 1,
+		},
+		{
+			"baseline", baselineSPS(),
+			AvcSPS{Profile: 66, ConstraintFlags: 0xc0, Level: 30, ChromaFormat: 1, BitDepthLuma: 8, BitDepthChroma: 8,
+				FrameMbsOnly: true, Width: 320, Height: 240, SarWidth: 4, SarHeight: 3, NumUnitsInTick: 1, TimeScale: 50},
+			25, 4.0 / 3,
+		},
+	}
+	for _, tt := range tests {
+		s, err := ParseAvcSPS(tt.nal)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*s, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *s, tt.want)
+		}
+		if r := s.FrameRate(); math.Abs(r-tt.frameRate) > 1e-9 {
+			t.Errorf("%s: frame rate %f, want %f", tt.name, r, tt.frameRate)
+		}
+		if r := s.PixelAspectRatio(); math.Abs(r-tt.par) > 1e-9 {
+			t.Errorf("%s: pixel aspect ratio %f, want %f", tt.name, r, tt.par)
+		}
+	}
+}
+
+func TestParseAvcSPSErrors(t *testing.T) {
+	sps := highSPS()
+	tests := []struct {
+		name string
+		nal  []byte
+		want error
+	}{
+		{"short", sps[:3], ErrTruncatedBody},
+		{"PPS", append([]byte{0x68}, sps[1:]...), ErrBadFormat},
+		{"truncated", sps[:20], ErrTruncatedBody},
+	}
+	for _, tt := range tests {
+		if _, err := ParseAvcSPS(tt.nal); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// avcC returns an avcC box holding a SPS and an empty PPS
+func avcC(profile, compatibility, level byte, sps []byte) []byte {
+	body := []byte{1, profile, compatibility, level, 0xff, 0xe1, byte(len(sps) >> 8), byte(len(sps))}
+	body = append(body, sps...)
+	body = append(body, 1, 0, 1, 0x68)
+	if isAvcHighProfile(profile) {
+		body = append(body, 0xfd, 0xf8, 0xf8, 0)
+	}
+	return append([]byte{0, 0, 0, byte(8 + len(body)), 'a', 'v', 'c', 'C'}, body...)
+}
+
+func TestAvcCCodec(t *testing.T) {
+	b := decodeBox(t, avcC(100, 0, 40, highSPS())).(*AvcCBox)
+	if c := b.Codec("avc1"); c != "avc1.640028" {
+		t.Errorf("codec %s", c)
+	}
+	if c := b.Codec("avc3"); c != "avc3.640028" {
+		t.Errorf("codec %s", c)
+	}
+	if !b.HasHighProfileFields || b.ChromaFormat != 1 || b.NALUnitLength() != 4 {
+		t.Errorf("got %+v", b)
+	}
+	b = decodeBox(t, avcC(66, 0xc0, 30, baselineSPS())).(*AvcCBox)
+	if c := b.Codec("avc1"); c != "avc1.42c01e" {
+		t.Errorf("codec %s", c)
+	}
+}
+
+func TestSampleCodecs(t *testing.T) {
+	tests := []struct {
+		file   string
+		codecs []string
+	}{
+		{"sample/meta.test1.mp4", []string{"avc1.640033", "mp4a.40.2"}},
+		{"sample/hls-init.mp4", []string{"avc1.640032", "mp4a.40.2"}},
+		{"sample/dash-init-stream0.m4s", []string{"avc1.4d4033"}},
+		{"sample/dash-init-stream3.m4s", []string{"mp4a.40.2"}},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		codecs := []string{}
+		for _, trak := range m.Moov.Trak {
+			for _, e := range trak.Mdia.Minf.Stbl.Stsd.Entries {
+				switch e := e.(type) {
+				case *VisualSampleEntry:
+					codecs = append(codecs, e.Codec())
+				case *AudioSampleEntry:
+					codecs = append(codecs, e.Codec())
+				}
+			}
+		}
+		if !reflect.DeepEqual(codecs, tt.codecs) {
+			t.Errorf("%s: codecs %v, want %v", tt.file, codecs, tt.codecs)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mp4
+
+// bitReader reads a bit stream (most significant bit first), as used by codec configurations
+// (H.264/HEVC parameter sets, AV1 OBUs, AAC AudioSpecificConfig, ...).
+//
+// Errors are sticky : once the end of the data has been reached, all reads return 0 and err is set.
+type bitReader struct {
+	data []byte
+	pos  int // position in bits
+	err  error
+}
+
+func newBitReader(data []byte) *bitReader {
+	return &bitReader{data: data}
+}
+
+// readBits reads n bits (n <= 32)
+func (r *bitReader) readBits(n int) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+n > len(r.data)*8 {
+		r.err = ErrTruncatedBody
+		return 0
+	}
+	var v uint32
+	for i := 0; i < n; i++ {
+		bit := (r.data[r.pos>>3] >> (7 - uint(r.pos&7))) & 1
+		v = v<<1 | uint32(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) readFlag() bool {
+	return r.readBits(1) == 1
+}
+
+func (r *bitReader) skip(n int) {
+	if r.err != nil {
+		return
+	}
+	if r.pos+n > len(r.data)*8 {
+		r.err = ErrTruncatedBody
+		return
+	}
+	r.pos += n
+}
+
+// readUE reads an unsigned Exp-Golomb code
+func (r *bitReader) readUE() uint32 {
+	zeros := 0
+	for !r.readFlag() {
+		if r.err != nil {
+			return 0
+		}
+		zeros++
+		if zeros > 31 {
+			r.err = ErrBadFormat
+			return 0
+		}
+	}
+	return (1<<uint(zeros) - 1) + r.readBits(zeros)
+}
+
+// readSE reads a signed Exp-Golomb code
+func (r *bitReader) readSE() int32 {
+	v := r.readUE()
+	if v&1 == 1 {
+		return int32((v + 1) / 2)
+	}
+	return -int32(v / 2)
+}
+
+// unescapeRBSP removes the emulation prevention bytes (0x000003) from a NAL unit
+func unescapeRBSP(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package mp4
+
+import (
+	"bytes"
+	"testing"
+)
+
+// bitWriter writes the bit streams of the codec configurations of the tests
+type bitWriter struct {
+	data []byte
+	n    int // of bits written
+}
+
+// bits writes the n lower bits of v
+func (w *bitWriter) bits(v uint64, n int) *bitWriter {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			w.data[len(w.data)-1] |= 1 << (7 - uint(w.n%8))
+		}
+		w.n++
+	}
+	return w
+}
+
+func (w *bitWriter) flag(f bool) *bitWriter {
+	if f {
+		return w.bits(1, 1)
+	}
+	return w.bits(0, 1)
+}
+
+// ue writes an unsigned Exp-Golomb code
+func (w *bitWriter) ue(v uint32) *bitWriter {
+	n := 0
+	for x := uint64(v) + 1; x > 1; x >>= 1 {
+		n++
+	}
+	return w.bits(0, n).bits(uint64(v)+1, n+1)
+}
+
+// se writes a signed Exp-Golomb code
+func (w *bitWriter) se(v int32) *bitWriter {
+	if v > 0 {
+		return w.ue(uint32(2*v - 1))
+	}
+	return w.ue(uint32(-2 * v))
+}
+
+// rbsp returns the data followed by the RBSP trailing bits
+func (w *bitWriter) rbsp() []byte {
+	w.bits(1, 1)
+	return w.data
+}
+
+// escapeRBSP inserts the emulation prevention bytes in a NAL unit payload
+func escapeRBSP(data []byte) []byte {
+	out := []byte{}
+	zeros := 0
+	for _, b := range data {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func TestBitReader(t *testing.T) {
+	w := &bitWriter{}
+	w.bits(5, 3).ue(0).ue(1).ue(254).se(0).se(1).se(-1).se(-300).bits(0xabcdef01, 32)
+	r := newBitReader(w.data)
+	if v := r.readBits(3); v != 5 {
+		t.Errorf("readBits = %d", v)
+	}
+	for _, want := range []uint32{0, 1, 254} {
+		if v := r.readUE(); v != want {
+			t.Errorf("readUE = %d, want %d", v, want)
+		}
+	}
+	for _, want := range []int32{0, 1, -1, -300} {
+		if v := r.readSE(); v != want {
+			t.Errorf("readSE = %d, want %d", v, want)
+		}
+	}
+	if v := r.readBits(32); v != 0xabcdef01 {
+		t.Errorf("readBits = %x", v)
+	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	r.readBits(8)
+	if r.err != ErrTruncatedBody || r.readBits(1) != 0 {
+		t.Errorf("read past the end: %v", r.err)
+	}
+}
+
+func TestUnescapeRBSP(t *testing.T) {
+	for _, data := range [][]byte{
+		{0, 0, 0, 1},
+		{0, 0, 3, 0, 0, 2, 0, 0},
+		{1, 2, 0, 0, 0, 0, 0, 0, 4},
+	} {
+		escaped := escapeRBSP(data)
+		if bytes.Contains(escaped, []byte{0, 0, 0}) || bytes.Contains(escaped, []byte{0, 0, 1}) {
+			t.Fatalf("escaped %x", escaped)
+		}
+		if got := unescapeRBSP(escaped); !bytes.Equal(got, data) {
+			t.Errorf("unescapeRBSP(%x) = %x, want %x", escaped, got, data)
+		}
+	}
+}
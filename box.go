@@ -6,11 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
+	"math"
 )
 
 const (
-	BoxHeaderSize = 8
+	BoxHeaderSize      = 8
+	LargeBoxHeaderSize = 16 // size 1, type, and 64 bits largesize
 )
 
 var (
@@ -44,6 +46,7 @@ func init() {
 		"mfhd": DecodeMfhd,
 		"mfra": DecodeMfra,
 		"mfro": DecodeMfro,
+		"mehd": DecodeMehd,
 		"mvex": DecodeMvex,
 		"hdlr": DecodeHdlr,
 		"vmhd": DecodeVmhd,
@@ -72,6 +75,35 @@ func init() {
 		"mdat": DecodeMdat,
 		"free": DecodeFree,
 		"sgpd": DecodeSgpd,
+		"avc1": DecodeVisualSampleEntry,
+		"avc3": DecodeVisualSampleEntry,
+		"hvc1": DecodeVisualSampleEntry,
+		"hev1": DecodeVisualSampleEntry,
+		"av01": DecodeVisualSampleEntry,
+		"vp08": DecodeVisualSampleEntry,
+		"vp09": DecodeVisualSampleEntry,
+		"mp4v": DecodeVisualSampleEntry,
+		"encv": DecodeVisualSampleEntry,
+		"mp4a": DecodeAudioSampleEntry,
+		"Opus": DecodeAudioSampleEntry,
+		"ac-3": DecodeAudioSampleEntry,
+		"ec-3": DecodeAudioSampleEntry,
+		"fLaC": DecodeAudioSampleEntry,
+		"enca": DecodeAudioSampleEntry,
+		"tx3g": DecodeTextSampleEntry,
+		"wvtt": DecodeTextSampleEntry,
+		"stpp": DecodeTextSampleEntry,
+		"stxt": DecodeTextSampleEntry,
+		"sbtt": DecodeTextSampleEntry,
+		"avcC": DecodeAvcC,
+		"hvcC": DecodeHvcC,
+		"av1C": DecodeAv1C,
+		"vpcC": DecodeVpcC,
+		"esds": DecodeEsds,
+		"dOps": DecodeDOps,
+		"dfLa": DecodeDfLa,
+		"dac3": DecodeDac3,
+		"dec3": DecodeDec3,
 	}
 }
 
@@ -80,6 +112,7 @@ type BoxHeader struct {
 	Type      string
 	Size      uint32
 	LargeSize uint64
+	UserType  [16]byte `json:"-"` // extended type of the uuid boxes, read by DecodeBox
 }
 
 type FullBox struct {
@@ -104,48 +137,63 @@ func EncodeFullBox(b FullBox, buf []byte) error {
 	return nil
 }
 
-// DecodeHeader decodes a box header (size + box type)
+// DecodeHeader decodes a box header (size + box type). A size of 0 means that the box extends to the end of
+// its container, or of the media (see DecodeBox).
 func DecodeHeader(r io.Reader) (BoxHeader, error) {
 	buf := make([]byte, BoxHeaderSize)
-	n, err := r.Read(buf)
-	if err != nil {
+	_, err := io.ReadFull(r, buf)
+	if err == io.EOF {
 		return BoxHeader{}, err
 	}
-	if n != BoxHeaderSize {
+	if err != nil {
 		return BoxHeader{}, ErrTruncatedHeader
 	}
-	var lsz uint64
-	sz := binary.BigEndian.Uint32(buf[0:4])
-	if sz == 0 {
-		secBuf := &bytes.Buffer{}
-		nRead, err := io.Copy(secBuf, r)
+	h := BoxHeader{Type: string(buf[4:8]), Size: binary.BigEndian.Uint32(buf[0:4])}
+	if h.Size == 1 {
+		_, err = io.ReadFull(r, buf)
 		if err != nil {
 			return BoxHeader{}, ErrTruncatedHeader
 		}
-		sz = uint32(nRead)
-	} else if sz == 1 {
-		secBuf := make([]byte, BoxHeaderSize)
-		n, err := r.Read(secBuf)
-		if err != nil {
-			return BoxHeader{}, err
-		}
-		if n != BoxHeaderSize {
-			return BoxHeader{}, ErrTruncatedHeader
-		}
-		lsz = binary.BigEndian.Uint64(secBuf)
+		h.LargeSize = binary.BigEndian.Uint64(buf)
 	}
-	return BoxHeader{string(buf[4:8]), sz, lsz}, nil
+	return h, nil
+}
+
+// largeBox is implemented by the boxes that keep the 64 bits largesize of their header, even when their
+// size fits in 32 bits
+type largeBox interface {
+	largeHeader() bool
 }
 
-// EncodeHeader encodes a box header to a writer
+// EncodeHeader encodes a box header to a writer. The header of the boxes larger than 4 GiB uses a 64 bits
+// largesize, that must be counted in the size of the box (see sizeWithHeader).
 func EncodeHeader(b Box, w io.Writer) error {
+	size := b.Size()
+	l, ok := b.(largeBox)
+	if (ok && l.largeHeader()) || size > math.MaxUint32 {
+		buf := make([]byte, LargeBoxHeaderSize)
+		binary.BigEndian.PutUint32(buf, 1)
+		strtobuf(buf[4:], b.Type(), 4)
+		binary.BigEndian.PutUint64(buf[8:], uint64(size))
+		_, err := w.Write(buf)
+		return err
+	}
 	buf := make([]byte, BoxHeaderSize)
-	binary.BigEndian.PutUint32(buf, uint32(b.Size()))
+	binary.BigEndian.PutUint32(buf, uint32(size))
 	strtobuf(buf[4:], b.Type(), 4)
 	_, err := w.Write(buf)
 	return err
 }
 
+// sizeWithHeader returns the size of a box with a body of the given size, the header using a 64 bits
+// largesize if large is set or if the box does not fit in 32 bits
+func sizeWithHeader(large bool, bodySize uint64) int {
+	if large || bodySize+BoxHeaderSize > math.MaxUint32 {
+		return LargeBoxHeaderSize + int(bodySize)
+	}
+	return BoxHeaderSize + int(bodySize)
+}
+
 // A box
 type Box interface {
 	Box() Box
@@ -157,46 +205,190 @@ type Box interface {
 
 type BoxDecoder func(h BoxHeader, r io.Reader) (Box, error)
 
-// DecodeBox decodes a box
+// DecodeBox decodes a box, using the decoders of the library or the ones registered by the application
+// (see RegisterBox). Unknown boxes are decoded as UkwnBox.
+//
+// The errors are returned as *DecodeError, with the path and the offset of the box.
 func DecodeBox(h BoxHeader, r io.Reader) (Box, error) {
-	d := decoders[h.Type]
+	offset := position(r) - h.headerSize()
+	path := childPath(r, h.Type)
+	logger := Logger()
+	logger.Debug("decoding box", "type", h.Type, "path", path, "offset", offset, "size", h.boxSize())
+	fail := func(err error) (Box, error) {
+		return nil, &DecodeError{Path: path, Offset: offset, Err: err}
+	}
+	unbounded := false
+	if h.Size == 0 {
+		if br, ok := r.(*boxReader); ok && br.depth > 0 && decodeMode(r) == DecodeStrict {
+			// only the last top-level box can extend to the end of the media
+			return fail(ErrBadFormat)
+		}
+		// the box extends to the end of its container, or of the media
+		var err error
+		h, unbounded, err = sizeToEnd(h, r)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	// the size of the body, to the end of the media if unknown
+	readSize := int64(rootSize)
+	if !unbounded {
+		readSize = h.boxSize() - h.headerSize()
+		if readSize < 0 {
+			return fail(ErrBadFormat)
+		}
+		if p, ok := r.(*boxReader); ok && !p.unbounded && readSize > p.N {
+			// larger than its container
+			return fail(ErrBadFormat)
+		}
+	}
+	body := newBoxReader(r, h.Type, path, offset+h.headerSize(), readSize)
+	body.unbounded = unbounded
+	if max := body.limits.MaxDepth; max > 0 && body.depth > max {
+		return fail(ErrLimitExceeded)
+	}
+	var br io.Reader = body
+	var d BoxDecoder
+	if h.Type == "uuid" {
+		_, err := io.ReadFull(body, h.UserType[:])
+		if err != nil {
+			return fail(ErrTruncatedHeader)
+		}
+		d = uuidDecoder(h.UserType)
+		if d == nil {
+			// the user type is kept in the data of the unknown box
+			br = io.MultiReader(bytes.NewReader(h.UserType[:]), body)
+		}
+	} else {
+		d = decoder(parentType(r), h.Type)
+	}
 	if d == nil {
-		log.Printf("Error while decoding %s: unknown box type, len: %d", h.Type, h.Size)
+		logger.Warn("unknown box type", "type", h.Type, "path", path, "offset", offset, "size", h.boxSize())
 		d = DecodeUkwnBox
 	}
-	var readSize int64
-	readSize = int64(h.Size - BoxHeaderSize)
-	if h.Size == 1 {
-		readSize = int64(h.LargeSize - (BoxHeaderSize * 2))
+	b, err := d(h, br)
+	if err == nil && decoders[h.Type] == nil {
+		// the data not read by a registered decoder is skipped
+		_, err = io.Copy(ioutil.Discard, body)
+	}
+	if err == nil && body.decoding != nil && body.decoding.mode == DecodeStrict {
+		err = checkStrict(h, b, body)
 	}
-	b, err := d(h, io.LimitReader(r, readSize))
 	if err != nil {
-		log.Printf("Error while decoding %v:%s", h, err)
-		return nil, err
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			// not already returned by a box contained in this one
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = ErrTruncatedBody
+			}
+			de = &DecodeError{Path: path, Offset: offset, Err: err}
+		}
+		logger.Debug("decoding error", "type", h.Type, "path", path, "offset", offset, "error", de.Err)
+		return nil, de
 	}
 	return b, nil
 }
 
+// boxSize returns the size of the box, header included
+func (h BoxHeader) boxSize() int64 {
+	if h.Size == 1 {
+		return int64(h.LargeSize)
+	}
+	return int64(h.Size)
+}
+
+// sizeToEnd sets the size of a box extending to the end of its container, or of the media read by r. The
+// size is left to 0 if the size of the media is unknown (non-seekable readers).
+func sizeToEnd(h BoxHeader, r io.Reader) (BoxHeader, bool, error) {
+	size := int64(-1) // of the body
+	if br, ok := r.(*boxReader); ok && !br.unbounded {
+		size = br.N
+	} else if s, ok := sourceReader(r).(io.Seeker); ok {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return h, false, err
+		}
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return h, false, err
+		}
+		_, err = s.Seek(pos, io.SeekStart)
+		if err != nil {
+			return h, false, err
+		}
+		size = end - pos
+	}
+	if size < 0 {
+		return h, true, nil
+	}
+	if size+BoxHeaderSize > int64(^uint32(0)) {
+		h.Size, h.LargeSize = 1, uint64(size)+BoxHeaderSize*2
+	} else {
+		h.Size = uint32(size) + BoxHeaderSize
+	}
+	return h, false, nil
+}
+
+// headerSize returns the size of the header, the user type of the uuid boxes excluded
+func (h BoxHeader) headerSize() int64 {
+	if h.Size == 1 {
+		return BoxHeaderSize * 2
+	}
+	return BoxHeaderSize
+}
+
 // DecodeContainer decodes a container box
 func DecodeContainer(r io.Reader) ([]Box, error) {
+	l, _, err := decodeContainer(r)
+	return l, err
+}
+
+// decodeContainer decodes a container box, and returns the offset of each box from the beginning of r
+func decodeContainer(r io.Reader) ([]Box, []int64, error) {
 	l := []Box{}
+	offsets := []int64{}
+	var off int64
 	for {
+		pos := position(r)
 		h, err := DecodeHeader(r)
+		br, isBox := r.(*boxReader)
+		if err == io.EOF && isBox && !br.unbounded && br.N > 0 {
+			// end of the media before the end of the container
+			err = ErrTruncatedBody
+		}
 		if err == io.EOF {
-			return l, nil
+			return l, offsets, nil
 		}
-		log.Printf("Decode header, %v\n", h)
+		lenient := isBox && decodeMode(r) == DecodeLenient
 		if err != nil {
-			log.Printf("Decode header fail, %s:%v\n", err, h)
-			return l, err
+			var path string
+			if isBox {
+				path = br.path
+			}
+			err = &DecodeError{Path: path, Offset: pos, Err: err}
+			if lenient {
+				// the boxes that follow cannot be found
+				warn(br, err)
+				return l, offsets, nil
+			}
+			return l, offsets, err
+		}
+		var b Box
+		if lenient {
+			b, err = decodeBoxLenient(h, br)
+		} else {
+			b, err = DecodeBox(h, r)
 		}
-		b, err := DecodeBox(h, r)
 		if err != nil {
-			log.Printf("Decode Box fail, %s:%v\n", err, h)
-			return l, err
+			if lenient {
+				warn(br, err)
+				return l, offsets, nil
+			}
+			return l, offsets, err
 		}
-		log.Printf("Decode box, %v\n", h)
 		l = append(l, b)
+		offsets = append(offsets, off)
+		off += h.boxSize()
 	}
 }
 
@@ -239,6 +431,13 @@ func strtobuf(out []byte, str string, l int) {
 	}
 }
 
+// fourCC returns the four character code stored in v (e.g. a grouping type)
+func fourCC(v uint32) string {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return string(buf)
+}
+
 func makebuf(b Box) []byte {
 	return make([]byte, b.Size()-BoxHeaderSize)
 }
@@ -255,6 +454,93 @@ func BEPutUint28(b []byte, v uint32) {
 	b[2] = byte(v)
 }
 
+// beUintN reads a n bytes (1 to 4) big endian unsigned integer
+func beUintN(b []byte, n uint8) uint32 {
+	var v uint32
+	for i := 0; i < int(n); i++ {
+		v = v<<8 | uint32(b[i])
+	}
+	return v
+}
+
+// bePutUintN writes v as a n bytes (1 to 4) big endian unsigned integer
+func bePutUintN(b []byte, n uint8, v uint32) {
+	for i := int(n) - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// fullBoxVersion returns the version used to encode a box storing values on 32 bits in version 0 and on
+// 64 bits in version 1 : version 1 when a value does not fit in 32 bits, whatever the version set
+func fullBoxVersion(version byte, values ...uint64) byte {
+	if version != 0 {
+		return version
+	}
+	for _, v := range values {
+		if v > math.MaxUint32 {
+			return 1
+		}
+	}
+	return 0
+}
+
 func compareFlag(flag uint32, bitmask uint32) bool {
 	return flag&bitmask == bitmask
 }
+
+// boxTypes returns the types of the boxes, recorded by the containers when decoded to encode their boxes
+// in the same order (see orderedBoxes)
+func boxTypes(l []Box) []string {
+	types := make([]string, 0, len(l))
+	for _, b := range l {
+		types = append(types, b.Type())
+	}
+	return types
+}
+
+// orderedBoxes returns the boxes l in the order in which they were decoded (order). The boxes added since,
+// or which types were not decoded, are returned last.
+func orderedBoxes(order []string, l []Box) []Box {
+	if len(order) == 0 {
+		return l
+	}
+	byType := map[string][]Box{}
+	for _, b := range l {
+		byType[b.Type()] = append(byType[b.Type()], b)
+	}
+	sorted := make([]Box, 0, len(l))
+	for _, t := range order {
+		if len(byType[t]) > 0 {
+			sorted = append(sorted, byType[t][0])
+			byType[t] = byType[t][1:]
+		}
+	}
+	for _, b := range l {
+		if len(byType[b.Type()]) > 0 && byType[b.Type()][0] == b {
+			sorted = append(sorted, b)
+			byType[b.Type()] = byType[b.Type()][1:]
+		}
+	}
+	return sorted
+}
+
+// boxesSize returns the size of the boxes
+func boxesSize(l []Box) int {
+	sz := 0
+	for _, b := range l {
+		sz += b.Size()
+	}
+	return sz
+}
+
+// encodeBoxes encodes the boxes to a writer
+func encodeBoxes(l []Box, w io.Writer) error {
+	for _, b := range l {
+		err := b.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
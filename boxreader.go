@@ -0,0 +1,151 @@
+package mp4
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// boxReader reads the body of a box, and gives its context to the decoders of the boxes it contains : type
+// (see decoder), path and offset (see DecodeError)
+type boxReader struct {
+	*io.LimitedReader
+	boxType   string
+	path      string
+	offset    int64          // of the body, from the beginning of the media
+	size      int64          // of the body
+	unbounded bool           // the body extends to the end of a media of unknown size
+	counts    map[string]int // of the children read, by type
+	depth     int            // 0 for the top-level reader
+	limits    *Limits
+	decoding  *decoding
+}
+
+// newBoxReader returns a reader of the body of a box, read from r
+func newBoxReader(r io.Reader, boxType, path string, offset, size int64) *boxReader {
+	br := &boxReader{
+		LimitedReader: &io.LimitedReader{R: r, N: size},
+		boxType:       boxType,
+		path:          path,
+		offset:        offset,
+		size:          size,
+	}
+	if p, ok := r.(*boxReader); ok {
+		br.depth, br.limits, br.decoding = p.depth+1, p.limits, p.decoding
+	} else {
+		br.limits = limits.Load()
+	}
+	return br
+}
+
+// size of the body of the rootReader
+const rootSize = math.MaxInt64
+
+// rootReader returns the reader of the top-level boxes of a media
+func rootReader(r io.Reader) *boxReader {
+	br := newBoxReader(r, "", "", 0, rootSize)
+	br.unbounded = true
+	return br
+}
+
+// bodySize returns the size of the body of a box left to read from r, and whether the body extends to the end
+// of a media of unknown size
+func bodySize(h BoxHeader, r io.Reader) (int64, bool) {
+	if br, ok := r.(*boxReader); ok {
+		return br.N, br.unbounded
+	}
+	return h.boxSize() - h.headerSize(), h.Size == 0
+}
+
+// parentType returns the type of the box read by r, "" for the top-level reader
+func parentType(r io.Reader) string {
+	if br, ok := r.(*boxReader); ok {
+		return br.boxType
+	}
+	return ""
+}
+
+// position returns the offset of the next byte read from r, from the beginning of the media
+func position(r io.Reader) int64 {
+	if br, ok := r.(*boxReader); ok {
+		return br.offset + br.size - br.N
+	}
+	return 0
+}
+
+// childPath returns the path of the next box of type boxType read from r, the index of the box being
+// given if it is not the first one of its type
+func childPath(r io.Reader, boxType string) string {
+	var path string
+	var n int
+	if br, ok := r.(*boxReader); ok {
+		if br.counts == nil {
+			br.counts = map[string]int{}
+		}
+		path, n = br.path, br.counts[boxType]
+		br.counts[boxType]++
+	}
+	return boxPath(path, boxType, n)
+}
+
+// boxPath returns the path of the box n (starting at 0) of type boxType contained in the box at path
+func boxPath(path, boxType string, n int) string {
+	if path != "" {
+		path += "/"
+	}
+	if n > 0 {
+		return fmt.Sprintf("%s%s[%d]", path, boxType, n)
+	}
+	return path + boxType
+}
+
+// childReader returns a reader over data, the boxes contained in the box read by r (for boxes that decode
+// their body before their children, data being the end of the body)
+func childReader(r io.Reader, data []byte) *boxReader {
+	br, ok := r.(*boxReader)
+	if !ok {
+		return newBoxReader(bytes.NewReader(data), "", "", 0, int64(len(data)))
+	}
+	offset := position(r) - int64(len(data))
+	c := newBoxReader(bytes.NewReader(data), br.boxType, br.path, offset, int64(len(data)))
+	c.depth, c.limits, c.decoding = br.depth, br.limits, br.decoding
+	return c
+}
+
+// siblingReader returns a reader over data, the body of the next box read by r, read in memory at offset pos
+func siblingReader(r *boxReader, data []byte, pos int64) *boxReader {
+	if r.counts == nil {
+		r.counts = map[string]int{}
+	}
+	return &boxReader{
+		LimitedReader: &io.LimitedReader{R: bytes.NewReader(data), N: int64(len(data))},
+		boxType:       r.boxType,
+		path:          r.path,
+		offset:        pos,
+		size:          int64(len(data)),
+		counts:        r.counts,
+		depth:         r.depth,
+		limits:        r.limits,
+		decoding:      r.decoding,
+	}
+}
+
+// skipped records that the next n bytes read by r were skipped by seeking the source of the media, for the
+// positions of the following boxes
+func skipped(r io.Reader, n int64) {
+	for br, ok := r.(*boxReader); ok; br, ok = br.R.(*boxReader) {
+		br.N -= n
+	}
+}
+
+// sourceReader returns the reader of the media, under the boxReaders
+func sourceReader(r io.Reader) io.Reader {
+	for {
+		br, ok := r.(*boxReader)
+		if !ok {
+			return r
+		}
+		r = br.R
+	}
+}
@@ -16,11 +16,13 @@ type BxmlBox struct {
 }
 
 func DecodeBxml(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 4 {
+		return nil, ErrBadFormat
+	}
 	return &BxmlBox{
 		Version: data[0],
 		Flags:   [3]byte{data[1], data[2], data[3]},
@@ -3,27 +3,48 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
 	cli "github.com/jawher/mow.cli"
 	"github.com/otherplace/mp4"
 	"github.com/otherplace/mp4/filter"
+	"github.com/otherplace/mp4/fragment"
+	"github.com/otherplace/mp4/validate"
 )
 
 func main() {
 	cmd := cli.App("mp4tool", "MP4 command line tool")
+	verbose := cmd.BoolOpt("v verbose", false, "log the decoding of the boxes")
+	cmd.Before = func() {
+		if *verbose {
+			mp4.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		}
+	}
 
 	cmd.Command("info", "Displays information about a media", func(cmd *cli.Cmd) {
 		file := cmd.StringArg("FILE", "", "the file to display")
 		isPlain := cmd.BoolOpt("t text", false, "display as plain text")
 		isPretty := cmd.BoolOpt("p pretty", false, "Pretty print JSON")
+		strict := cmd.BoolOpt("strict", false, "fail on any violation of the specification")
+		lenient := cmd.BoolOpt("l lenient", false, "keep the boxes that cannot be decoded, and continue")
 		cmd.Action = func() {
 			fd, err := os.Open(*file)
 			defer fd.Close()
-			v, err := mp4.Decode(fd)
+			opts := mp4.DecodeOptions{}
+			if *strict {
+				opts.Mode = mp4.DecodeStrict
+			} else if *lenient {
+				opts.Mode = mp4.DecodeLenient
+			}
+			v, err := mp4.DecodeWithOptions(fd, opts)
 			if err != nil {
-				panic(err)
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			for _, w := range v.Warnings {
+				fmt.Fprintln(os.Stderr, "warning:", w)
 			}
 			if *isPlain {
 				v.Dump()
@@ -85,8 +106,148 @@ func main() {
 				fmt.Println(err)
 			}
 			defer out.Close()
-			v.Encode(out)
+			err = v.Encode(out)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	})
+	cmd.Command("faststart", "Moves the moov box in front of the media data, for progressive playback", func(cmd *cli.Cmd) {
+		src := cmd.StringArg("SRC", "", "the source file name")
+		dst := cmd.StringArg("DST", "", "the destination file name")
+		cmd.Action = func() {
+			v := decodeFile(*src, mp4.DecodeOptions{}, 1)
+			create(*dst, func(out *os.File) error { return filter.EncodeFiltered(out, v, filter.Faststart()) })
+		}
+	})
+	cmd.Command("fragment", "Converts a media into a fragmented media, as a single file or as an init segment and media segments", func(cmd *cli.Cmd) {
+		cmd.Spec = "[-d] [-s] SRC DST"
+		duration := cmd.IntOpt("d duration", 2, "target duration of the fragments (sec)")
+		segments := cmd.StringOpt("s segments", "", "name of the media segments, e.g. seg-%d.m4s (numbered from 1), DST being the init segment")
+		src := cmd.StringArg("SRC", "", "the source file name")
+		dst := cmd.StringArg("DST", "", "the destination file name")
+		cmd.Action = func() {
+			v := decodeFile(*src, mp4.DecodeOptions{}, 1)
+			f, err := fragment.New(v, fragment.Options{Duration: time.Duration(*duration) * time.Second})
+			if err != nil {
+				fail(err, 1)
+			}
+			if *segments == "" {
+				create(*dst, func(out *os.File) error { return f.Encode(out) })
+				return
+			}
+			create(*dst, func(out *os.File) error { return f.EncodeInit(out) })
+			for i := 0; i < f.Count(); i++ {
+				create(fmt.Sprintf(*segments, i+1), func(out *os.File) error { return f.EncodeSegment(out, i) })
+			}
+		}
+	})
+	cmd.Command("defragment", "Converts a fragmented media, or an init segment and its media segments, into a progressive media", func(cmd *cli.Cmd) {
+		cmd.Spec = "SRC [SEGMENTS...] DST"
+		src := cmd.StringArg("SRC", "", "the fragmented media, or the init segment")
+		segments := cmd.StringsArg("SEGMENTS", nil, "the media segments")
+		dst := cmd.StringArg("DST", "", "the destination file name")
+		cmd.Action = func() {
+			init, l := decodeSegments(*src, *segments, mp4.DecodeOptions{}, 1)
+			v, err := fragment.Defragment(init, l...)
+			if err != nil {
+				fail(err, 1)
+			}
+			create(*dst, func(out *os.File) error { return v.Encode(out) })
+		}
+	})
+	cmd.Command("concat-segments", "Joins an init segment and its media segments into a single fragmented media", func(cmd *cli.Cmd) {
+		cmd.Spec = "[-i] [-g] INIT SEGMENTS... DST"
+		index := cmd.BoolOpt("i index", false, "add a mfra box indexing the fragments")
+		gaps := cmd.BoolOpt("g gaps", false, "accept the gaps between the decode times of the fragments")
+		src := cmd.StringArg("INIT", "", "the init segment")
+		segments := cmd.StringsArg("SEGMENTS", nil, "the media segments")
+		dst := cmd.StringArg("DST", "", "the destination file name")
+		cmd.Action = func() {
+			init, l := decodeSegments(*src, *segments, mp4.DecodeOptions{}, 1)
+			out, err := os.Create(*dst)
+			if err != nil {
+				fail(err, 1)
+			}
+			err = fragment.Concat(out, init, l, fragment.ConcatOptions{AllowGaps: *gaps, Index: *index})
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(*dst)
+				fail(err, 1)
+			}
+		}
+	})
+	cmd.Command("validate", "Checks the consistency of a media, or of an init segment and its media segments", func(cmd *cli.Cmd) {
+		cmd.Spec = "[-j] [-w] FILE [SEGMENTS...]"
+		isJSON := cmd.BoolOpt("j json", false, "output the issues as JSON")
+		warnings := cmd.BoolOpt("w warnings", false, "fail on warnings too")
+		file := cmd.StringArg("FILE", "", "the media, or the init segment")
+		segments := cmd.StringsArg("SEGMENTS", nil, "the media segments")
+		cmd.Action = func() {
+			// exits with 2 if the media cannot be decoded, 1 on the issues
+			init, l := decodeSegments(*file, *segments, mp4.DecodeOptions{Mode: mp4.DecodeLenient}, 2)
+			report := validate.Segments(init, l...)
+			if *isJSON {
+				jsonBytes, err := json.MarshalIndent(report, "", "\t")
+				if err != nil {
+					panic(err)
+				}
+				fmt.Println(string(jsonBytes))
+			} else {
+				fmt.Print(report)
+				fmt.Printf("%d errors, %d warnings\n", report.Count(validate.Error), report.Count(validate.Warning))
+			}
+			if report.Count(validate.Error) > 0 || (*warnings && report.Count(validate.Warning) > 0) {
+				os.Exit(1)
+			}
 		}
 	})
 	cmd.Run(os.Args)
 }
+
+// fail prints an error and exits with the given code
+func fail(err error, code int) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(code)
+}
+
+// decodeFile decodes a media file, failing with code if it cannot be read. The file is closed on exit, the
+// mdat boxes referencing it.
+func decodeFile(name string, opts mp4.DecodeOptions, code int) *mp4.MP4 {
+	fd, err := os.Open(name)
+	if err != nil {
+		fail(err, code)
+	}
+	v, err := mp4.DecodeWithOptions(fd, opts)
+	if err != nil {
+		fail(err, code)
+	}
+	return v
+}
+
+// decodeSegments decodes an init segment (or a single file) and its media segments (see decodeFile)
+func decodeSegments(init string, segments []string, opts mp4.DecodeOptions, code int) (*mp4.MP4, []*mp4.MP4) {
+	m := decodeFile(init, opts, code)
+	l := []*mp4.MP4{}
+	for _, s := range segments {
+		l = append(l, decodeFile(s, opts, code))
+	}
+	return m, l
+}
+
+// create creates a file and encodes it, failing on error
+func create(name string, encode func(*os.File) error) {
+	out, err := os.Create(name)
+	if err != nil {
+		fail(err, 1)
+	}
+	err = encode(out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fail(err, 1)
+	}
+}
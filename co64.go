@@ -6,11 +6,14 @@ import (
 	"io"
 )
 
-// Box Type: ‘co64’
-// Container: Sample Table Box (‘stbl’)
-// Mandatory: Yes
-// Quantity: Exactly one variant must be present
-
+// Chunk Large Offset Box (co64 - mandatory if there is no stco box)
+//
+// Contained in : Sample Table box (stbl)
+//
+// Status: decoded
+//
+// This is the 64bits version of the stco box, used when the media is larger than 4 GiB (see
+// StblBox.SetChunkOffsets). EntryCount is updated on encoding.
 type Co64Box struct {
 	FullBox
 	EntryCount  uint32
@@ -18,16 +21,21 @@ type Co64Box struct {
 }
 
 func DecodeCo64(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	b := &Co64Box{
 		FullBox:     DecodeFullBox(data[0:4]),
 		EntryCount:  binary.BigEndian.Uint32(data[4:8]),
 		ChunkOffset: []uint64{},
 	}
+	if err = checkEntries(r, uint64(b.EntryCount), 8, 8, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(b.EntryCount); i++ {
 		chunk := binary.BigEndian.Uint64(data[(8 + 8*i):(16 + 8*i)])
 		b.ChunkOffset = append(b.ChunkOffset, chunk)
@@ -44,7 +52,7 @@ func (b *Co64Box) Type() string {
 }
 
 func (b *Co64Box) Size() int {
-	return BoxHeaderSize + 8 + len(b.ChunkOffset)*4
+	return BoxHeaderSize + 8 + len(b.ChunkOffset)*8
 }
 
 func (b *Co64Box) Dump() {
@@ -61,9 +69,10 @@ func (b *Co64Box) Encode(w io.Writer) error {
 	}
 	buf := makebuf(b)
 	EncodeFullBox(b.FullBox, buf)
+	b.EntryCount = uint32(len(b.ChunkOffset))
 	binary.BigEndian.PutUint32(buf[4:], b.EntryCount)
 	for i := range b.ChunkOffset {
-		binary.BigEndian.PutUint64(buf[12+8*i:], b.ChunkOffset[i])
+		binary.BigEndian.PutUint64(buf[8+8*i:], b.ChunkOffset[i])
 	}
 	_, err = w.Write(buf)
 	return err
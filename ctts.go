@@ -10,32 +10,40 @@ import (
 //
 // Contained in: Sample Table Box (stbl)
 //
-// Status: version 0 decoded. version 1 uses int32 for offsets
+// Status: decoded
+//
+// Version 0 defines unsigned offsets, version 1 signed offsets. Offsets are stored as int32 for both versions,
+// as version 0 offsets larger than 2^31 are not used in practice.
 type CttsBox struct {
 	Version      byte
 	Flags        [3]byte
 	EntryCount   uint32
 	SampleCount  []uint32
-	SampleOffset []uint32 // int32 for version 1
+	SampleOffset []int32
 }
 
 func DecodeCtts(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	b := &CttsBox{
 		Version:      data[0],
 		Flags:        [3]byte{data[1], data[2], data[3]},
 		SampleCount:  []uint32{},
-		SampleOffset: []uint32{},
+		SampleOffset: []int32{},
 	}
 	ec := binary.BigEndian.Uint32(data[4:8])
+	if err = checkEntries(r, uint64(ec), 8, 8, data); err != nil {
+		return nil, err
+	}
 	b.EntryCount = ec
 	for i := 0; i < int(ec); i++ {
 		s_count := binary.BigEndian.Uint32(data[(8 + 8*i):(12 + 8*i)])
-		s_offset := binary.BigEndian.Uint32(data[(12 + 8*i):(16 + 8*i)])
+		s_offset := int32(binary.BigEndian.Uint32(data[(12 + 8*i):(16 + 8*i)]))
 		b.SampleCount = append(b.SampleCount, s_count)
 		b.SampleOffset = append(b.SampleOffset, s_offset)
 	}
@@ -61,10 +69,10 @@ func (b *CttsBox) Encode(w io.Writer) error {
 	buf := makebuf(b)
 	buf[0] = b.Version
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	binary.BigEndian.PutUint32(buf[4:], b.EntryCount)
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(b.SampleCount)))
 	for i := range b.SampleCount {
 		binary.BigEndian.PutUint32(buf[8+8*i:], b.SampleCount[i])
-		binary.BigEndian.PutUint32(buf[12+8*i:], b.SampleOffset[i])
+		binary.BigEndian.PutUint32(buf[12+8*i:], uint32(b.SampleOffset[i]))
 	}
 	_, err = w.Write(buf)
 	return err
@@ -72,8 +80,8 @@ func (b *CttsBox) Encode(w io.Writer) error {
 
 func (b *CttsBox) Dump() {
 	fmt.Printf("Composition Time to Sample Box\n")
-	fmt.Printf("+- Entry Count: %d\n", b.EntryCount)
-	for i := 0; i < int(b.EntryCount); i++ {
+	fmt.Printf("+- Entry Count: %d\n", len(b.SampleCount))
+	for i := range b.SampleCount {
 		fmt.Printf(" +- #%d Sample Count: %d\n", i, b.SampleCount[i])
 		fmt.Printf(" +- #%d Sample Offset: %d\n", i, b.SampleOffset[i])
 	}
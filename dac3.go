@@ -0,0 +1,112 @@
+package mp4
+
+import (
+	"fmt"
+	"io"
+)
+
+// Sample rates defined by fscod
+var ac3SampleRates = []uint32{48000, 44100, 32000}
+
+// Number of full bandwidth channels defined by acmod
+var ac3Channels = []int{2, 1, 2, 3, 3, 4, 4, 5}
+
+// Bitrates (in kbit/s) defined by bit_rate_code
+var ac3Bitrates = []uint32{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+
+// AC-3 Specific Box (dac3 - mandatory for AC-3 audio tracks)
+//
+// Contained in : Audio Sample Entry (ac-3)
+//
+// Status: decoded
+//
+// Contains the AC-3 stream parameters (ETSI TS 102 366, F.4) : sample rate code, bit stream mode,
+// audio coding mode (channel layout), LFE channel and bitrate code.
+type Dac3Box struct {
+	Fscod       byte
+	Bsid        byte
+	Bsmod       byte
+	Acmod       byte
+	LFEOn       bool
+	BitRateCode byte
+	reserved    byte
+}
+
+func DecodeDac3(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 3 {
+		return nil, ErrTruncatedBody
+	}
+	return &Dac3Box{
+		Fscod:       data[0] >> 6,
+		Bsid:        (data[0] >> 1) & 0x1f,
+		Bsmod:       (data[0]&0x01)<<2 | data[1]>>6,
+		Acmod:       (data[1] >> 3) & 0x07,
+		LFEOn:       data[1]&0x04 != 0,
+		BitRateCode: (data[1]&0x03)<<3 | data[2]>>5,
+		reserved:    data[2] & 0x1f,
+	}, nil
+}
+
+func (b *Dac3Box) Box() Box {
+	return b
+}
+
+func (b *Dac3Box) Type() string {
+	return "dac3"
+}
+
+func (b *Dac3Box) Size() int {
+	return BoxHeaderSize + 3
+}
+
+// SampleRate returns the sample rate in Hz, 0 if fscod is reserved
+func (b *Dac3Box) SampleRate() uint32 {
+	if int(b.Fscod) < len(ac3SampleRates) {
+		return ac3SampleRates[b.Fscod]
+	}
+	return 0
+}
+
+// Channels returns the number of channels, including the LFE channel
+func (b *Dac3Box) Channels() int {
+	n := ac3Channels[b.Acmod&0x07]
+	if b.LFEOn {
+		n++
+	}
+	return n
+}
+
+// Bitrate returns the bitrate in bit/s, 0 if bit_rate_code is unknown
+func (b *Dac3Box) Bitrate() uint32 {
+	if int(b.BitRateCode) < len(ac3Bitrates) {
+		return ac3Bitrates[b.BitRateCode] * 1000
+	}
+	return 0
+}
+
+func (b *Dac3Box) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.Fscod<<6 | (b.Bsid&0x1f)<<1 | (b.Bsmod>>2)&0x01
+	buf[1] = b.Bsmod<<6 | (b.Acmod&0x07)<<3 | (b.BitRateCode>>3)&0x03
+	if b.LFEOn {
+		buf[1] |= 0x04
+	}
+	buf[2] = b.BitRateCode<<5 | b.reserved&0x1f
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *Dac3Box) Dump() {
+	fmt.Printf("AC-3 Specific Box\n")
+	fmt.Printf("+- Sample rate: %d Hz\n", b.SampleRate())
+	fmt.Printf("+- Channels: %d (acmod %d, LFE %t)\n", b.Channels(), b.Acmod, b.LFEOn)
+	fmt.Printf("+- Bitrate: %d bit/s\n", b.Bitrate())
+}
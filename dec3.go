@@ -0,0 +1,177 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// E-AC-3 Specific Box (dec3 - mandatory for E-AC-3 audio tracks)
+//
+// Contained in : Audio Sample Entry (ec-3)
+//
+// Status: decoded
+//
+// Contains the E-AC-3 stream parameters (ETSI TS 102 366, F.6) : data rate (in kbit/s) and the
+// parameters of each independent substream. The extension fields (e.g. Dolby Atmos signaling) are
+// not decoded.
+type Dec3Box struct {
+	DataRate             uint16
+	IndependentSubstream []Ec3Substream
+	notDecoded           []byte
+}
+
+// Ec3Substream describes an independent substream, and its dependent substreams (ChanLoc defines the
+// additional channel locations)
+type Ec3Substream struct {
+	Fscod     byte
+	Bsid      byte
+	Asvc      bool
+	Bsmod     byte
+	Acmod     byte
+	LFEOn     bool
+	NumDepSub byte
+	ChanLoc   uint16 `json:",omitempty"`
+}
+
+func DecodeDec3(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, ErrTruncatedBody
+	}
+	v := binary.BigEndian.Uint16(data[0:2])
+	b := &Dec3Box{
+		DataRate: v >> 3,
+	}
+	n := int(v&0x07) + 1
+	off := 2
+	for i := 0; i < n; i++ {
+		if off+3 > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		s := Ec3Substream{
+			Fscod:     data[off] >> 6,
+			Bsid:      (data[off] >> 1) & 0x1f,
+			Asvc:      data[off+1]&0x80 != 0,
+			Bsmod:     (data[off+1] >> 4) & 0x07,
+			Acmod:     (data[off+1] >> 1) & 0x07,
+			LFEOn:     data[off+1]&0x01 != 0,
+			NumDepSub: (data[off+2] >> 1) & 0x0f,
+		}
+		if s.NumDepSub > 0 {
+			if off+4 > len(data) {
+				return nil, ErrTruncatedBody
+			}
+			s.ChanLoc = binary.BigEndian.Uint16(data[off+2:off+4]) & 0x01ff
+			off++
+		}
+		off += 3
+		b.IndependentSubstream = append(b.IndependentSubstream, s)
+	}
+	b.notDecoded = data[off:]
+	return b, nil
+}
+
+func (b *Dec3Box) Box() Box {
+	return b
+}
+
+func (b *Dec3Box) Type() string {
+	return "dec3"
+}
+
+func (b *Dec3Box) Size() int {
+	sz := BoxHeaderSize + 2 + len(b.notDecoded)
+	for _, s := range b.IndependentSubstream {
+		sz += 3
+		if s.NumDepSub > 0 {
+			sz++
+		}
+	}
+	return sz
+}
+
+// SampleRate returns the sample rate in Hz of the first independent substream, 0 if unknown
+func (b *Dec3Box) SampleRate() uint32 {
+	if len(b.IndependentSubstream) == 0 || int(b.IndependentSubstream[0].Fscod) >= len(ac3SampleRates) {
+		return 0
+	}
+	return ac3SampleRates[b.IndependentSubstream[0].Fscod]
+}
+
+// Channels returns the number of channels of the first independent substream and its dependent
+// substreams, including the LFE channels
+func (b *Dec3Box) Channels() int {
+	if len(b.IndependentSubstream) == 0 {
+		return 0
+	}
+	s := b.IndependentSubstream[0]
+	n := ac3Channels[s.Acmod&0x07]
+	if s.LFEOn {
+		n++
+	}
+	// chan_loc bits, from the most significant : Lc/Rc, Lrs/Rrs, Cs, Ts, Lsd/Rsd, Lw/Rw, Vhl/Vhr, Vhc, LFE2
+	for i, pair := range []bool{true, true, false, false, true, true, true, false, false} {
+		if s.ChanLoc&(0x100>>uint(i)) == 0 {
+			continue
+		}
+		if pair {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// Bitrate returns the bitrate in bit/s
+func (b *Dec3Box) Bitrate() uint32 {
+	return uint32(b.DataRate) * 1000
+}
+
+func (b *Dec3Box) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	n := len(b.IndependentSubstream)
+	if n > 0 {
+		n--
+	}
+	binary.BigEndian.PutUint16(buf[0:], b.DataRate<<3|uint16(n&0x07))
+	off := 2
+	for _, s := range b.IndependentSubstream {
+		buf[off] = s.Fscod<<6 | (s.Bsid&0x1f)<<1
+		buf[off+1] = (s.Bsmod&0x07)<<4 | (s.Acmod&0x07)<<1
+		if s.Asvc {
+			buf[off+1] |= 0x80
+		}
+		if s.LFEOn {
+			buf[off+1] |= 0x01
+		}
+		if s.NumDepSub > 0 {
+			binary.BigEndian.PutUint16(buf[off+2:], uint16(s.NumDepSub&0x0f)<<9|s.ChanLoc&0x01ff)
+			off++
+		} else {
+			buf[off+2] = 0
+		}
+		off += 3
+	}
+	copy(buf[off:], b.notDecoded)
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *Dec3Box) Dump() {
+	fmt.Printf("E-AC-3 Specific Box\n")
+	fmt.Printf("+- Data rate: %d kbit/s\n", b.DataRate)
+	fmt.Printf("+- Sample rate: %d Hz\n", b.SampleRate())
+	fmt.Printf("+- Channels: %d\n", b.Channels())
+	for i, s := range b.IndependentSubstream {
+		fmt.Printf("+- Substream #%d: acmod %d, LFE %t, %d dependent substreams\n", i, s.Acmod, s.LFEOn, s.NumDepSub)
+	}
+}
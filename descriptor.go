@@ -0,0 +1,535 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MPEG-4 descriptor tags (ISO/IEC 14496-1, 7.2.2.1)
+const (
+	ObjectDescrTag        = 0x01
+	InitialObjectDescrTag = 0x02
+	ESDescrTag            = 0x03
+	DecoderConfigDescrTag = 0x04
+	DecSpecificInfoTag    = 0x05
+	SLConfigDescrTag      = 0x06
+	ESIDIncTag            = 0x0e
+	MP4IODTag             = 0x10
+)
+
+// Descriptor is a MPEG-4 descriptor (ISO/IEC 14496-1), as stored in the esds and iods boxes.
+//
+// Each descriptor starts with its tag and its size, stored on 1 to 4 bytes (7 bits per byte). Many
+// encoders use 4 bytes even for small sizes : the length of the size field is kept when decoding,
+// so that the descriptors are encoded back identically.
+type Descriptor interface {
+	Tag() byte
+	// Size returns the size of the descriptor, including the tag and the size field
+	Size() int
+	Encode(w io.Writer) error
+}
+
+// decodeDescriptorHeader returns the tag, the payload size and the header size of the descriptor
+// starting at data[0]
+func decodeDescriptorHeader(data []byte) (byte, int, int, error) {
+	if len(data) < 2 {
+		return 0, 0, 0, ErrTruncatedBody
+	}
+	size := 0
+	for i := 1; i <= 4; i++ {
+		if i >= len(data) {
+			return 0, 0, 0, ErrTruncatedBody
+		}
+		size = size<<7 | int(data[i]&0x7f)
+		if data[i]&0x80 == 0 {
+			if 1+i+size > len(data) {
+				return 0, 0, 0, ErrTruncatedBody
+			}
+			return data[0], size, 1 + i, nil
+		}
+	}
+	return 0, 0, 0, ErrBadFormat
+}
+
+// descriptorSizeLen returns the number of bytes used to encode size, at least sizeLen
+func descriptorSizeLen(size, sizeLen int) int {
+	n := 1
+	for s := size >> 7; s > 0; s >>= 7 {
+		n++
+	}
+	if sizeLen > n {
+		return sizeLen
+	}
+	return n
+}
+
+func encodeDescriptorHeader(w io.Writer, tag byte, size, sizeLen int) error {
+	n := descriptorSizeLen(size, sizeLen)
+	buf := make([]byte, 1+n)
+	buf[0] = tag
+	for i := n; i >= 1; i-- {
+		buf[i] = byte(size & 0x7f)
+		if i != n {
+			buf[i] |= 0x80
+		}
+		size >>= 7
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// DecodeDescriptor decodes the descriptor starting at data[0], and returns it with its size
+func DecodeDescriptor(data []byte) (Descriptor, int, error) {
+	tag, size, hlen, err := decodeDescriptorHeader(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := data[hlen : hlen+size]
+	sizeLen := hlen - 1
+	var d Descriptor
+	switch tag {
+	case ESDescrTag:
+		d, err = decodeESDescriptor(payload, sizeLen)
+	case DecoderConfigDescrTag:
+		d, err = decodeDecoderConfigDescriptor(payload, sizeLen)
+	case DecSpecificInfoTag:
+		d = &DecoderSpecificInfo{Data: payload, sizeLen: sizeLen}
+	case InitialObjectDescrTag, MP4IODTag:
+		d, err = decodeInitialObjectDescriptor(tag, payload, sizeLen)
+	default:
+		d = &RawDescriptor{DescrTag: tag, Data: payload, sizeLen: sizeLen}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return d, hlen + size, nil
+}
+
+// decodeDescriptors decodes a list of descriptors filling data
+func decodeDescriptors(data []byte) ([]Descriptor, error) {
+	l := []Descriptor{}
+	for len(data) > 0 {
+		d, n, err := DecodeDescriptor(data)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, d)
+		data = data[n:]
+	}
+	return l, nil
+}
+
+func descriptorsSize(l []Descriptor) int {
+	sz := 0
+	for _, d := range l {
+		sz += d.Size()
+	}
+	return sz
+}
+
+func encodeDescriptors(w io.Writer, l []Descriptor) error {
+	for _, d := range l {
+		err := d.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ES Descriptor (ES_DescrTag)
+//
+// Describes an elementary stream. The DecoderConfigDescriptor is the first child descriptor, the others
+// (SL config, ...) are kept in Descriptors.
+type ESDescriptor struct {
+	ESID             uint16
+	StreamDependence bool
+	URLFlag          bool
+	OCRStream        bool
+	StreamPriority   byte
+	DependsOnESID    uint16 `json:",omitempty"`
+	URL              string `json:",omitempty"`
+	OCRESID          uint16 `json:",omitempty"`
+	DecoderConfig    *DecoderConfigDescriptor
+	Descriptors      []Descriptor `json:",omitempty"`
+	sizeLen          int
+}
+
+func decodeESDescriptor(data []byte, sizeLen int) (*ESDescriptor, error) {
+	if len(data) < 3 {
+		return nil, ErrTruncatedBody
+	}
+	d := &ESDescriptor{
+		ESID:             binary.BigEndian.Uint16(data[0:2]),
+		StreamDependence: data[2]&0x80 != 0,
+		URLFlag:          data[2]&0x40 != 0,
+		OCRStream:        data[2]&0x20 != 0,
+		StreamPriority:   data[2] & 0x1f,
+		sizeLen:          sizeLen,
+	}
+	off := 3
+	if d.StreamDependence {
+		if off+2 > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		d.DependsOnESID = binary.BigEndian.Uint16(data[off : off+2])
+		off += 2
+	}
+	if d.URLFlag {
+		if off+1 > len(data) || off+1+int(data[off]) > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		d.URL = string(data[off+1 : off+1+int(data[off])])
+		off += 1 + int(data[off])
+	}
+	if d.OCRStream {
+		if off+2 > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		d.OCRESID = binary.BigEndian.Uint16(data[off : off+2])
+		off += 2
+	}
+	l, err := decodeDescriptors(data[off:])
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range l {
+		if dc, ok := c.(*DecoderConfigDescriptor); ok && d.DecoderConfig == nil {
+			d.DecoderConfig = dc
+		} else {
+			d.Descriptors = append(d.Descriptors, c)
+		}
+	}
+	return d, nil
+}
+
+func (d *ESDescriptor) Tag() byte {
+	return ESDescrTag
+}
+
+func (d *ESDescriptor) payloadSize() int {
+	sz := 3 + descriptorsSize(d.Descriptors)
+	if d.StreamDependence {
+		sz += 2
+	}
+	if d.URLFlag {
+		sz += 1 + len(d.URL)
+	}
+	if d.OCRStream {
+		sz += 2
+	}
+	if d.DecoderConfig != nil {
+		sz += d.DecoderConfig.Size()
+	}
+	return sz
+}
+
+func (d *ESDescriptor) Size() int {
+	sz := d.payloadSize()
+	return 1 + descriptorSizeLen(sz, d.sizeLen) + sz
+}
+
+func (d *ESDescriptor) Encode(w io.Writer) error {
+	err := encodeDescriptorHeader(w, d.Tag(), d.payloadSize(), d.sizeLen)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 3, 8+len(d.URL))
+	binary.BigEndian.PutUint16(buf[0:], d.ESID)
+	buf[2] = d.StreamPriority & 0x1f
+	if d.StreamDependence {
+		buf[2] |= 0x80
+		buf = append(buf, byte(d.DependsOnESID>>8), byte(d.DependsOnESID))
+	}
+	if d.URLFlag {
+		buf[2] |= 0x40
+		buf = append(buf, byte(len(d.URL)))
+		buf = append(buf, d.URL...)
+	}
+	if d.OCRStream {
+		buf[2] |= 0x20
+		buf = append(buf, byte(d.OCRESID>>8), byte(d.OCRESID))
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+	if d.DecoderConfig != nil {
+		err = d.DecoderConfig.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	return encodeDescriptors(w, d.Descriptors)
+}
+
+// Decoder Config Descriptor (DecoderConfigDescrTag)
+//
+// ObjectTypeIndication defines the codec (0x40 for MPEG-4 audio, 0x69/0x6b for MP3, ...), the codec
+// configuration is stored in the DecoderSpecificInfo.
+type DecoderConfigDescriptor struct {
+	ObjectTypeIndication byte
+	StreamType           byte
+	UpStream             bool
+	BufferSizeDB         uint32
+	MaxBitrate           uint32
+	AvgBitrate           uint32
+	DecoderSpecificInfo  *DecoderSpecificInfo
+	Descriptors          []Descriptor `json:",omitempty"`
+	noReservedBit        bool
+	sizeLen              int
+}
+
+func decodeDecoderConfigDescriptor(data []byte, sizeLen int) (*DecoderConfigDescriptor, error) {
+	if len(data) < 13 {
+		return nil, ErrTruncatedBody
+	}
+	d := &DecoderConfigDescriptor{
+		ObjectTypeIndication: data[0],
+		StreamType:           data[1] >> 2,
+		UpStream:             data[1]&0x02 != 0,
+		noReservedBit:        data[1]&0x01 == 0,
+		BufferSizeDB:         binary.BigEndian.Uint32(data[1:5]) & 0xffffff,
+		MaxBitrate:           binary.BigEndian.Uint32(data[5:9]),
+		AvgBitrate:           binary.BigEndian.Uint32(data[9:13]),
+		sizeLen:              sizeLen,
+	}
+	l, err := decodeDescriptors(data[13:])
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range l {
+		if dsi, ok := c.(*DecoderSpecificInfo); ok && d.DecoderSpecificInfo == nil {
+			d.DecoderSpecificInfo = dsi
+		} else {
+			d.Descriptors = append(d.Descriptors, c)
+		}
+	}
+	return d, nil
+}
+
+func (d *DecoderConfigDescriptor) Tag() byte {
+	return DecoderConfigDescrTag
+}
+
+func (d *DecoderConfigDescriptor) payloadSize() int {
+	sz := 13 + descriptorsSize(d.Descriptors)
+	if d.DecoderSpecificInfo != nil {
+		sz += d.DecoderSpecificInfo.Size()
+	}
+	return sz
+}
+
+func (d *DecoderConfigDescriptor) Size() int {
+	sz := d.payloadSize()
+	return 1 + descriptorSizeLen(sz, d.sizeLen) + sz
+}
+
+func (d *DecoderConfigDescriptor) Encode(w io.Writer) error {
+	err := encodeDescriptorHeader(w, d.Tag(), d.payloadSize(), d.sizeLen)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 13)
+	binary.BigEndian.PutUint32(buf[1:], d.BufferSizeDB&0xffffff)
+	buf[0] = d.ObjectTypeIndication
+	buf[1] = d.StreamType << 2
+	if d.UpStream {
+		buf[1] |= 0x02
+	}
+	if !d.noReservedBit {
+		buf[1] |= 0x01
+	}
+	binary.BigEndian.PutUint32(buf[5:], d.MaxBitrate)
+	binary.BigEndian.PutUint32(buf[9:], d.AvgBitrate)
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+	if d.DecoderSpecificInfo != nil {
+		err = d.DecoderSpecificInfo.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	return encodeDescriptors(w, d.Descriptors)
+}
+
+// Decoder Specific Info (DecSpecificInfoTag)
+//
+// Contains the codec configuration, for MPEG-4 audio the AudioSpecificConfig (see ParseAudioSpecificConfig).
+type DecoderSpecificInfo struct {
+	Data    []byte
+	sizeLen int
+}
+
+func (d *DecoderSpecificInfo) Tag() byte {
+	return DecSpecificInfoTag
+}
+
+func (d *DecoderSpecificInfo) Size() int {
+	return 1 + descriptorSizeLen(len(d.Data), d.sizeLen) + len(d.Data)
+}
+
+func (d *DecoderSpecificInfo) Encode(w io.Writer) error {
+	err := encodeDescriptorHeader(w, d.Tag(), len(d.Data), d.sizeLen)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d.Data)
+	return err
+}
+
+// Initial Object Descriptor (InitialObjectDescrTag or MP4_IOD_Tag)
+//
+// Stored in the iods box. The profile levels are only present if URLFlag is not set.
+type InitialObjectDescriptor struct {
+	DescrTag                  byte
+	ObjectDescriptorID        uint16
+	URLFlag                   bool
+	IncludeInlineProfileLevel bool
+	URL                       string `json:",omitempty"`
+	ODProfileLevel            byte
+	SceneProfileLevel         byte
+	AudioProfileLevel         byte
+	VisualProfileLevel        byte
+	GraphicsProfileLevel      byte
+	Descriptors               []Descriptor `json:",omitempty"`
+	sizeLen                   int
+}
+
+func decodeInitialObjectDescriptor(tag byte, data []byte, sizeLen int) (*InitialObjectDescriptor, error) {
+	if len(data) < 2 {
+		return nil, ErrTruncatedBody
+	}
+	d := &InitialObjectDescriptor{
+		DescrTag:                  tag,
+		ObjectDescriptorID:        binary.BigEndian.Uint16(data[0:2]) >> 6,
+		URLFlag:                   data[1]&0x20 != 0,
+		IncludeInlineProfileLevel: data[1]&0x10 != 0,
+		sizeLen:                   sizeLen,
+	}
+	off := 2
+	if d.URLFlag {
+		if off+1 > len(data) || off+1+int(data[off]) > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		d.URL = string(data[off+1 : off+1+int(data[off])])
+		off += 1 + int(data[off])
+	} else {
+		if off+5 > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		d.ODProfileLevel = data[off]
+		d.SceneProfileLevel = data[off+1]
+		d.AudioProfileLevel = data[off+2]
+		d.VisualProfileLevel = data[off+3]
+		d.GraphicsProfileLevel = data[off+4]
+		off += 5
+	}
+	var err error
+	d.Descriptors, err = decodeDescriptors(data[off:])
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *InitialObjectDescriptor) Tag() byte {
+	return d.DescrTag
+}
+
+func (d *InitialObjectDescriptor) payloadSize() int {
+	sz := 2 + descriptorsSize(d.Descriptors)
+	if d.URLFlag {
+		sz += 1 + len(d.URL)
+	} else {
+		sz += 5
+	}
+	return sz
+}
+
+func (d *InitialObjectDescriptor) Size() int {
+	sz := d.payloadSize()
+	return 1 + descriptorSizeLen(sz, d.sizeLen) + sz
+}
+
+func (d *InitialObjectDescriptor) Encode(w io.Writer) error {
+	err := encodeDescriptorHeader(w, d.Tag(), d.payloadSize(), d.sizeLen)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 2, 7+len(d.URL))
+	binary.BigEndian.PutUint16(buf, d.ObjectDescriptorID<<6|0x0f)
+	if d.URLFlag {
+		buf[1] |= 0x20
+	}
+	if d.IncludeInlineProfileLevel {
+		buf[1] |= 0x10
+	}
+	if d.URLFlag {
+		buf = append(buf, byte(len(d.URL)))
+		buf = append(buf, d.URL...)
+	} else {
+		buf = append(buf, d.ODProfileLevel, d.SceneProfileLevel, d.AudioProfileLevel, d.VisualProfileLevel, d.GraphicsProfileLevel)
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+	return encodeDescriptors(w, d.Descriptors)
+}
+
+// RawDescriptor is a descriptor that is not decoded (SL config, ES_ID_Inc, ...), its payload is kept in Data
+type RawDescriptor struct {
+	DescrTag byte
+	Data     []byte
+	sizeLen  int
+}
+
+func (d *RawDescriptor) Tag() byte {
+	return d.DescrTag
+}
+
+func (d *RawDescriptor) Size() int {
+	return 1 + descriptorSizeLen(len(d.Data), d.sizeLen) + len(d.Data)
+}
+
+func (d *RawDescriptor) Encode(w io.Writer) error {
+	err := encodeDescriptorHeader(w, d.DescrTag, len(d.Data), d.sizeLen)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d.Data)
+	return err
+}
+
+// dumpDescriptors prints a descriptor tree, with an indentation per level
+func dumpDescriptors(l []Descriptor, indent string) {
+	for _, d := range l {
+		switch d := d.(type) {
+		case *ESDescriptor:
+			fmt.Printf("%s+- ES descriptor: ES ID %d\n", indent, d.ESID)
+			if d.DecoderConfig != nil {
+				dumpDescriptors([]Descriptor{d.DecoderConfig}, indent+" ")
+			}
+			dumpDescriptors(d.Descriptors, indent+" ")
+		case *DecoderConfigDescriptor:
+			fmt.Printf("%s+- Decoder config descriptor: object type 0x%02x, stream type %d, max bitrate %d, avg bitrate %d\n",
+				indent, d.ObjectTypeIndication, d.StreamType, d.MaxBitrate, d.AvgBitrate)
+			if d.DecoderSpecificInfo != nil {
+				dumpDescriptors([]Descriptor{d.DecoderSpecificInfo}, indent+" ")
+			}
+			dumpDescriptors(d.Descriptors, indent+" ")
+		case *DecoderSpecificInfo:
+			fmt.Printf("%s+- Decoder specific info: % x\n", indent, d.Data)
+		case *InitialObjectDescriptor:
+			fmt.Printf("%s+- Initial object descriptor: OD ID %d, audio profile 0x%02x, visual profile 0x%02x\n",
+				indent, d.ObjectDescriptorID, d.AudioProfileLevel, d.VisualProfileLevel)
+			dumpDescriptors(d.Descriptors, indent+" ")
+		default:
+			fmt.Printf("%s+- Descriptor: tag 0x%02x, %d bytes\n", indent, d.Tag(), d.Size())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// FLAC metadata block types
+const (
+	FlacStreamInfo = 0
+	FlacPadding    = 1
+	FlacSeekTable  = 3
+	FlacComment    = 4
+	FlacPicture    = 6
+)
+
+// FLAC Specific Box (dfLa - mandatory for FLAC audio tracks)
+//
+// Contained in : Audio Sample Entry (fLaC)
+//
+// Status: decoded
+//
+// Contains the FLAC metadata blocks (Encapsulation of FLAC in ISO Base Media File Format), the first one
+// being the STREAMINFO block (see StreamInfo). The last-metadata-block flag is set when encoding.
+type DfLaBox struct {
+	Version byte
+	Flags   [3]byte
+	Blocks  []FlacMetadataBlock
+}
+
+// FlacMetadataBlock is a FLAC metadata block (STREAMINFO, SEEKTABLE, VORBIS_COMMENT, ...)
+type FlacMetadataBlock struct {
+	BlockType byte
+	Data      []byte
+}
+
+// FlacStreamInfoBlock is the content of the STREAMINFO metadata block
+type FlacStreamInfoBlock struct {
+	MinBlockSize  uint16
+	MaxBlockSize  uint16
+	MinFrameSize  uint32
+	MaxFrameSize  uint32
+	SampleRate    uint32
+	Channels      byte
+	BitsPerSample byte
+	TotalSamples  uint64
+	MD5           []byte
+}
+
+func DecodeDfLa(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, ErrTruncatedBody
+	}
+	b := &DfLaBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	off := 4
+	for off < len(data) {
+		if off+4 > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		last := data[off]&0x80 != 0
+		sz := int(binary.BigEndian.Uint32(data[off:off+4]) & 0xffffff)
+		if off+4+sz > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		b.Blocks = append(b.Blocks, FlacMetadataBlock{
+			BlockType: data[off] & 0x7f,
+			Data:      data[off+4 : off+4+sz],
+		})
+		off += 4 + sz
+		if last {
+			break
+		}
+	}
+	return b, nil
+}
+
+func (b *DfLaBox) Box() Box {
+	return b
+}
+
+func (b *DfLaBox) Type() string {
+	return "dfLa"
+}
+
+func (b *DfLaBox) Size() int {
+	sz := BoxHeaderSize + 4
+	for _, m := range b.Blocks {
+		sz += 4 + len(m.Data)
+	}
+	return sz
+}
+
+// StreamInfo decodes the STREAMINFO metadata block
+func (b *DfLaBox) StreamInfo() (*FlacStreamInfoBlock, error) {
+	for _, m := range b.Blocks {
+		if m.BlockType != FlacStreamInfo {
+			continue
+		}
+		if len(m.Data) < 34 {
+			return nil, ErrTruncatedBody
+		}
+		d := m.Data
+		v := binary.BigEndian.Uint64(d[10:18])
+		return &FlacStreamInfoBlock{
+			MinBlockSize:  binary.BigEndian.Uint16(d[0:2]),
+			MaxBlockSize:  binary.BigEndian.Uint16(d[2:4]),
+			MinFrameSize:  binary.BigEndian.Uint32(d[3:7]) & 0xffffff,
+			MaxFrameSize:  binary.BigEndian.Uint32(d[6:10]) & 0xffffff,
+			SampleRate:    uint32(v >> 44),
+			Channels:      byte(v>>41&0x07) + 1,
+			BitsPerSample: byte(v>>36&0x1f) + 1,
+			TotalSamples:  v & 0xfffffffff,
+			MD5:           d[18:34],
+		}, nil
+	}
+	return nil, ErrBadFormat
+}
+
+func (b *DfLaBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.Version
+	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
+	off := 4
+	for i, m := range b.Blocks {
+		binary.BigEndian.PutUint32(buf[off:], uint32(len(m.Data)))
+		buf[off] = m.BlockType & 0x7f
+		if i == len(b.Blocks)-1 {
+			buf[off] |= 0x80
+		}
+		off += 4 + copy(buf[off+4:], m.Data)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *DfLaBox) Dump() {
+	fmt.Printf("FLAC Specific Box\n")
+	if si, err := b.StreamInfo(); err == nil {
+		fmt.Printf("+- Stream info: %d Hz, %d channels, %d bits, %d samples\n", si.SampleRate, si.Channels, si.BitsPerSample, si.TotalSamples)
+	}
+	for _, m := range b.Blocks {
+		fmt.Printf("+- Metadata block: type %d, %d bytes\n", m.BlockType, len(m.Data))
+	}
+}
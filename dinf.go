@@ -13,6 +13,7 @@ import (
 type DinfBox struct {
 	Dref  *DrefBox `json:"dref,"`
 	Boxes []Box    `json:",omitempty"`
+	order []string
 }
 
 func DecodeDinf(h BoxHeader, r io.Reader) (Box, error) {
@@ -20,8 +21,12 @@ func DecodeDinf(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	d := &DinfBox{}
+	d := &DinfBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			d.Boxes = append(d.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "dref":
 			d.Dref = b.(*DrefBox)
@@ -39,8 +44,17 @@ func (b *DinfBox) Type() string {
 	return "dinf"
 }
 
+// boxes returns the boxes of the dinf box, in the decoding order
+func (b *DinfBox) boxes() []Box {
+	l := []Box{}
+	if b.Dref != nil {
+		l = append(l, b.Dref)
+	}
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
 func (b *DinfBox) Size() int {
-	return BoxHeaderSize + b.Dref.Size()
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *DinfBox) Encode(w io.Writer) error {
@@ -48,9 +62,12 @@ func (b *DinfBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return b.Dref.Encode(w)
+	return encodeBoxes(b.boxes(), w)
 }
 func (b *DinfBox) Dump() {
 	fmt.Printf("Data Information Box\n")
 	b.Dref.Dump()
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
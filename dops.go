@@ -0,0 +1,105 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Opus Specific Box (dOps - mandatory for Opus audio tracks)
+//
+// Contained in : Audio Sample Entry (Opus)
+//
+// Status: decoded
+//
+// Contains the Opus decoder configuration (Encapsulation of Opus in ISO Base Media File Format) :
+// channel count, pre-skip (in samples at 48 kHz), output gain and channel mapping.
+//
+// OutputGain is a fixed point number (8 bits + 8 bits) in dB. The stream and coupled counts and the
+// channel mapping are only present if ChannelMappingFamily is not 0.
+type DOpsBox struct {
+	Version              byte
+	OutputChannelCount   byte
+	PreSkip              uint16
+	InputSampleRate      uint32
+	OutputGain           int16
+	ChannelMappingFamily byte
+	StreamCount          byte   `json:",omitempty"`
+	CoupledCount         byte   `json:",omitempty"`
+	ChannelMapping       []byte `json:",omitempty"`
+}
+
+func DecodeDOps(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 11 {
+		return nil, ErrTruncatedBody
+	}
+	b := &DOpsBox{
+		Version:              data[0],
+		OutputChannelCount:   data[1],
+		PreSkip:              binary.BigEndian.Uint16(data[2:4]),
+		InputSampleRate:      binary.BigEndian.Uint32(data[4:8]),
+		OutputGain:           int16(binary.BigEndian.Uint16(data[8:10])),
+		ChannelMappingFamily: data[10],
+	}
+	if b.ChannelMappingFamily != 0 {
+		if len(data) < 13+int(b.OutputChannelCount) {
+			return nil, ErrTruncatedBody
+		}
+		b.StreamCount = data[11]
+		b.CoupledCount = data[12]
+		b.ChannelMapping = data[13 : 13+int(b.OutputChannelCount)]
+	}
+	return b, nil
+}
+
+func (b *DOpsBox) Box() Box {
+	return b
+}
+
+func (b *DOpsBox) Type() string {
+	return "dOps"
+}
+
+func (b *DOpsBox) Size() int {
+	if b.ChannelMappingFamily != 0 {
+		return BoxHeaderSize + 13 + len(b.ChannelMapping)
+	}
+	return BoxHeaderSize + 11
+}
+
+func (b *DOpsBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.Version
+	buf[1] = b.OutputChannelCount
+	binary.BigEndian.PutUint16(buf[2:], b.PreSkip)
+	binary.BigEndian.PutUint32(buf[4:], b.InputSampleRate)
+	binary.BigEndian.PutUint16(buf[8:], uint16(b.OutputGain))
+	buf[10] = b.ChannelMappingFamily
+	if b.ChannelMappingFamily != 0 {
+		buf[11] = b.StreamCount
+		buf[12] = b.CoupledCount
+		copy(buf[13:], b.ChannelMapping)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *DOpsBox) Dump() {
+	fmt.Printf("Opus Specific Box\n")
+	fmt.Printf("+- Channels: %d\n", b.OutputChannelCount)
+	fmt.Printf("+- Pre-skip: %d samples\n", b.PreSkip)
+	fmt.Printf("+- Input sample rate: %d Hz\n", b.InputSampleRate)
+	fmt.Printf("+- Output gain: %.2f dB\n", float64(b.OutputGain)/256)
+	fmt.Printf("+- Channel mapping family: %d\n", b.ChannelMappingFamily)
+	if b.ChannelMappingFamily != 0 {
+		fmt.Printf("+- Streams: %d (%d coupled), mapping: %v\n", b.StreamCount, b.CoupledCount, b.ChannelMapping)
+	}
+}
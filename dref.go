@@ -20,11 +20,13 @@ type DrefBox struct {
 }
 
 func DecodeDref(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 4, 4); err != nil {
+		return nil, err
+	}
 	return &DrefBox{
 		Version:    data[0],
 		Flags:      [3]byte{data[1], data[2], data[3]},
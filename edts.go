@@ -22,6 +22,9 @@ func DecodeEdts(h BoxHeader, r io.Reader) (Box, error) {
 	for _, b := range l {
 		switch b.Type() {
 		case "elst":
+			if _, ok := b.(*RawBox); ok {
+				return nil, ErrBadFormat
+			}
 			e.Elst = b.(*ElstBox)
 		default:
 			return nil, ErrBadFormat
@@ -38,16 +41,21 @@ func (b *EdtsBox) Type() string {
 }
 
 func (b *EdtsBox) Size() int {
+	if b.Elst == nil {
+		return BoxHeaderSize
+	}
 	return BoxHeaderSize + b.Elst.Size()
 }
 
 func (b *EdtsBox) Dump() {
-	b.Elst.Dump()
+	if b.Elst != nil {
+		b.Elst.Dump()
+	}
 }
 
 func (b *EdtsBox) Encode(w io.Writer) error {
 	err := EncodeHeader(b, w)
-	if err != nil {
+	if err != nil || b.Elst == nil {
 		return err
 	}
 	return b.Elst.Encode(w)
@@ -4,40 +4,66 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 )
 
 // Edit List Box (elst - optional)
 //
 // Contained in : Edit Box (edts)
 //
-// Status: version 0 decoded. version 1 not supported
+// Status: decoded
+//
+// Version 0 stores segment durations and media times on 32 bits, version 1 on 64 bits.
+// A media time of -1 defines an empty edit.
+// The slices hold the fields of the entries, and must have the same length (Encode fails otherwise).
 type ElstBox struct {
 	Version                             byte
 	Flags                               [3]byte
-	SegmentDuration, MediaTime          []uint32 // should be uint32/int32 for version 0 and uint64/int32 for version 1
+	SegmentDuration                     []uint64
+	MediaTime                           []int64
 	MediaRateInteger, MediaRateFraction []uint16 // should be int16
 }
 
 func DecodeElst(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	b := &ElstBox{
 		Version:           data[0],
 		Flags:             [3]byte{data[1], data[2], data[3]},
-		SegmentDuration:   []uint32{},
-		MediaTime:         []uint32{},
+		SegmentDuration:   []uint64{},
+		MediaTime:         []int64{},
 		MediaRateInteger:  []uint16{},
 		MediaRateFraction: []uint16{},
 	}
 	ec := binary.BigEndian.Uint32(data[4:8])
+	off := 8
+	entrySize := 12
+	if b.Version == 1 {
+		entrySize = 20
+	}
+	if err = checkEntries(r, uint64(ec), entrySize, off, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(ec); i++ {
-		sd := binary.BigEndian.Uint32(data[(8 + 12*i):(12 + 12*i)])
-		mt := binary.BigEndian.Uint32(data[(12 + 12*i):(16 + 12*i)])
-		mri := binary.BigEndian.Uint16(data[(16 + 12*i):(18 + 12*i)])
-		mrf := binary.BigEndian.Uint16(data[(18 + 12*i):(20 + 12*i)])
+		var sd uint64
+		var mt int64
+		if b.Version == 1 {
+			sd = binary.BigEndian.Uint64(data[off : off+8])
+			mt = int64(binary.BigEndian.Uint64(data[off+8 : off+16]))
+			off += 16
+		} else {
+			sd = uint64(binary.BigEndian.Uint32(data[off : off+4]))
+			mt = int64(int32(binary.BigEndian.Uint32(data[off+4 : off+8])))
+			off += 8
+		}
+		mri := binary.BigEndian.Uint16(data[off : off+2])
+		mrf := binary.BigEndian.Uint16(data[off+2 : off+4])
+		off += 4
 		b.SegmentDuration = append(b.SegmentDuration, sd)
 		b.MediaTime = append(b.MediaTime, mt)
 		b.MediaRateInteger = append(b.MediaRateInteger, mri)
@@ -53,7 +79,21 @@ func (b *ElstBox) Type() string {
 	return "elst"
 }
 
+// version returns the version of the box, 1 if a duration or a media time does not fit in 32 bits
+func (b *ElstBox) version() byte {
+	v := fullBoxVersion(b.Version, b.SegmentDuration...)
+	for _, t := range b.MediaTime {
+		if v == 0 && (t > math.MaxInt32 || t < math.MinInt32) {
+			return 1
+		}
+	}
+	return v
+}
+
 func (b *ElstBox) Size() int {
+	if b.version() == 1 {
+		return BoxHeaderSize + 8 + len(b.SegmentDuration)*20
+	}
 	return BoxHeaderSize + 8 + len(b.SegmentDuration)*12
 }
 
@@ -65,19 +105,32 @@ func (b *ElstBox) Dump() {
 }
 
 func (b *ElstBox) Encode(w io.Writer) error {
+	n := len(b.SegmentDuration)
+	if len(b.MediaTime) != n || len(b.MediaRateInteger) != n || len(b.MediaRateFraction) != n {
+		return ErrBadFormat
+	}
 	err := EncodeHeader(b, w)
 	if err != nil {
 		return err
 	}
 	buf := make([]byte, b.Size()-BoxHeaderSize)
-	buf[0] = b.Version
+	buf[0] = b.version()
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
 	binary.BigEndian.PutUint32(buf[4:], uint32(len(b.SegmentDuration)))
+	off := 8
 	for i := range b.SegmentDuration {
-		binary.BigEndian.PutUint32(buf[8+12*i:], b.SegmentDuration[i])
-		binary.BigEndian.PutUint32(buf[12+12*i:], b.MediaTime[i])
-		binary.BigEndian.PutUint16(buf[16+12*i:], b.MediaRateInteger[i])
-		binary.BigEndian.PutUint16(buf[18+12*i:], b.MediaRateFraction[i])
+		if b.version() == 1 {
+			binary.BigEndian.PutUint64(buf[off:], b.SegmentDuration[i])
+			binary.BigEndian.PutUint64(buf[off+8:], uint64(b.MediaTime[i]))
+			off += 16
+		} else {
+			binary.BigEndian.PutUint32(buf[off:], uint32(b.SegmentDuration[i]))
+			binary.BigEndian.PutUint32(buf[off+4:], uint32(b.MediaTime[i]))
+			off += 8
+		}
+		binary.BigEndian.PutUint16(buf[off:], b.MediaRateInteger[i])
+		binary.BigEndian.PutUint16(buf[off+2:], b.MediaRateFraction[i])
+		off += 4
 	}
 	_, err = w.Write(buf)
 	return err
@@ -0,0 +1,34 @@
+package mp4
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DecodeError is the error returned when a box can not be decoded
+type DecodeError struct {
+	Path   string // of the box in the media, e.g. moov/trak[1]/mdia/minf/stbl/stsz ("" for the top-level)
+	Offset int64  // of the box, from the beginning of the media
+	Err    error  // cause (ErrTruncatedBody, ErrBadFormat, ...)
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "top-level"
+	}
+	return fmt.Sprintf("mp4: %s at offset %d: %v", path, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON marshals the error with its cause as a string (see MP4.Warnings)
+func (e *DecodeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path   string `json:"path"`
+		Offset int64  `json:"offset"`
+		Err    string `json:"error"`
+	}{e.Path, e.Offset, fmt.Sprint(e.Err)})
+}
@@ -0,0 +1,31 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	data, err := os.ReadFile("sample/hls-init.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stsz box of the first track is smaller than its header
+	stsz := bytes.Index(data, []byte("stsz")) - 4
+	if stsz < 0 {
+		t.Fatal("stsz box not found")
+	}
+	binary.BigEndian.PutUint32(data[stsz:], 4)
+
+	_, err = Decode(bytes.NewReader(data))
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("got %v, want a *DecodeError", err)
+	}
+	if de.Path != "moov/trak/mdia/minf/stbl/stsz" || de.Offset != int64(stsz) || !errors.Is(err, ErrBadFormat) {
+		t.Errorf("got %v, want an ErrBadFormat error of the box moov/trak/mdia/minf/stbl/stsz at offset %d", err, stsz)
+	}
+}
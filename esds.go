@@ -0,0 +1,127 @@
+package mp4
+
+import (
+	"fmt"
+	"io"
+)
+
+// Elementary Stream Descriptor Box (esds - mandatory for MPEG-4 audio and video tracks)
+//
+// Contained in : Audio Sample Entry (mp4a), Visual Sample Entry (mp4v)
+//
+// Status: decoded
+//
+// Contains the ES descriptor (ISO/IEC 14496-1) : object type (codec), bitrates and decoder specific
+// info (AudioSpecificConfig for AAC).
+type EsdsBox struct {
+	Version    byte
+	Flags      [3]byte
+	ES         *ESDescriptor
+	notDecoded []byte
+}
+
+func DecodeEsds(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, ErrTruncatedBody
+	}
+	b := &EsdsBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	d, n, err := DecodeDescriptor(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	es, ok := d.(*ESDescriptor)
+	if !ok {
+		return nil, ErrBadFormat
+	}
+	b.ES = es
+	b.notDecoded = data[4+n:]
+	return b, nil
+}
+
+func (b *EsdsBox) Box() Box {
+	return b
+}
+
+func (b *EsdsBox) Type() string {
+	return "esds"
+}
+
+func (b *EsdsBox) Size() int {
+	sz := BoxHeaderSize + 4 + len(b.notDecoded)
+	if b.ES != nil {
+		sz += b.ES.Size()
+	}
+	return sz
+}
+
+// DecoderConfig returns the decoder config descriptor, nil if there is none
+func (b *EsdsBox) DecoderConfig() *DecoderConfigDescriptor {
+	if b.ES == nil {
+		return nil
+	}
+	return b.ES.DecoderConfig
+}
+
+// AudioConfig parses the AudioSpecificConfig of MPEG-4 audio tracks
+func (b *EsdsBox) AudioConfig() (*AudioSpecificConfig, error) {
+	dc := b.DecoderConfig()
+	if dc == nil || dc.ObjectTypeIndication != 0x40 || dc.DecoderSpecificInfo == nil {
+		return nil, ErrBadFormat
+	}
+	return ParseAudioSpecificConfig(dc.DecoderSpecificInfo.Data)
+}
+
+// Codec returns the RFC 6381 codec string (e.g. mp4a.40.2) for a sample entry format (mp4a, mp4v)
+func (b *EsdsBox) Codec(format string) string {
+	dc := b.DecoderConfig()
+	if dc == nil {
+		return format
+	}
+	if c, err := b.AudioConfig(); err == nil {
+		return fmt.Sprintf("%s.40.%d", format, c.CodecObjectType())
+	}
+	return fmt.Sprintf("%s.%02X", format, dc.ObjectTypeIndication)
+}
+
+func (b *EsdsBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte{b.Version, b.Flags[0], b.Flags[1], b.Flags[2]})
+	if err != nil {
+		return err
+	}
+	if b.ES != nil {
+		err = b.ES.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(b.notDecoded)
+	return err
+}
+
+func (b *EsdsBox) Dump() {
+	fmt.Printf("Elementary Stream Descriptor Box\n")
+	if b.ES != nil {
+		dumpDescriptors([]Descriptor{b.ES}, "")
+	}
+	if c, err := b.AudioConfig(); err == nil {
+		fmt.Printf("+- Audio config: object type %d, %d Hz, channel configuration %d", c.ObjectType, c.SamplingFrequency, c.ChannelConfiguration)
+		if c.SBR {
+			fmt.Printf(", SBR %d Hz", c.ExtensionSamplingFrequency)
+		}
+		if c.PS {
+			fmt.Printf(", PS")
+		}
+		fmt.Println()
+	}
+}
@@ -3,7 +3,6 @@ package filter
 import (
 	"errors"
 	"io"
-	"log"
 	"sort"
 	"time"
 
@@ -14,23 +13,28 @@ var (
 	ErrInvalidDuration = errors.New("invalid duration")
 	ErrClipOutside     = errors.New("clip zone is outside video")
 	ErrTruncatedChunk  = errors.New("chunk was truncated")
+	ErrMultipleMdat    = errors.New("media data in several mdat boxes is not supported")
 )
 
 type chunk struct {
 	track                   int
 	index                   int
-	firstTC, lastTC         time.Duration
 	descriptionID           uint32
-	oldOffset               uint32
+	oldOffset               uint64
 	samples                 []uint32
 	firstSample, lastSample uint32
-	keyFrame                bool
+	skipBefore              uint32 // size of the samples at the beginning of the chunk left out of the clip
 	skip                    bool
 }
 
 func (c *chunk) size() uint32 {
+	return sizeOf(c.samples)
+}
+
+// sizeOf returns the total size of samples
+func sizeOf(samples []uint32) uint32 {
 	var sz uint32
-	for _, ssz := range c.samples {
+	for _, ssz := range samples {
 		sz += ssz
 	}
 	return sz
@@ -50,39 +54,21 @@ func (m mdat) Swap(i, j int) {
 	m[i], m[j] = m[j], m[i]
 }
 
-func (m mdat) firstSample(tnum int, timecode time.Duration) uint32 {
-	for _, c := range m {
-		if c.track != tnum {
-			continue
-		}
-		if timecode >= c.firstTC && timecode <= c.lastTC {
-			return c.firstSample
-		}
-	}
-	return 0
-}
-
-func (m mdat) lastSample(tnum int, timecode time.Duration) uint32 {
-	for _, c := range m {
-		if c.track != tnum {
-			continue
-		}
-		if timecode >= c.firstTC && timecode < c.lastTC {
-			return c.lastSample
-		}
-	}
-	return 0
-}
-
 type clipFilter struct {
-	err        error
-	begin, end time.Duration
-	mdatSize   uint32 // FIXME:
-	chunks     mdat
+	err         error
+	begin, end  time.Duration
+	mdat        *mp4.MdatBox // of the source
+	dataStart   uint64       // offset of the data of the mdat box in the source
+	boxesSize   uint64       // of the boxes written with the moov box, before the mdat box
+	mdatSize    uint64
+	mdatDone    bool
+	chunks      mdat
+	first, last []uint32       // samples of each track in the clip, in decoding order
+	edits       []*mp4.ElstBox // edit lists of the clip, nil for the tracks without edit list
 }
 
 // Clip returns a filter that extracts a clip between begin and begin + duration (in seconds, starting at 0)
-// Il will try to include a key frame at the beginning, and keeps the same chunks as the origin media
+// The clip starts at the sync sample preceding begin, and keeps the same chunks as the origin media
 func Clip(begin, duration time.Duration) Filter {
 	f := &clipFilter{begin: begin, end: begin + duration}
 	if begin < 0 {
@@ -91,89 +77,250 @@ func Clip(begin, duration time.Duration) Filter {
 	return f
 }
 
+// FilterMedia locates the media data in the source, and sizes the boxes preceding it in the clip
+func (f *clipFilter) FilterMedia(m *mp4.MP4) error {
+	if f.err != nil {
+		return f.err
+	}
+	if m.Ftyp == nil || m.Moov == nil || len(m.Mdat) == 0 {
+		return mp4.ErrBadFormat
+	}
+	if len(m.Mdat) > 1 {
+		return ErrMultipleMdat
+	}
+	f.mdat, f.dataStart = m.Mdat[0], m.MdatSpans()[0].Start
+	for _, b := range m.Boxes() {
+		switch b.Type() {
+		case "moov", "mdat":
+		default:
+			f.boxesSize += uint64(b.Size())
+		}
+	}
+	return nil
+}
+
 func (f *clipFilter) FilterMoov(m *mp4.MoovBox) error {
 	if f.err != nil {
 		return f.err
 	}
+	if f.mdat == nil {
+		// FilterMedia not called
+		return mp4.ErrBadFormat
+	}
 	if f.begin > time.Second*time.Duration(m.Mvhd.Duration)/time.Duration(m.Mvhd.Timescale) {
 		return ErrClipOutside
 	}
 	if f.end > time.Second*time.Duration(m.Mvhd.Duration)/time.Duration(m.Mvhd.Timescale) || f.end == f.begin {
 		f.end = time.Second * time.Duration(m.Mvhd.Duration) / time.Duration(m.Mvhd.Timescale)
 	}
-	oldSize := m.Size()
+	tracks := []*mp4.Track{}
+	for _, t := range m.Trak {
+		track, err := mp4.NewTrack(t)
+		if err != nil {
+			return err
+		}
+		track.MovieTimescale = m.Mvhd.Timescale
+		tracks = append(tracks, track)
+	}
+	err := f.syncToKF(tracks)
+	if err != nil {
+		return err
+	}
 	f.chunks = []*chunk{}
-	for tnum, t := range m.Trak {
-		f.buildChunkList(tnum, t)
+	f.first, f.last = make([]uint32, len(tracks)), make([]uint32, len(tracks))
+	f.edits = make([]*mp4.ElstBox, len(tracks))
+	for tnum, track := range tracks {
+		f.first[tnum], f.last[tnum], err = f.sampleRange(track)
+		if err != nil {
+			return err
+		}
+		if track.Trak.Edts != nil && track.Trak.Edts.Elst != nil {
+			f.edits[tnum], err = f.clipEdits(track, f.first[tnum], f.last[tnum], m.Mvhd.Timescale)
+			if err != nil {
+				return err
+			}
+		}
+		err = f.buildChunkList(tnum, track)
+		if err != nil {
+			return err
+		}
 	}
-	f.syncToKF()
 	for tnum, t := range m.Trak {
-		// update stts, find first/last sample
+		// update the sample tables
 		f.updateSamples(tnum, t)
 		f.updateChunks(tnum, t)
-		// co64 ?
+		if f.edits[tnum] != nil {
+			t.Edts.Elst = f.edits[tnum]
+		}
 	}
 	f.updateDurations(m)
 	sort.Sort(f.chunks)
+	f.mdatSize = 0
 	for _, c := range f.chunks {
-		sz := 0
-		for _, ssz := range c.samples {
-			sz += int(ssz)
+		if !c.skip {
+			f.mdatSize += uint64(c.size())
+		}
+	}
+	clip := *f.mdat
+	clip.ContentSize = f.mdatSize
+	header := uint64(clip.Size()) - clip.ContentSize
+	for {
+		// the moov box grows when stco boxes are replaced by co64 boxes
+		size := m.Size()
+		f.updateChunkOffsets(m, f.boxesSize+uint64(size)+header)
+		if m.Size() == size {
+			return nil
+		}
+	}
+}
+
+// syncToKF moves the beginning of the clip to the latest sync sample preceding it in the video tracks (the
+// tracks with a sync sample table), the end being moved by the same amount
+func (f *clipFilter) syncToKF(tracks []*mp4.Track) error {
+	begin := f.begin
+	for _, t := range tracks {
+		if t.Trak.Mdia.Minf.Stbl.Stss == nil {
+			continue
 		}
+		s, err := t.SyncSampleBefore(f.begin)
+		if err == mp4.ErrTimeOutOfRange {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		tc, err := t.TimeOfSample(s.Number)
+		if err != nil {
+			return err
+		}
+		begin = min(begin, max(tc, 0))
 	}
-	deltaOffset := m.Size() - oldSize
-	f.mdatSize = f.updateChunkOffsets(m, deltaOffset)
+	f.end += f.begin - begin
+	f.begin = begin
 	return nil
 }
 
-func (f *clipFilter) syncToKF() {
-	var tc time.Duration
-	for _, c := range f.chunks {
-		if c.keyFrame && c.firstTC <= f.begin {
-			tc = c.firstTC
+// sampleRange returns the first and the last samples of a track in the clip, the first one being a sync
+// sample in the video tracks
+func (f *clipFilter) sampleRange(t *mp4.Track) (uint32, uint32, error) {
+	seek := t.SampleAtTime
+	if t.Trak.Mdia.Minf.Stbl.Stss != nil {
+		seek = t.SyncSampleBefore
+	}
+	first, err := sampleAt(t, f.begin, seek)
+	if err != nil {
+		return 0, 0, err
+	}
+	// the sample presented at f.end is not part of the clip
+	last, err := sampleAt(t, f.end-time.Nanosecond, t.SampleAtTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	// with composition offsets, the samples following it in decoding order (up to the next sync sample) can
+	// be presented before f.end
+	if t.Trak.Mdia.Minf.Stbl.Ctts != nil {
+		for n := last + 1; n <= t.SampleCount(); n++ {
+			s, err := t.Sample(n)
+			if err != nil {
+				return 0, 0, err
+			}
+			if s.IsSync {
+				break
+			}
+			tc, err := t.TimeOfSample(n)
+			if err != nil {
+				return 0, 0, err
+			}
+			if tc < f.end {
+				last = n
+			}
 		}
 	}
-	f.end += f.begin - tc
-	f.begin = tc
+	if first == 0 || first > last {
+		return 0, 0, ErrClipOutside
+	}
+	return first, last, nil
 }
 
-func (f *clipFilter) buildChunkList(tnum int, t *mp4.TrakBox) {
-	stsz := t.Mdia.Minf.Stbl.Stsz
-	stsc := t.Mdia.Minf.Stbl.Stsc
-	stco := t.Mdia.Minf.Stbl.Stco
-	stts := t.Mdia.Minf.Stbl.Stts
-	stss := t.Mdia.Minf.Stbl.Stss
-	timescale := t.Mdia.Mdhd.Timescale
-	sci, ssi, ski := 0, 0, 0
-	for i, off := range stco.ChunkOffset {
-		c := &chunk{
-			track:       tnum,
-			index:       i + 1,
-			oldOffset:   uint32(off),
-			samples:     []uint32{},
-			firstSample: uint32(ssi + 1),
-			firstTC:     stts.GetTimeCode(uint32(ssi+1), timescale),
-		}
-		if sci < len(stsc.FirstChunk)-1 && c.index >= int(stsc.FirstChunk[sci+1]) {
-			sci++
-		}
-		c.descriptionID = stsc.SampleDescriptionID[sci]
-		samples := stsc.SamplesPerChunk[sci]
-		for samples > 0 {
-			c.samples = append(c.samples, stsz.GetSampleSize(ssi+1))
-			ssi++
-			samples--
-		}
-		c.lastSample = uint32(ssi)
-		c.lastTC = stts.GetTimeCode(c.lastSample+1, timescale)
-		if stss != nil {
-			for ski < len(stss.SampleNumber) && stss.SampleNumber[ski] < c.lastSample {
-				c.keyFrame = true
-				ski++
+// sampleAt returns the number of the sample found by seek at the time d, the first sample of the track if
+// d precedes it, and the last sample if d follows it
+func sampleAt(t *mp4.Track, d time.Duration, seek func(time.Duration) (mp4.SampleInfo, error)) (uint32, error) {
+	s, err := seek(d)
+	if err == nil {
+		return s.Number, nil
+	}
+	if err != mp4.ErrTimeOutOfRange || t.SampleCount() == 0 {
+		return 0, err
+	}
+	start, err := t.TimeOfSample(1)
+	if err != nil {
+		return 0, err
+	}
+	if d < start {
+		return 1, nil
+	}
+	return t.SampleCount(), nil
+}
+
+// clipEdits returns the edit list presenting the samples first to last of a track from f.begin to f.end,
+// the first sample being decoded at 0 in the clip
+func (f *clipFilter) clipEdits(t *mp4.Track, first, last uint32, movieTimescale uint32) (*mp4.ElstBox, error) {
+	s, err := t.Sample(first)
+	if err != nil {
+		return nil, err
+	}
+	l, err := t.Sample(last)
+	if err != nil {
+		return nil, err
+	}
+	tc, err := t.TimeOfSample(first)
+	if err != nil {
+		return nil, err
+	}
+	ts := t.Timescale()
+	start := max(f.begin, tc)
+	mediaTime := s.PresentationTime - int64(s.DecodeTime) + int64(toUnits(start-tc, ts))
+	duration := min(int64(l.DecodeTime+uint64(l.Duration)-s.DecodeTime)-mediaTime, int64(toUnits(f.end-start, ts)))
+	elst := &mp4.ElstBox{Version: t.Trak.Edts.Elst.Version, Flags: t.Trak.Edts.Elst.Flags}
+	if tc > f.begin {
+		// the track starts after the beginning of the clip
+		elst.SegmentDuration = append(elst.SegmentDuration, toUnits(tc-f.begin, movieTimescale))
+		elst.MediaTime = append(elst.MediaTime, -1)
+		elst.MediaRateInteger = append(elst.MediaRateInteger, 1)
+		elst.MediaRateFraction = append(elst.MediaRateFraction, 0)
+	}
+	elst.SegmentDuration = append(elst.SegmentDuration, uint64(max(duration, 0))*uint64(movieTimescale)/uint64(ts))
+	elst.MediaTime = append(elst.MediaTime, mediaTime)
+	elst.MediaRateInteger = append(elst.MediaRateInteger, 1)
+	elst.MediaRateFraction = append(elst.MediaRateFraction, 0)
+	return elst, nil
+}
+
+// toUnits converts a duration to time units of a timescale (rounded down)
+func toUnits(d time.Duration, timescale uint32) uint64 {
+	return uint64(d/time.Second)*uint64(timescale) + uint64(d%time.Second)*uint64(timescale)/uint64(time.Second)
+}
+
+func (f *clipFilter) buildChunkList(tnum int, track *mp4.Track) error {
+	var c *chunk
+	it := track.Samples()
+	for it.Next() {
+		s := it.Sample()
+		if c == nil || c.index != int(s.Chunk) {
+			c = &chunk{
+				track:         tnum,
+				index:         int(s.Chunk),
+				oldOffset:     s.Offset,
+				samples:       []uint32{},
+				firstSample:   s.Number,
+				descriptionID: s.DescriptionIndex,
 			}
+			f.chunks = append(f.chunks, c)
 		}
-		f.chunks = append(f.chunks, c)
+		c.samples = append(c.samples, s.Size)
+		c.lastSample = s.Number
 	}
+	return it.Err()
 }
 
 func (f *clipFilter) updateSamples(tnum int, t *mp4.TrakBox) {
@@ -182,28 +329,13 @@ func (f *clipFilter) updateSamples(tnum int, t *mp4.TrakBox) {
 	oldCount, oldDelta := stts.SampleCount, stts.SampleTimeDelta
 	stts.SampleCount, stts.SampleTimeDelta = []uint32{}, []uint32{}
 
-	firstSample := f.chunks.firstSample(tnum, f.begin)
-	lastSample := f.chunks.lastSample(tnum, f.end)
-
-	log.Printf("first sample %d, last %d", firstSample, lastSample)
-	sample := uint32(1)
-	for i := 0; i < len(oldCount) && sample < lastSample; i++ {
-		if sample+oldCount[i] >= firstSample {
-			var current uint32
-			switch {
-			case sample < firstSample && sample+oldCount[i] > lastSample:
-				current = lastSample - firstSample + 1
-			case sample < firstSample:
-				current = oldCount[i] + sample - firstSample
-			case sample+oldCount[i] > lastSample:
-				current = oldCount[i] + sample - lastSample
-			default:
-				current = oldCount[i]
-			}
-			stts.SampleCount = append(stts.SampleCount, current)
-			stts.SampleTimeDelta = append(stts.SampleTimeDelta, oldDelta[i])
-		}
-		sample += oldCount[i]
+	firstSample, lastSample := f.first[tnum], f.last[tnum]
+
+	mp4.Logger().Debug("clip", "track", tnum, "first sample", firstSample, "last sample", lastSample)
+	counts, kept := clipCounts(oldCount, firstSample, lastSample)
+	for k, i := range kept {
+		stts.SampleCount = append(stts.SampleCount, counts[k])
+		stts.SampleTimeDelta = append(stts.SampleTimeDelta, oldDelta[i])
 	}
 
 	// stss (key frames)
@@ -227,31 +359,36 @@ func (f *clipFilter) updateSamples(tnum int, t *mp4.TrakBox) {
 			stsz.SampleSize = append(stsz.SampleSize, sz)
 		}
 	}
-	log.Printf("stsz => %d", len(stsz.SampleSize))
+	stsz.SampleNumber = lastSample - firstSample + 1
+	mp4.Logger().Debug("clip", "track", tnum, "samples", len(stsz.SampleSize))
 
 	// ctts - time offsets
 	ctts := t.Mdia.Minf.Stbl.Ctts
 	if ctts != nil {
 		oldCount, oldOffset := ctts.SampleCount, ctts.SampleOffset
-		ctts.SampleCount, ctts.SampleOffset = []uint32{}, []uint32{}
-		sample := uint32(1)
-		for i := 0; i < len(oldCount) && sample < lastSample; i++ {
-			if sample+oldCount[i] >= firstSample {
-				current := oldCount[i]
-				if sample < firstSample && sample+oldCount[i] > firstSample {
-					current += sample - firstSample
-				}
-				if sample+oldCount[i] > lastSample {
-					current += lastSample - sample - oldCount[i]
-				}
-
-				ctts.SampleCount = append(ctts.SampleCount, current)
-				ctts.SampleOffset = append(ctts.SampleOffset, oldOffset[i])
-			}
-			sample += oldCount[i]
+		ctts.SampleCount, ctts.SampleOffset = []uint32{}, []int32{}
+		counts, kept := clipCounts(oldCount, firstSample, lastSample)
+		for k, i := range kept {
+			ctts.SampleCount = append(ctts.SampleCount, counts[k])
+			ctts.SampleOffset = append(ctts.SampleOffset, oldOffset[i])
 		}
 	}
+}
 
+// clipCounts returns the sample counts of the entries of a run-length encoded table (stts, ctts) restricted
+// to the samples first to last, and the indexes of the entries kept
+func clipCounts(counts []uint32, first, last uint32) ([]uint32, []int) {
+	clipped, kept := []uint32{}, []int{}
+	sample := uint32(1) // first sample of the entry
+	for i, count := range counts {
+		from, to := max(sample, first), min(sample+count-1, last)
+		if count > 0 && from <= to {
+			clipped = append(clipped, to-from+1)
+			kept = append(kept, i)
+		}
+		sample += count
+	}
+	return clipped, kept
 }
 
 func (f *clipFilter) updateChunks(tnum int, t *mp4.TrakBox) {
@@ -260,8 +397,7 @@ func (f *clipFilter) updateChunks(tnum int, t *mp4.TrakBox) {
 	stsc.FirstChunk, stsc.SamplesPerChunk, stsc.SampleDescriptionID = []uint32{}, []uint32{}, []uint32{}
 	var firstChunk *chunk
 	var index, firstIndex uint32
-	firstSample := f.chunks.firstSample(tnum, f.begin)
-	lastSample := f.chunks.lastSample(tnum, f.end)
+	firstSample, lastSample := f.first[tnum], f.last[tnum]
 	for _, c := range f.chunks {
 		if c.track != tnum {
 			continue
@@ -270,6 +406,18 @@ func (f *clipFilter) updateChunks(tnum int, t *mp4.TrakBox) {
 			c.skip = true
 			continue
 		}
+		// the chunks at the boundaries of the clip are cut
+		if c.firstSample < firstSample {
+			k := firstSample - c.firstSample
+			c.skipBefore = sizeOf(c.samples[:k])
+			c.samples = c.samples[k:]
+			c.firstSample = firstSample
+		}
+		if c.lastSample > lastSample {
+			k := uint32(len(c.samples)) - (c.lastSample - lastSample)
+			c.samples = c.samples[:k]
+			c.lastSample = lastSample
+		}
 		index++
 		if firstChunk == nil {
 			firstChunk = c
@@ -289,48 +437,42 @@ func (f *clipFilter) updateChunks(tnum int, t *mp4.TrakBox) {
 		stsc.SampleDescriptionID = append(stsc.SampleDescriptionID, firstChunk.descriptionID)
 	}
 
-	// stco (chunk offsets) - build empty table to compute moov box size
-	stco := t.Mdia.Minf.Stbl.Stco
-	stco.ChunkOffset = make([]uint32, index)
+	// stco/co64 (chunk offsets) - build empty table to compute moov box size
+	t.Mdia.Minf.Stbl.SetChunkOffsets(make([]uint64, index))
 }
 
-func (f *clipFilter) updateChunkOffsets(m *mp4.MoovBox, deltaOff int) uint32 {
-	stco, i := make([]*mp4.StcoBox, len(m.Trak)), make([]int, len(m.Trak))
-	for tnum, t := range m.Trak {
-		stco[tnum] = t.Mdia.Minf.Stbl.Stco
-	}
-	var offset, sz uint32
+// updateChunkOffsets sets the offsets of the chunks of the clip, written one after the other from offset
+func (f *clipFilter) updateChunkOffsets(m *mp4.MoovBox, offset uint64) {
+	offsets := make([][]uint64, len(m.Trak))
 	for _, c := range f.chunks {
-		if offset == 0 {
-			offset = uint32(int(c.oldOffset) + deltaOff)
-		}
 		if !c.skip {
-			stco[c.track].ChunkOffset[i[c.track]] = offset + sz
-			i[c.track]++
-			sz += c.size()
+			offsets[c.track] = append(offsets[c.track], offset)
+			offset += uint64(c.size())
 		}
 	}
-	return sz
+	for tnum, t := range m.Trak {
+		t.Mdia.Minf.Stbl.SetChunkOffsets(offsets[tnum])
+	}
 }
 
 func (f *clipFilter) updateDurations(m *mp4.MoovBox) {
 	timescale := m.Mvhd.Timescale
 	m.Mvhd.Duration = 0
-	for tnum, t := range m.Trak {
-		var start, end time.Duration
-		for _, c := range f.chunks {
-			if c.track != tnum || c.skip {
-				continue
-			}
-			if start == 0 || c.firstTC < start {
-				start = c.firstTC
-			}
-			if end == 0 || c.lastTC > end {
-				end = c.lastTC
+	for _, t := range m.Trak {
+		var duration uint64
+		stts := t.Mdia.Minf.Stbl.Stts
+		for i, count := range stts.SampleCount {
+			duration += uint64(count) * uint64(stts.SampleTimeDelta[i])
+		}
+		t.Mdia.Mdhd.Duration = duration
+		t.Tkhd.Duration = duration * uint64(timescale) / uint64(t.Mdia.Mdhd.Timescale)
+		if t.Edts != nil && t.Edts.Elst != nil {
+			// duration of the presentation
+			t.Tkhd.Duration = 0
+			for _, d := range t.Edts.Elst.SegmentDuration {
+				t.Tkhd.Duration += d
 			}
 		}
-		t.Mdia.Mdhd.Duration = uint32((end - start) * time.Duration(t.Mdia.Mdhd.Timescale) / time.Second)
-		t.Tkhd.Duration = uint32((end - start) * time.Duration(timescale) / time.Second)
 		if t.Tkhd.Duration > m.Mvhd.Duration {
 			m.Mvhd.Duration = t.Tkhd.Duration
 		}
@@ -341,8 +483,13 @@ func (f *clipFilter) FilterMdat(w io.Writer, m *mp4.MdatBox) error {
 	if f.err != nil {
 		return f.err
 	}
-	m.ContentSize = uint64(f.mdatSize) // FIXME:
-	err := mp4.EncodeHeader(m, w)
+	if f.mdatDone {
+		return ErrMultipleMdat
+	}
+	f.mdatDone = true
+	clip := *m
+	clip.ContentSize = f.mdatSize
+	err := mp4.EncodeHeader(&clip, w)
 	if err != nil {
 		return err
 	}
@@ -353,32 +500,33 @@ func (f *clipFilter) FilterMdat(w io.Writer, m *mp4.MdatBox) error {
 		}
 	}
 	buffer := make([]byte, bufSize)
+	r := m.Reader()
 	for _, c := range f.chunks {
-		s := c.size()
-		// Seek if the reader supports it
-		if rs, seekable := m.Reader().(io.Seeker); c.skip && seekable {
-			_, err := rs.Seek(int64(s), 1)
-			if err != nil {
-				return err
-			}
+		if c.skip {
 			continue
 		}
-		// Read otherwise, and only write if the chunk was not skipped
-		n, err := io.ReadFull(m.Reader(), buffer[:s])
+		// the samples of the chunk left out of the clip are not read
+		if c.oldOffset < f.dataStart {
+			return ErrChunkOutsideMdat
+		}
+		_, err := r.Seek(int64(c.oldOffset-f.dataStart)+int64(c.skipBefore), io.SeekStart)
 		if err != nil {
 			return err
 		}
-		if n != int(s) {
+		s := c.size()
+		n, err := io.ReadFull(r, buffer[:s])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			return ErrTruncatedChunk
 		}
-		if !c.skip {
-			n, err = w.Write(buffer[:s])
-			if err != nil {
-				return err
-			}
-			if n != int(s) {
-				return ErrTruncatedChunk
-			}
+		if err != nil {
+			return err
+		}
+		n, err = w.Write(buffer[:n])
+		if err != nil {
+			return err
+		}
+		if n != int(s) {
+			return ErrTruncatedChunk
 		}
 	}
 	return nil
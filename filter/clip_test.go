@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/otherplace/mp4"
+)
+
+func TestClip(t *testing.T) {
+	data, err := os.ReadFile("../sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the moov box after the mdat box, and before it
+	sources := map[string][]byte{"moov last": data, "faststart": encode(t, data, Faststart())}
+	clips := []struct {
+		begin, duration time.Duration
+	}{
+		{0, 2 * time.Second},
+		{500 * time.Millisecond, time.Second},
+		{time.Second, 300 * time.Millisecond},
+		{1700 * time.Millisecond, time.Second},
+		{1500 * time.Millisecond, 0},
+	}
+	for name, src := range sources {
+		want := trackSamples(t, src)
+		for _, c := range clips {
+			f := Clip(c.begin, c.duration).(*clipFilter)
+			clip := encode(t, src, f)
+			got := trackSamples(t, clip)
+			for tnum, samples := range got {
+				first, last := f.first[tnum], f.last[tnum]
+				if len(samples) != int(last-first+1) {
+					t.Errorf("%s, %v: track %d has %d samples, want %d", name, c, tnum+1, len(samples), last-first+1)
+					continue
+				}
+				for i, s := range samples {
+					if !bytes.Equal(s, want[tnum][int(first)-1+i]) {
+						t.Errorf("%s, %v: sample %d of track %d differs from the source sample %d", name, c, i+1,
+							tnum+1, int(first)+i)
+						break
+					}
+				}
+			}
+			m, err := mp4.Decode(bytes.NewReader(clip))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, trak := range m.Moov.Trak {
+				tr, err := mp4.NewTrack(trak)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s, err := tr.Sample(1); err != nil || !s.IsSync {
+					t.Errorf("%s, %v: the first sample of track %d is not a sync sample (%v)", name, c, tr.ID(), err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"errors"
+	"io"
+
+	"github.com/otherplace/mp4"
+)
+
+var (
+	ErrChunkOutsideMdat = errors.New("chunk offset outside of the mdat boxes")
+)
+
+// shift moves the data of a mdat box
+type shift struct {
+	start, end uint64 // of the data, in the source media
+	delta      int64
+}
+
+type faststartFilter struct {
+	shifts []shift
+}
+
+// Faststart returns a filter that moves the moov box in front of the mdat boxes, so that the media can be
+// played while it is downloaded. The chunk offsets are shifted with the media data, the stco boxes being
+// replaced by co64 boxes when the offsets do not fit in 32 bits anymore.
+//
+// The free boxes are replaced by a single one, placed after the moov box, that absorbs the growth when it is
+// large enough : the media data does not move then.
+func Faststart() Filter {
+	return &faststartFilter{}
+}
+
+func (f *faststartFilter) FilterMedia(m *mp4.MP4) error {
+	if m.Ftyp == nil || m.Moov == nil {
+		return mp4.ErrBadFormat
+	}
+	for _, s := range m.MdatSpans() {
+		f.shifts = append(f.shifts, shift{start: s.Start, end: s.End})
+	}
+
+	stbls := []*mp4.StblBox{}
+	offsets := [][]uint64{}
+	for _, t := range m.Moov.Trak {
+		if t.Mdia == nil || t.Mdia.Minf == nil || t.Mdia.Minf.Stbl == nil {
+			continue
+		}
+		stbls = append(stbls, t.Mdia.Minf.Stbl)
+		offsets = append(offsets, t.Mdia.Minf.Stbl.ChunkOffsets())
+	}
+	m.Free = nil
+	for {
+		size := m.Moov.Size()
+		f.layout(m)
+		for i, stbl := range stbls {
+			moved := make([]uint64, len(offsets[i]))
+			for j, o := range offsets[i] {
+				n, err := f.move(o)
+				if err != nil {
+					return err
+				}
+				moved[j] = n
+			}
+			stbl.SetChunkOffsets(moved)
+		}
+		// the moov box grows when stco boxes are replaced by co64 boxes
+		if m.Moov.Size() == size {
+			return nil
+		}
+	}
+}
+
+// layout computes the shifts of the mdat boxes, following the moov box and the other boxes
+func (f *faststartFilter) layout(m *mp4.MP4) {
+	// offset of the first mdat box, without free box
+	m.Free = nil
+	pos := uint64(m.Ftyp.Size() + m.Moov.Size())
+	for _, b := range m.Boxes() {
+		switch b.Type() {
+		case "ftyp", "moov", "mdat":
+		default:
+			pos += uint64(b.Size())
+		}
+	}
+	if len(m.Mdat) > 0 {
+		header := uint64(m.Mdat[0].Size()) - m.Mdat[0].ContentSize
+		if f.shifts[0].start >= pos+header+mp4.BoxHeaderSize {
+			free := mp4.NewFreeBox(int(f.shifts[0].start - pos - header))
+			m.Free = []*mp4.FreeBox{free}
+			pos += uint64(free.Size())
+		}
+	}
+	for i, d := range m.Mdat {
+		pos += uint64(d.Size()) - d.ContentSize // header
+		f.shifts[i].delta = int64(pos) - int64(f.shifts[i].start)
+		pos += d.ContentSize
+	}
+}
+
+// move returns the offset of a chunk in the filtered media
+func (f *faststartFilter) move(offset uint64) (uint64, error) {
+	for _, s := range f.shifts {
+		if offset >= s.start && offset <= s.end {
+			return uint64(int64(offset) + s.delta), nil
+		}
+	}
+	return 0, ErrChunkOutsideMdat
+}
+
+func (f *faststartFilter) FilterMoov(m *mp4.MoovBox) error {
+	// updated by FilterMedia
+	return nil
+}
+
+func (f *faststartFilter) FilterMdat(w io.Writer, m *mp4.MdatBox) error {
+	return (&noopFilter{}).FilterMdat(w, m)
+}
@@ -0,0 +1,128 @@
+package filter
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/otherplace/mp4"
+)
+
+// trackSamples returns the data of the samples of each track of a media
+func trackSamples(t *testing.T, data []byte) [][][]byte {
+	t.Helper()
+	m, err := mp4.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := [][][]byte{}
+	for _, trak := range m.Moov.Trak {
+		tr, err := mp4.NewTrack(trak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples := [][]byte{}
+		it := tr.Samples()
+		for it.Next() {
+			s := it.Sample()
+			if s.Offset+uint64(s.Size) > uint64(len(data)) {
+				t.Fatalf("sample %d of track %d outside of the media", s.Number, tr.ID())
+			}
+			samples = append(samples, data[s.Offset:s.Offset+uint64(s.Size)])
+		}
+		if it.Err() != nil {
+			t.Fatal(it.Err())
+		}
+		l = append(l, samples)
+	}
+	return l
+}
+
+// encode encodes the media decoded from data with the filter f
+func encode(t *testing.T, data []byte, f Filter) []byte {
+	t.Helper()
+	m, err := mp4.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := EncodeFiltered(buf, m, f); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFaststart(t *testing.T) {
+	data, err := os.ReadFile("../sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := encode(t, data, Faststart())
+	// space reserved for the moov box by a free box preceding the mdat box : the media data does not move
+	const reserved = 100000
+	m, err := mp4.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withFree := &bytes.Buffer{}
+	for _, trak := range m.Moov.Trak {
+		offsets := trak.Mdia.Minf.Stbl.Stco.ChunkOffset
+		for i := range offsets {
+			// replacing the empty free box of the source
+			offsets[i] += reserved - mp4.BoxHeaderSize
+		}
+	}
+	for _, b := range []mp4.Box{m.Ftyp, mp4.NewFreeBox(reserved), m.Mdat[0], m.Moov} {
+		if err := b.Encode(withFree); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"moov last", data},
+		{"moov first", fs},
+		{"free box", withFree.Bytes()},
+	}
+	for _, tt := range tests {
+		want := trackSamples(t, tt.data)
+		out := encode(t, tt.data, Faststart())
+		m, err := mp4.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types := []string{}
+		for _, b := range m.Boxes() {
+			types = append(types, b.Type())
+		}
+		if len(types) < 3 || types[0] != "ftyp" || types[1] != "moov" || types[len(types)-1] != "mdat" {
+			t.Errorf("%s: boxes %v", tt.name, types)
+		}
+		got := trackSamples(t, out)
+		for i := range want {
+			if len(got[i]) != len(want[i]) {
+				t.Errorf("%s: track %d has %d samples, want %d", tt.name, i+1, len(got[i]), len(want[i]))
+				continue
+			}
+			for j := range want[i] {
+				if !bytes.Equal(got[i][j], want[i][j]) {
+					t.Errorf("%s: sample %d of track %d differs from the source", tt.name, j+1, i+1)
+					break
+				}
+			}
+		}
+		src, err := mp4.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.name == "free box" && m.Mdat[0].Offset() != src.Mdat[0].Offset() {
+			t.Errorf("%s: the media data moved from %d to %d", tt.name, src.Mdat[0].Offset(), m.Mdat[0].Offset())
+		}
+	}
+
+	m = &mp4.MP4{Ftyp: &mp4.FtypBox{MajorBrand: "isom", CompatibleBrands: []string{}}}
+	if err := EncodeFiltered(&bytes.Buffer{}, m, Faststart()); err != mp4.ErrBadFormat {
+		t.Errorf("no moov box: %v", err)
+	}
+}
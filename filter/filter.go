@@ -9,12 +9,27 @@ import (
 type Filter interface {
 	// Updates the moov box
 	FilterMoov(m *mp4.MoovBox) error
-	// Filters the Mdat data and writes it to w
+	// Filters the Mdat data and writes it to w, called for each mdat box
 	FilterMdat(w io.Writer, m *mp4.MdatBox) error
 }
 
-// EncodeFiltered encodes a media to a writer, filtering the media using the specified filter
+// MediaFilter is implemented by the filters that update the top-level boxes of the media (see Faststart).
+// FilterMedia is called before the other methods.
+type MediaFilter interface {
+	Filter
+	FilterMedia(m *mp4.MP4) error
+}
+
+// EncodeFiltered encodes a media to a writer, filtering the media using the specified filter.
+//
+// The boxes are encoded in the following order : ftyp, moov, the other boxes, and the mdat boxes.
 func EncodeFiltered(w io.Writer, m *mp4.MP4, f Filter) error {
+	if mf, ok := f.(MediaFilter); ok {
+		err := mf.FilterMedia(m)
+		if err != nil {
+			return err
+		}
+	}
 	err := m.Ftyp.Encode(w)
 	if err != nil {
 		return err
@@ -35,5 +50,11 @@ func EncodeFiltered(w io.Writer, m *mp4.MP4, f Filter) error {
 			}
 		}
 	}
-	return f.FilterMdat(w, m.Mdat)
+	for _, d := range m.Mdat {
+		err = f.FilterMdat(w, d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,219 @@
+package fragment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/otherplace/mp4"
+)
+
+var (
+	ErrNotFragmented = errors.New("the media is not fragmented")
+	ErrDecodeTimeGap = errors.New("decode time discontinuity")
+)
+
+// GapError is returned by Concat when the decode time (tfdt) of a fragment does not follow the previous
+// fragment of its track
+type GapError struct {
+	Segment    int // starting at 1
+	TrackID    uint32
+	DecodeTime uint64 // of the fragment
+	Expected   uint64 // end of the previous fragment
+}
+
+func (e *GapError) Error() string {
+	return fmt.Sprintf("segment %d, track %d: decode time %d, the previous fragment ending at %d", e.Segment,
+		e.TrackID, e.DecodeTime, e.Expected)
+}
+
+func (e *GapError) Unwrap() error {
+	return ErrDecodeTimeGap
+}
+
+// ConcatOptions are the options of Concat
+type ConcatOptions struct {
+	// Accept the gaps and overlaps between the decode times of the fragments
+	AllowGaps bool
+	// Add a mfra box at the end of the media, indexing the first sync sample of each fragment of each track
+	Index bool
+}
+
+// countingWriter counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += uint64(n)
+	return n, err
+}
+
+// Concat joins an init segment and its media segments into a single fragmented media. The fragments are
+// renumbered (mfhd) in their order, their decode times (tfdt) are checked to follow each other, and the
+// styp, sidx and mfra boxes of the segments are removed.
+//
+// The moof boxes of the inputs are modified : their sequence numbers (mfhd) and the base data offsets of their
+// track fragments (tfhd) become the ones of the joined media, and the inputs must be decoded again to be
+// encoded on their own.
+func Concat(w io.Writer, init *mp4.MP4, segments []*mp4.MP4, o ConcatOptions) error {
+	if init.Moov == nil {
+		return mp4.ErrNoMovie
+	}
+	if init.Moov.Mvex == nil {
+		return ErrNotFragmented
+	}
+	tracks := []*mp4.Track{}
+	for _, trak := range init.Moov.Trak {
+		t, err := mp4.NewTrack(trak)
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, t)
+	}
+	next := map[uint32]uint64{} // decode time of the next fragment, by track
+	tfra := map[uint32]*mp4.TfraBox{}
+	var sequence uint32
+	cw := &countingWriter{w: w}
+	for k, src := range append([]*mp4.MP4{init}, segments...) {
+		for _, b := range src.Boxes() {
+			switch b.Type() {
+			case "ftyp", "moov":
+				if k > 0 {
+					continue
+				}
+			case "styp", "sidx", "mfra":
+				continue
+			case "moof":
+				moof := b.(*mp4.MoofBox)
+				sequence++
+				if moof.Mfhd == nil {
+					moof.Mfhd = &mp4.MfhdBox{}
+				}
+				moof.Mfhd.SequenceNumber = sequence
+				for _, traf := range moof.Traf {
+					if traf.Tfhd != nil && traf.Tfhd.Flags[2]&mp4.BaseDataOffsetPresent != 0 {
+						// absolute offset, in the segment
+						traf.Tfhd.BaseDataOffset = uint64(int64(traf.Tfhd.BaseDataOffset) + int64(cw.n) - moof.Offset())
+					}
+				}
+				for _, t := range tracks {
+					err := fragmentTimes(t, init.Moov.Mvex, moof, k, next, o.AllowGaps)
+					if err != nil {
+						return err
+					}
+					if o.Index {
+						index(tfra, t, moof, cw.n)
+					}
+				}
+			}
+			err := b.Encode(cw)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !o.Index {
+		return nil
+	}
+	mfra := &mp4.MfraBox{Mfro: &mp4.MfroBox{Flags: []byte{0, 0, 0}}}
+	for _, t := range tracks {
+		if b, ok := tfra[t.ID()]; ok {
+			mfra.Tfra = append(mfra.Tfra, b)
+		}
+	}
+	mfra.Mfro.MSize = uint32(mfra.Size())
+	return mfra.Encode(cw)
+}
+
+// fragmentTimes adds the samples of a moof box to a track, and checks that its decode time follows the
+// previous fragment of the track
+func fragmentTimes(t *mp4.Track, mvex *mp4.MvexBox, moof *mp4.MoofBox, segment int, next map[uint32]uint64, gaps bool) error {
+	n := t.SampleCount()
+	err := t.AddFragments(mvex, moof)
+	if err != nil {
+		return err
+	}
+	if t.SampleCount() == n {
+		return nil
+	}
+	first, err := t.Sample(n + 1)
+	if err != nil {
+		return err
+	}
+	last, err := t.Sample(t.SampleCount())
+	if err != nil {
+		return err
+	}
+	if expected, ok := next[t.ID()]; ok && first.DecodeTime != expected && !gaps {
+		return &GapError{Segment: segment, TrackID: t.ID(), DecodeTime: first.DecodeTime, Expected: expected}
+	}
+	next[t.ID()] = last.DecodeTime + uint64(last.Duration)
+	return nil
+}
+
+// index adds the first sync sample of a track in a moof box, at offset in the media, to the tfra box of
+// the track. The samples of the moof box are the last ones added to the track.
+func index(tfra map[uint32]*mp4.TfraBox, t *mp4.Track, moof *mp4.MoofBox, offset uint64) {
+	// samples of the track in the moof box
+	count := 0
+	for _, traf := range moof.Traf {
+		if traf.Tfhd != nil && traf.Tfhd.TrackId == t.ID() {
+			for _, trun := range traf.Trun {
+				count += len(trun.Samples)
+			}
+		}
+	}
+	first := t.SampleCount() - uint32(count) + 1
+	for n := first; n <= t.SampleCount(); n++ {
+		s, err := t.Sample(n)
+		if err != nil || !s.IsSync {
+			continue
+		}
+		e := &mp4.TfraEntry{Time: s.DecodeTime, MoofOffset: offset}
+		// numbers of the traf, trun and sample, starting at 1
+		left := int(n - first)
+		for i, traf := range moof.Traf {
+			if traf.Tfhd == nil || traf.Tfhd.TrackId != t.ID() || e.TrafNumber != 0 {
+				continue
+			}
+			for j, trun := range traf.Trun {
+				if left < len(trun.Samples) {
+					e.TrafNumber, e.TrunNumber, e.SampleNumber = uint32(i+1), uint32(j+1), uint32(left+1)
+					break
+				}
+				left -= len(trun.Samples)
+			}
+		}
+		b, ok := tfra[t.ID()]
+		if !ok {
+			b = &mp4.TfraBox{Flags: []byte{0, 0, 0}, TrackId: t.ID()}
+			tfra[t.ID()] = b
+		}
+		b.Entries = append(b.Entries, e)
+		b.NumberOfTfraEntry = uint32(len(b.Entries))
+		if e.Time > math.MaxUint32 || e.MoofOffset > math.MaxUint32 {
+			b.Version = 1
+		}
+		b.LengthSizeOfTrafNum = max(b.LengthSizeOfTrafNum, lengthSize(e.TrafNumber))
+		b.LengthSizeOfTrunNum = max(b.LengthSizeOfTrunNum, lengthSize(e.TrunNumber))
+		b.LengthSizeOfSampleNum = max(b.LengthSizeOfSampleNum, lengthSize(e.SampleNumber))
+		return
+	}
+}
+
+// lengthSize returns the number of bytes minus one needed to store a number in a tfra entry
+func lengthSize(n uint32) uint8 {
+	switch {
+	case n > 0xffffff:
+		return 3
+	case n > 0xffff:
+		return 2
+	case n > 0xff:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,388 @@
+package fragment
+
+import (
+	"io"
+	"math"
+	"sort"
+
+	"github.com/otherplace/mp4"
+)
+
+// segmentBrands are the brands of the fragmented media, removed from the progressive media
+var segmentBrands = map[string]bool{"dash": true, "msdh": true, "msix": true, "iso6": true}
+
+// chunk is a run of contiguous samples of a track, sharing the same sample description
+type chunk struct {
+	track       int
+	count       int    // of samples
+	description uint32 // index of the sample description
+	offset      uint64 // of the data in the source
+	size        uint64
+	pos         uint64 // of the data in the mdat box of the progressive media
+	mdat        *mp4.MdatBox
+	mdatOffset  uint64 // of the data in mdat
+}
+
+// Defragment flattens a fragmented media into a progressive media. init is the init segment, followed by
+// its media segments, or a fragmented file without segments. The sample tables of the tracks (stts, ctts,
+// stss, stsc, stsz, stco or co64) are rebuilt from the track runs, after the samples of the sample tables of
+// init if any.
+//
+// The returned media holds the ftyp, moov and mdat boxes, the mdat box referencing the data of init and of
+// the segments, that must stay readable until the media is encoded. The decode times of the tracks start at
+// 0 : the decode time of the first fragment (tfdt) is not kept, nor the gaps between the fragments. The
+// offsets between the starts of the tracks are kept : the tracks starting after the earliest one begin with
+// an empty edit.
+func Defragment(init *mp4.MP4, segments ...*mp4.MP4) (*mp4.MP4, error) {
+	if init.Moov == nil {
+		return nil, mp4.ErrNoMovie
+	}
+	moov, err := copyMoov(init.Moov)
+	if err != nil {
+		return nil, err
+	}
+	moov.Mvex = nil
+
+	sources := append([]*mp4.MP4{init}, segments...)
+	samples := make([][]mp4.SampleInfo, len(moov.Trak))
+	chunks := []*chunk{}
+	for _, src := range sources {
+		spans := src.MdatSpans()
+		// chunks of the source, by track in decoding order
+		queues := make([][]*chunk, len(moov.Trak))
+		for i, trak := range init.Moov.Trak {
+			t, err := mp4.NewTrack(trak)
+			if err != nil {
+				return nil, err
+			}
+			first := uint32(1)
+			if src != init {
+				// the samples of the sample tables are the ones of init
+				first = t.SampleCount() + 1
+			}
+			err = t.AddFragments(init.Moov.Mvex, src.Moof...)
+			if err != nil {
+				return nil, err
+			}
+			var c *chunk
+			it := t.Samples()
+			for it.Next() {
+				s := it.Sample()
+				if s.Number < first {
+					continue
+				}
+				samples[i] = append(samples[i], s)
+				if c != nil && c.offset+c.size == s.Offset && c.description == s.DescriptionIndex {
+					c.count++
+					c.size += uint64(s.Size)
+					continue
+				}
+				c = &chunk{track: i, count: 1, description: s.DescriptionIndex, offset: s.Offset, size: uint64(s.Size)}
+				if !c.locate(spans) {
+					return nil, ErrSampleOutsideMdat
+				}
+				queues[i] = append(queues[i], c)
+			}
+			if it.Err() != nil {
+				return nil, it.Err()
+			}
+		}
+		chunks = append(chunks, interleave(queues)...)
+	}
+
+	// the chunks grow sample by sample once located : they must stay in their mdat box
+	var size uint64
+	for _, c := range chunks {
+		if c.mdatOffset+c.size > c.mdat.ContentSize {
+			return nil, ErrSampleOutsideMdat
+		}
+		c.pos = size
+		size += c.size
+	}
+	for i, trak := range moov.Trak {
+		l := []*chunk{}
+		for _, c := range chunks {
+			if c.track == i {
+				l = append(l, c)
+			}
+		}
+		sampleTables(trak.Mdia.Minf.Stbl, samples[i], l)
+	}
+	delays(moov, samples)
+	durations(moov, samples, init.Moov.Mvex != nil)
+
+	m := &mp4.MP4{
+		Ftyp: progressiveFtyp(init.Ftyp),
+		Moov: moov,
+		Mdat: []*mp4.MdatBox{mp4.NewMdatBox(&chunkReader{chunks}, 0, size)},
+	}
+	// the moov box grows when stco boxes are replaced by co64 boxes
+	for {
+		moovSize := moov.Size()
+		start := uint64(m.Ftyp.Size()+moov.Size()+m.Mdat[0].Size()) - size
+		for i, trak := range moov.Trak {
+			offsets := []uint64{}
+			for _, c := range chunks {
+				if c.track == i {
+					offsets = append(offsets, start+c.pos)
+				}
+			}
+			trak.Mdia.Minf.Stbl.SetChunkOffsets(offsets)
+		}
+		if moov.Size() == moovSize {
+			return m, nil
+		}
+	}
+}
+
+// locate finds the mdat box holding the first sample of the chunk
+func (c *chunk) locate(spans []mp4.MdatSpan) bool {
+	for _, s := range spans {
+		if c.offset >= s.Start && c.offset+c.size <= s.End {
+			c.mdat, c.mdatOffset = s.Mdat, c.offset-s.Start
+			return true
+		}
+	}
+	return false
+}
+
+// interleave merges the chunks of the tracks, in the order of their data in the source, the chunks of each
+// track staying in decoding order
+func interleave(queues [][]*chunk) []*chunk {
+	l := []*chunk{}
+	for {
+		next := -1
+		for i, q := range queues {
+			if len(q) > 0 && (next < 0 || q[0].offset < queues[next][0].offset) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return l
+		}
+		l = append(l, queues[next][0])
+		queues[next] = queues[next][1:]
+	}
+}
+
+// sampleTables rebuilds the sample tables of a track from its samples and its chunks, but the chunk offsets
+func sampleTables(stbl *mp4.StblBox, samples []mp4.SampleInfo, chunks []*chunk) {
+	stts := &mp4.SttsBox{SampleCount: []uint32{}, SampleTimeDelta: []uint32{}}
+	ctts := &mp4.CttsBox{SampleCount: []uint32{}, SampleOffset: []int32{}}
+	stss := &mp4.StssBox{SampleNumber: []uint32{}}
+	stsz := &mp4.StszBox{SampleSize: []uint32{}}
+	hasCtts, allSync := false, true
+	for i, s := range samples {
+		if n := len(stts.SampleCount); n > 0 && stts.SampleTimeDelta[n-1] == s.Duration {
+			stts.SampleCount[n-1]++
+		} else {
+			stts.SampleCount = append(stts.SampleCount, 1)
+			stts.SampleTimeDelta = append(stts.SampleTimeDelta, s.Duration)
+		}
+		cto := int32(s.PresentationTime - int64(s.DecodeTime))
+		if cto != 0 {
+			hasCtts = true
+		}
+		if cto < 0 {
+			ctts.Version = 1
+		}
+		if n := len(ctts.SampleCount); n > 0 && ctts.SampleOffset[n-1] == cto {
+			ctts.SampleCount[n-1]++
+		} else {
+			ctts.SampleCount = append(ctts.SampleCount, 1)
+			ctts.SampleOffset = append(ctts.SampleOffset, cto)
+		}
+		if s.IsSync {
+			stss.SampleNumber = append(stss.SampleNumber, uint32(i+1))
+		} else {
+			allSync = false
+		}
+		stsz.SampleSize = append(stsz.SampleSize, s.Size)
+	}
+	stsz.SampleNumber = uint32(len(samples))
+	if len(samples) > 0 {
+		uniform := true
+		for _, sz := range stsz.SampleSize {
+			uniform = uniform && sz == stsz.SampleSize[0]
+		}
+		if uniform {
+			stsz.SampleUniformSize, stsz.SampleSize = stsz.SampleSize[0], []uint32{}
+		}
+	}
+	ctts.EntryCount = uint32(len(ctts.SampleCount))
+
+	stsc := &mp4.StscBox{FirstChunk: []uint32{}, SamplesPerChunk: []uint32{}, SampleDescriptionID: []uint32{}}
+	for i, c := range chunks {
+		n := len(stsc.FirstChunk)
+		if n > 0 && stsc.SamplesPerChunk[n-1] == uint32(c.count) && stsc.SampleDescriptionID[n-1] == c.description {
+			continue
+		}
+		stsc.FirstChunk = append(stsc.FirstChunk, uint32(i+1))
+		stsc.SamplesPerChunk = append(stsc.SamplesPerChunk, uint32(c.count))
+		stsc.SampleDescriptionID = append(stsc.SampleDescriptionID, c.description)
+	}
+
+	stbl.Stts, stbl.Stsz, stbl.Stsc = stts, stsz, stsc
+	stbl.Ctts, stbl.Stss, stbl.Sbgp = nil, nil, nil
+	if hasCtts {
+		stbl.Ctts = ctts
+	}
+	if !allSync {
+		stbl.Stss = stss
+	}
+}
+
+// delays keeps the offsets between the starts of the tracks (the decode times of their first samples), their
+// decode times starting at 0 : the tracks starting after the earliest one begin with an empty edit of the
+// offset
+func delays(moov *mp4.MoovBox, samples [][]mp4.SampleInfo) {
+	if moov.Mvhd == nil || moov.Mvhd.Timescale == 0 {
+		return
+	}
+	// in the timescale of the movie
+	starts := make([]uint64, len(moov.Trak))
+	first := uint64(math.MaxUint64)
+	for i, trak := range moov.Trak {
+		starts[i] = math.MaxUint64
+		if len(samples[i]) == 0 || trak.Mdia.Mdhd.Timescale == 0 {
+			continue
+		}
+		starts[i] = samples[i][0].DecodeTime * uint64(moov.Mvhd.Timescale) / uint64(trak.Mdia.Mdhd.Timescale)
+		first = min(first, starts[i])
+	}
+	for i, trak := range moov.Trak {
+		if starts[i] == math.MaxUint64 || starts[i] == first {
+			continue
+		}
+		delay := starts[i] - first
+		if trak.Edts == nil || trak.Edts.Elst == nil {
+			// the media presented from its beginning, up to its end (see durations)
+			trak.Edts = &mp4.EdtsBox{Elst: &mp4.ElstBox{
+				SegmentDuration:   []uint64{0},
+				MediaTime:         []int64{0},
+				MediaRateInteger:  []uint16{1},
+				MediaRateFraction: []uint16{0},
+			}}
+		}
+		elst := trak.Edts.Elst
+		if len(elst.MediaTime) > 0 && len(elst.SegmentDuration) > 0 && elst.MediaTime[0] == -1 {
+			// already delayed
+			elst.SegmentDuration[0] += delay
+			continue
+		}
+		elst.SegmentDuration = append([]uint64{delay}, elst.SegmentDuration...)
+		elst.MediaTime = append([]int64{-1}, elst.MediaTime...)
+		elst.MediaRateInteger = append([]uint16{1}, elst.MediaRateInteger...)
+		elst.MediaRateFraction = append([]uint16{0}, elst.MediaRateFraction...)
+	}
+}
+
+// durations sets the durations of the movie and of its tracks from their samples. The last edit of the
+// fragmented media (fragmented set) lasting to the end of the track (0 duration) is given the duration of the
+// samples it covers.
+func durations(moov *mp4.MoovBox, samples [][]mp4.SampleInfo, fragmented bool) {
+	var movie uint64
+	for i, trak := range moov.Trak {
+		var d uint64
+		for _, s := range samples[i] {
+			d += uint64(s.Duration)
+		}
+		mdhd := trak.Mdia.Mdhd
+		mdhd.Duration = d
+		if d > math.MaxUint32 {
+			mdhd.Version = 1
+		}
+		if mdhd.Timescale == 0 || moov.Mvhd == nil || trak.Tkhd == nil {
+			continue
+		}
+		// in the timescale of the movie
+		scale := func(d uint64) uint64 {
+			return d * uint64(moov.Mvhd.Timescale) / uint64(mdhd.Timescale)
+		}
+		if trak.Edts != nil && trak.Edts.Elst != nil {
+			elst := trak.Edts.Elst
+			last := -1
+			for j, t := range elst.MediaTime {
+				if t >= 0 {
+					last = j
+				}
+			}
+			if fragmented && last >= 0 && elst.SegmentDuration[last] == 0 && uint64(elst.MediaTime[last]) <= mdhd.Duration {
+				elst.SegmentDuration[last] = scale(mdhd.Duration - uint64(elst.MediaTime[last]))
+			}
+			d = 0
+			for _, sd := range elst.SegmentDuration {
+				d += sd
+			}
+			if d > math.MaxUint32 {
+				elst.Version = 1
+			}
+		} else {
+			d = scale(d)
+		}
+		trak.Tkhd.Duration = d
+		if d > math.MaxUint32 {
+			trak.Tkhd.Version = 1
+		}
+		if d > movie {
+			movie = d
+		}
+	}
+	if moov.Mvhd != nil {
+		moov.Mvhd.Duration = movie
+		if movie > math.MaxUint32 {
+			moov.Mvhd.Version = 1
+		}
+	}
+}
+
+// progressiveFtyp returns the ftyp box of the progressive media
+func progressiveFtyp(src *mp4.FtypBox) *mp4.FtypBox {
+	ftyp := &mp4.FtypBox{MajorBrand: "isom", MinorVersion: 512, CompatibleBrands: []string{}}
+	if src == nil {
+		ftyp.CompatibleBrands = append(ftyp.CompatibleBrands, "isom")
+		return ftyp
+	}
+	if !segmentBrands[src.MajorBrand] {
+		ftyp.MajorBrand, ftyp.MinorVersion = src.MajorBrand, src.MinorVersion
+	}
+	for _, b := range src.CompatibleBrands {
+		if !segmentBrands[b] {
+			ftyp.CompatibleBrands = append(ftyp.CompatibleBrands, b)
+		}
+	}
+	if !hasBrand(ftyp.CompatibleBrands, ftyp.MajorBrand) {
+		ftyp.CompatibleBrands = append(ftyp.CompatibleBrands, ftyp.MajorBrand)
+	}
+	return ftyp
+}
+
+// chunkReader reads the data of the chunks, in the mdat boxes of their source
+type chunkReader struct {
+	chunks []*chunk
+}
+
+func (r *chunkReader) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		i := sort.Search(len(r.chunks), func(i int) bool { return r.chunks[i].pos+r.chunks[i].size > pos })
+		if i == len(r.chunks) {
+			return n, io.EOF
+		}
+		c := r.chunks[i]
+		l := c.pos + c.size - pos
+		if l > uint64(len(p)-n) {
+			l = uint64(len(p) - n)
+		}
+		k, err := c.mdat.ReadAt(p[n:n+int(l)], int64(c.mdatOffset+pos-c.pos))
+		n += k
+		if err != nil && (err != io.EOF || k < int(l)) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
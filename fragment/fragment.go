@@ -0,0 +1,145 @@
+// Package fragment converts a progressive media into a fragmented media (fMP4) : an init segment (ftyp and
+// moov, without samples) followed by movie fragments, each made of a moof box and of the mdat box holding
+// its samples.
+//
+//	f, err := fragment.New(m, fragment.Options{Duration: 4 * time.Second})
+//	// a single file
+//	err = f.Encode(w)
+//	// or an init segment and media segments
+//	err = f.EncodeInit(w)
+//	for i := 0; i < f.Count(); i++ {
+//		err = f.EncodeSegment(w, i)
+//	}
+//
+// The fragments start at the sync samples of a reference track (the first video track by default), the
+// samples of the other tracks being cut at the same decode times.
+//
+// Defragment does the reverse, and converts a fragmented media into a progressive media. Concat joins an
+// init segment and its media segments into a single fragmented media.
+package fragment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/otherplace/mp4"
+)
+
+var (
+	ErrFragmented        = errors.New("the media is already fragmented")
+	ErrInvalidFragment   = errors.New("invalid fragment number")
+	ErrSampleOutsideMdat = errors.New("sample outside of the mdat boxes")
+)
+
+// DefaultDuration is the target duration of the fragments when not set in the options
+const DefaultDuration = 2 * time.Second
+
+// Options of the fragmentation
+type Options struct {
+	// Target duration of the fragments : a fragment ends at the first sync sample of the reference track
+	// following this duration
+	Duration time.Duration
+	// ID of the reference track, the first video track (or the first track) when 0
+	ReferenceTrack uint32
+}
+
+// track holds the samples of a track of the source media
+type track struct {
+	trak    *mp4.TrakBox
+	id      uint32
+	scale   uint32
+	samples []mp4.SampleInfo
+	// index of the first sample of each fragment, followed by the number of samples
+	starts []int
+}
+
+// Fragmenter splits the samples of a media in movie fragments
+type Fragmenter struct {
+	media  *mp4.MP4
+	tracks []*track
+	count  int
+	spans  []mp4.MdatSpan
+}
+
+// New returns a Fragmenter for a progressive media, which mdat boxes must stay readable until the fragments
+// are encoded (see mp4.MdatBox). The media is not modified.
+func New(m *mp4.MP4, o Options) (*Fragmenter, error) {
+	if m.Moov == nil {
+		return nil, mp4.ErrNoMovie
+	}
+	if m.Moov.Mvex != nil || len(m.Moof) > 0 {
+		return nil, ErrFragmented
+	}
+	if o.Duration <= 0 {
+		o.Duration = DefaultDuration
+	}
+	f := &Fragmenter{media: m, spans: m.MdatSpans()}
+	var ref *track
+	for _, trak := range m.Moov.Trak {
+		t, err := mp4.NewTrack(trak)
+		if err != nil {
+			return nil, err
+		}
+		tr := &track{trak: trak, id: t.ID(), scale: t.Timescale()}
+		it := t.Samples()
+		for it.Next() {
+			tr.samples = append(tr.samples, it.Sample())
+		}
+		if it.Err() != nil {
+			return nil, it.Err()
+		}
+		f.tracks = append(f.tracks, tr)
+		switch {
+		case o.ReferenceTrack != 0:
+			if tr.id == o.ReferenceTrack {
+				ref = tr
+			}
+		case ref == nil || (!isVideo(ref.trak) && isVideo(trak)):
+			ref = tr
+		}
+	}
+	if ref == nil {
+		return nil, mp4.ErrTrackNotFound
+	}
+	f.cut(ref, o.Duration)
+	return f, nil
+}
+
+// cut splits the samples of the tracks at the sync samples of the reference track
+func (f *Fragmenter) cut(ref *track, d time.Duration) {
+	// decode times of the fragments, in the timescale of the reference track
+	target := uint64(d) * uint64(ref.scale) / uint64(time.Second)
+	times := []uint64{}
+	for i, s := range ref.samples {
+		if i == 0 {
+			times = append(times, s.DecodeTime)
+		} else if s.IsSync && s.DecodeTime-times[len(times)-1] >= target {
+			times = append(times, s.DecodeTime)
+		}
+	}
+	f.count = len(times)
+	if f.count == 0 {
+		f.count = 1
+	}
+	for _, t := range f.tracks {
+		t.starts = []int{0}
+		i := 0
+		for k := 1; k < f.count; k++ {
+			// before the fragment k when dts / t.scale < times[k] / ref.scale
+			for i < len(t.samples) && t.samples[i].DecodeTime*uint64(ref.scale) < times[k]*uint64(t.scale) {
+				i++
+			}
+			t.starts = append(t.starts, i)
+		}
+		t.starts = append(t.starts, len(t.samples))
+	}
+}
+
+// Count returns the number of fragments
+func (f *Fragmenter) Count() int {
+	return f.count
+}
+
+func isVideo(trak *mp4.TrakBox) bool {
+	return trak.Mdia != nil && trak.Mdia.Hdlr != nil && trak.Mdia.Hdlr.HandlerType == "vide"
+}
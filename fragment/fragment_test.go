@@ -0,0 +1,386 @@
+package fragment
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/otherplace/mp4"
+)
+
+// sample is a sample of a track, with its data, its decode time being relative to the first sample of the
+// track
+type sample struct {
+	decodeTime       uint64
+	compositionShift int64
+	duration         uint32
+	sync             bool
+	description      uint32
+	data             string
+}
+
+// readFile decodes a media of the sample directory, and returns it with its content
+func readFile(t *testing.T, name string) (*mp4.MP4, []byte) {
+	t.Helper()
+	data, err := os.ReadFile("../sample/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decode(t, data), data
+}
+
+// decode decodes a media
+func decode(t *testing.T, data []byte) *mp4.MP4 {
+	t.Helper()
+	m, err := mp4.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// mediaSamples returns the samples of each track of a media, made of an init segment (or a single file) and
+// of its media segments, given with their content
+func mediaSamples(t *testing.T, init *mp4.MP4, initData []byte, segments []*mp4.MP4, segmentData [][]byte) [][]sample {
+	t.Helper()
+	l := [][]sample{}
+	for _, trak := range init.Moov.Trak {
+		samples := []sample{}
+		var start uint64
+		for k, src := range append([]*mp4.MP4{init}, segments...) {
+			data := initData
+			if k > 0 {
+				data = segmentData[k-1]
+			}
+			tr, err := mp4.NewTrack(trak)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := uint32(1)
+			if k > 0 {
+				first = tr.SampleCount() + 1
+			}
+			if err := tr.AddFragments(init.Moov.Mvex, src.Moof...); err != nil {
+				t.Fatal(err)
+			}
+			it := tr.Samples()
+			for it.Next() {
+				s := it.Sample()
+				if s.Number < first {
+					continue
+				}
+				if s.Offset+uint64(s.Size) > uint64(len(data)) {
+					t.Fatalf("track %d: sample %d outside of the media", tr.ID(), s.Number)
+				}
+				if len(samples) == 0 {
+					start = s.DecodeTime
+				}
+				samples = append(samples, sample{
+					decodeTime:       s.DecodeTime - start,
+					compositionShift: s.PresentationTime - int64(s.DecodeTime),
+					duration:         s.Duration,
+					sync:             s.IsSync,
+					description:      s.DescriptionIndex,
+					data:             string(data[s.Offset : s.Offset+uint64(s.Size)]),
+				})
+			}
+			if it.Err() != nil {
+				t.Fatal(it.Err())
+			}
+		}
+		l = append(l, samples)
+	}
+	return l
+}
+
+// compareSamples compares the samples of each track of a media to the expected ones
+func compareSamples(t *testing.T, name string, got, want [][]sample) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d tracks, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Errorf("%s: track %d has %d samples, want %d", name, i+1, len(got[i]), len(want[i]))
+			continue
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				g, w := got[i][j], want[i][j]
+				t.Errorf("%s: sample %d of track %d: decode time %d, shift %d, duration %d, sync %v, %d bytes, want %d, %d, %d, %v, %d bytes",
+					name, j+1, i+1, g.decodeTime, g.compositionShift, g.duration, g.sync, len(g.data),
+					w.decodeTime, w.compositionShift, w.duration, w.sync, len(w.data))
+				break
+			}
+		}
+	}
+}
+
+func TestFragment(t *testing.T) {
+	src, data := readFile(t, "meta.test1.mp4")
+	want := mediaSamples(t, src, data, nil, nil)
+	tests := []struct {
+		name      string
+		options   Options
+		fragments int
+	}{
+		// a single sync sample in the video track
+		{"default", Options{}, 1},
+		{"1s", Options{Duration: time.Second}, 1},
+		{"audio reference", Options{Duration: time.Second, ReferenceTrack: 2}, 2},
+		{"audio reference, 500ms", Options{Duration: 500 * time.Millisecond, ReferenceTrack: 2}, 4},
+	}
+	for _, tt := range tests {
+		m, _ := readFile(t, "meta.test1.mp4")
+		f, err := New(m, tt.options)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if f.Count() != tt.fragments {
+			t.Errorf("%s: %d fragments, want %d", tt.name, f.Count(), tt.fragments)
+		}
+		// a single file
+		buf := &bytes.Buffer{}
+		if err := f.Encode(buf); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		out := decode(t, buf.Bytes())
+		if len(out.Moof) != f.Count() {
+			t.Errorf("%s: %d moof boxes, want %d", tt.name, len(out.Moof), f.Count())
+		}
+		compareSamples(t, tt.name, mediaSamples(t, out, buf.Bytes(), nil, nil), want)
+
+		// an init segment and media segments
+		buf = &bytes.Buffer{}
+		if err := f.EncodeInit(buf); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		init, initData := decode(t, buf.Bytes()), buf.Bytes()
+		segments, segmentData := []*mp4.MP4{}, [][]byte{}
+		for i := 0; i < f.Count(); i++ {
+			buf := &bytes.Buffer{}
+			if err := f.EncodeSegment(buf, i); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			segments, segmentData = append(segments, decode(t, buf.Bytes())), append(segmentData, buf.Bytes())
+		}
+		got := mediaSamples(t, init, initData, segments, segmentData)
+		compareSamples(t, tt.name+" segments", got, want)
+		// the fragments start at the sync samples of the reference track
+		for _, s := range segments {
+			tr, err := mp4.NewTrack(init.Moov.Trak[0])
+			if tt.options.ReferenceTrack != 0 {
+				tr, err = mp4.NewTrack(init.Moov.Trak[tt.options.ReferenceTrack-1])
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tr.AddFragments(init.Moov.Mvex, s.Moof...); err != nil {
+				t.Fatal(err)
+			}
+			if first, err := tr.Sample(1); err != nil || !first.IsSync {
+				t.Errorf("%s: the fragment does not start with a sync sample (%v)", tt.name, err)
+			}
+		}
+	}
+
+	init, _ := readFile(t, "hls-init.mp4")
+	if _, err := New(init, Options{}); err != ErrFragmented {
+		t.Errorf("fragmented media: %v", err)
+	}
+}
+
+func TestInit(t *testing.T) {
+	m, _ := readFile(t, "meta.test1.mp4")
+	stbl := m.Moov.Trak[0].Mdia.Minf.Stbl
+	stbl.Boxes = append(stbl.Boxes,
+		&mp4.UkwnBox{Header: mp4.BoxHeader{Type: "sdtp"}, Data: make([]byte, 4+stbl.Stsz.SampleNumber)},
+		&mp4.UkwnBox{Header: mp4.BoxHeader{Type: "tst1"}, Data: []byte{1, 2}})
+	f, err := New(m, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	init, err := f.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stbl = init.Moov.Trak[0].Mdia.Minf.Stbl
+	if len(stbl.Boxes) != 1 || stbl.Boxes[0].Type() != "tst1" {
+		t.Errorf("the per-sample boxes of the sample table are kept: %v", stbl.Boxes)
+	}
+	if stbl.Stss != nil || stbl.Ctts != nil || stbl.Sgpd != nil || len(stbl.Sbgp) != 0 {
+		t.Errorf("the per-sample boxes of the sample table are kept")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := f.EncodeSegment(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if styp := decode(t, buf.Bytes()).Styp; styp == nil || len(styp.CompatibleBrands) != 1 || styp.CompatibleBrands[0] != "msdh" {
+		t.Errorf("styp %+v, want the msdh brand only", styp)
+	}
+}
+
+func TestDefragment(t *testing.T) {
+	// a fragmented file of meta.test1
+	m, data := readFile(t, "meta.test1.mp4")
+	want := mediaSamples(t, m, data, nil, nil)
+	f, err := New(m, Options{Duration: 500 * time.Millisecond, ReferenceTrack: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := f.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	fragmented := buf.Bytes()
+
+	tests := []struct {
+		name     string
+		init     string
+		segments []string
+	}{
+		{"hls", "hls-init.mp4", []string{"hls_sequence_00000.m4s"}},
+		{"hls late segment", "hls-init.mp4", []string{"hls_sequence_00299.m4s"}},
+		{"dash video", "dash-init-stream0.m4s", []string{"dash-chunk-stream0-00001.m4s"}},
+		{"dash audio", "dash-init-stream3.m4s", []string{"dash-chunk-stream3-00001.m4s"}},
+		{"fragmented file", "", nil},
+	}
+	for _, tt := range tests {
+		var init *mp4.MP4
+		var initData []byte
+		segments, segmentData := []*mp4.MP4{}, [][]byte{}
+		expected := want
+		if tt.init == "" {
+			init, initData = decode(t, fragmented), fragmented
+		} else {
+			init, initData = readFile(t, tt.init)
+			for _, name := range tt.segments {
+				s, data := readFile(t, name)
+				segments, segmentData = append(segments, s), append(segmentData, data)
+			}
+			expected = mediaSamples(t, init, initData, segments, segmentData)
+		}
+		out, err := Defragment(init, segments...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := out.Encode(buf); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		m := decode(t, buf.Bytes())
+		if m.Moov.Mvex != nil || len(m.Moof) > 0 {
+			t.Errorf("%s: the media is still fragmented", tt.name)
+		}
+		compareSamples(t, tt.name, mediaSamples(t, m, buf.Bytes(), nil, nil), expected)
+		// the tracks keep their offsets
+		from, to := startTimes(t, init, segments), startTimes(t, m, nil)
+		for i := range from {
+			shift := to[i] - from[i] - (to[0] - from[0])
+			if shift < -time.Millisecond || shift > time.Millisecond {
+				t.Errorf("%s: track %d starts at %v, %v in the source (track 1 at %v, %v)", tt.name, i+1, to[i], from[i],
+					to[0], from[0])
+			}
+		}
+	}
+
+	// the last edit of a fragmented media, lasting to the end of the track (0) or not
+	for _, d := range []uint64{0, 1000} {
+		init := decode(t, fragmented)
+		elst := init.Moov.Trak[0].Edts.Elst
+		last := len(elst.SegmentDuration) - 1
+		elst.SegmentDuration[last] = d
+		out, err := Defragment(init)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trak := out.Moov.Trak[0]
+		want := d
+		if d == 0 {
+			mdhd := trak.Mdia.Mdhd
+			want = (mdhd.Duration - uint64(elst.MediaTime[last])) * uint64(out.Moov.Mvhd.Timescale) / uint64(mdhd.Timescale)
+		}
+		if got := trak.Edts.Elst.SegmentDuration[last]; got != want || want == 0 {
+			t.Errorf("edit duration %d, want %d", got, want)
+		}
+	}
+}
+
+// startTimes returns the presentation time of the first sample of each track of a media, made of an init
+// segment (or a single file) and of its media segments
+func startTimes(t *testing.T, init *mp4.MP4, segments []*mp4.MP4) []time.Duration {
+	t.Helper()
+	l := []time.Duration{}
+	for _, trak := range init.Moov.Trak {
+		tr, err := mp4.NewTrack(trak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.MovieTimescale = init.Moov.Mvhd.Timescale
+		first, moof := uint32(1), init.Moof
+		if len(segments) > 0 {
+			first, moof = tr.SampleCount()+1, segments[0].Moof
+		}
+		if err := tr.AddFragments(init.Moov.Mvex, moof...); err != nil {
+			t.Fatal(err)
+		}
+		d, err := tr.TimeOfSample(first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l = append(l, d)
+	}
+	return l
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		segments []string
+		options  ConcatOptions
+		err      error
+	}{
+		{"hls", "hls-init.mp4", []string{"hls_sequence_00000.m4s"}, ConcatOptions{}, nil},
+		{"dash", "dash-init-stream0.m4s", []string{"dash-chunk-stream0-00001.m4s"}, ConcatOptions{Index: true}, nil},
+		{"gap", "hls-init.mp4", []string{"hls_sequence_00000.m4s", "hls_sequence_00299.m4s"}, ConcatOptions{}, ErrDecodeTimeGap},
+		{"gap allowed", "hls-init.mp4", []string{"hls_sequence_00000.m4s", "hls_sequence_00299.m4s"}, ConcatOptions{AllowGaps: true}, nil},
+		{"not fragmented", "meta.test1.mp4", nil, ConcatOptions{}, ErrNotFragmented},
+	}
+	for _, tt := range tests {
+		init, initData := readFile(t, tt.init)
+		segments, segmentData := []*mp4.MP4{}, [][]byte{}
+		for _, name := range tt.segments {
+			s, data := readFile(t, name)
+			segments, segmentData = append(segments, s), append(segmentData, data)
+		}
+		want := mediaSamples(t, init, initData, segments, segmentData)
+		buf := &bytes.Buffer{}
+		err := Concat(buf, init, segments, tt.options)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		m := decode(t, buf.Bytes())
+		for i, moof := range m.Moof {
+			if moof.Mfhd.SequenceNumber != uint32(i+1) {
+				t.Errorf("%s: sequence number %d of fragment %d", tt.name, moof.Mfhd.SequenceNumber, i+1)
+			}
+		}
+		if m.Styp != nil || len(m.Sidx) > 0 {
+			t.Errorf("%s: the styp and sidx boxes of the segments are kept", tt.name)
+		}
+		if tt.options.Index && (m.Mfra == nil || len(m.Mfra.Tfra) != len(m.Moov.Trak)) {
+			t.Errorf("%s: no index of the fragments", tt.name)
+		}
+		compareSamples(t, tt.name, mediaSamples(t, m, buf.Bytes(), nil, nil), want)
+	}
+}
@@ -0,0 +1,102 @@
+package fragment
+
+import (
+	"bytes"
+	"io"
+	"math"
+
+	"github.com/otherplace/mp4"
+)
+
+// sampleBoxes are the types of the boxes of a sample table describing the samples, that are not kept in the
+// init segment
+var sampleBoxes = map[string]bool{
+	"sdtp": true, "subs": true, "saiz": true, "saio": true, "sgpd": true, "sbgp": true,
+	"stsh": true, "stdp": true, "padb": true, "stz2": true, "cslg": true,
+}
+
+// Init returns the init segment : the ftyp box, and a copy of the moov box without samples, with a mvex box
+// holding the duration of the media (mehd) and the defaults of the tracks (trex)
+func (f *Fragmenter) Init() (*mp4.MP4, error) {
+	ftyp := &mp4.FtypBox{MajorBrand: "isom", MinorVersion: 512, CompatibleBrands: []string{"isom"}}
+	if src := f.media.Ftyp; src != nil {
+		ftyp = &mp4.FtypBox{
+			MajorBrand:       src.MajorBrand,
+			MinorVersion:     src.MinorVersion,
+			CompatibleBrands: append([]string{}, src.CompatibleBrands...),
+		}
+	}
+	if !hasBrand(ftyp.CompatibleBrands, "iso6") {
+		ftyp.CompatibleBrands = append(ftyp.CompatibleBrands, "iso6")
+	}
+
+	moov, err := copyMoov(f.media.Moov)
+	if err != nil {
+		return nil, err
+	}
+	mehd := &mp4.MehdBox{}
+	if moov.Mvhd != nil {
+		mehd.FragmentDuration = moov.Mvhd.Duration
+		moov.Mvhd.Duration = 0
+	}
+	if mehd.FragmentDuration > math.MaxUint32 {
+		mehd.Version = 1
+	}
+	moov.Mvex = &mp4.MvexBox{Mehd: mehd}
+	for i, trak := range moov.Trak {
+		if trak.Tkhd != nil {
+			trak.Tkhd.Duration = 0
+		}
+		trak.Mdia.Mdhd.Duration = 0
+		stbl := trak.Mdia.Minf.Stbl
+		stbl.Stts.SampleCount, stbl.Stts.SampleTimeDelta = []uint32{}, []uint32{}
+		stbl.Stsc.FirstChunk, stbl.Stsc.SamplesPerChunk, stbl.Stsc.SampleDescriptionID = []uint32{}, []uint32{}, []uint32{}
+		stbl.Stsz.SampleUniformSize, stbl.Stsz.SampleNumber, stbl.Stsz.SampleSize = 0, 0, []uint32{}
+		stbl.SetChunkOffsets(nil)
+		stbl.Stss, stbl.Ctts, stbl.Sbgp, stbl.Sgpd = nil, nil, nil, nil
+		boxes := []mp4.Box{}
+		for _, b := range stbl.Boxes {
+			if !sampleBoxes[b.Type()] {
+				boxes = append(boxes, b)
+			}
+		}
+		stbl.Boxes = boxes
+		moov.Mvex.Trex = append(moov.Mvex.Trex, &mp4.TrexBox{
+			TrackId:                f.tracks[i].id,
+			SampleDescriptionIndex: 1,
+		})
+	}
+	return &mp4.MP4{Ftyp: ftyp, Moov: moov}, nil
+}
+
+// EncodeInit encodes the init segment to a writer
+func (f *Fragmenter) EncodeInit(w io.Writer) error {
+	m, err := f.Init()
+	if err != nil {
+		return err
+	}
+	return m.Encode(w)
+}
+
+// copyMoov returns a copy of a moov box
+func copyMoov(moov *mp4.MoovBox) (*mp4.MoovBox, error) {
+	buf := &bytes.Buffer{}
+	err := moov.Encode(buf)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mp4.Decode(buf)
+	if err != nil {
+		return nil, err
+	}
+	return m.Moov, nil
+}
+
+func hasBrand(brands []string, brand string) bool {
+	for _, b := range brands {
+		if b == brand {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package fragment
+
+import (
+	"io"
+	"math"
+
+	"github.com/otherplace/mp4"
+)
+
+// Sample flags of the track runs
+const (
+	syncSampleFlags    = 0x02000000                             // depends on no other sample
+	nonSyncSampleFlags = 0x01000000 | mp4.SampleIsNonSyncSample // depends on other samples
+)
+
+// extent is a range of sample data of the source, copied to the mdat box of a fragment
+type extent struct {
+	offset, size uint64
+}
+
+// Fragment returns the moof box of the fragment i (starting at 0), and the size of the data of its mdat box.
+// The data offsets of the track runs are relative to the moof box, that must be followed by the mdat box
+// (see EncodeFragment).
+func (f *Fragmenter) Fragment(i int) (*mp4.MoofBox, uint64, error) {
+	moof, extents, err := f.fragment(i)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size uint64
+	for _, e := range extents {
+		size += e.size
+	}
+	return moof, size, nil
+}
+
+// fragment builds the moof box of the fragment i, and lists the sample data of its mdat box
+func (f *Fragmenter) fragment(i int) (*mp4.MoofBox, []extent, error) {
+	if i < 0 || i >= f.count {
+		return nil, nil, ErrInvalidFragment
+	}
+	moof := &mp4.MoofBox{Mfhd: &mp4.MfhdBox{SequenceNumber: uint32(i + 1)}}
+	extents := []extent{}
+	offsets := []uint64{} // of the data of the track runs in the mdat box
+	var size uint64
+	for _, t := range f.tracks {
+		samples := t.samples[t.starts[i]:t.starts[i+1]]
+		for len(samples) > 0 {
+			// a track fragment by sample description
+			n := 1
+			for n < len(samples) && samples[n].DescriptionIndex == samples[0].DescriptionIndex {
+				n++
+			}
+			moof.Traf = append(moof.Traf, trackFragment(t.id, samples[:n]))
+			offsets = append(offsets, size)
+			for _, s := range samples[:n] {
+				last := len(extents) - 1
+				if last >= 0 && extents[last].offset+extents[last].size == s.Offset {
+					extents[last].size += uint64(s.Size)
+				} else {
+					extents = append(extents, extent{s.Offset, uint64(s.Size)})
+				}
+				size += uint64(s.Size)
+			}
+			samples = samples[n:]
+		}
+	}
+	// the data follows the moof box and the header of the mdat box
+	base := uint64(moof.Size()) + uint64(mp4.NewMdatBox(nil, 0, size).Size()) - size
+	for j, traf := range moof.Traf {
+		off := base + offsets[j]
+		if off > math.MaxInt32 {
+			return nil, nil, mp4.ErrBadFormat
+		}
+		traf.Trun[0].DataOffset = int32(off)
+	}
+	return moof, extents, nil
+}
+
+// trackFragment returns the track fragment of samples sharing the same sample description
+func trackFragment(id uint32, samples []mp4.SampleInfo) *mp4.TrafBox {
+	tfhd := &mp4.TfhdBox{TrackId: id, DefaultBaseIsMoof: true}
+	flags := uint32(mp4.DefaultBaseIsMoof)
+	if d := samples[0].DescriptionIndex; d != 1 {
+		tfhd.SampleDescriptionIndex = d
+		flags |= mp4.SampleDescriptionIndexPresent
+	}
+	tfhd.Flags = [3]byte{byte(flags >> 16), byte(flags >> 8), byte(flags)}
+
+	tfdt := &mp4.TfdtBox{BaseMediaDecodeTime: samples[0].DecodeTime}
+	if tfdt.BaseMediaDecodeTime > math.MaxUint32 {
+		tfdt.Version = 1
+	}
+
+	trun := &mp4.TrunBox{SampleCount: uint32(len(samples))}
+	flags = mp4.DataOffsetPresent | mp4.SampleDurationPresent | mp4.SampleSizePresent | mp4.SampleFlagsPresent
+	for _, s := range samples {
+		cto := s.PresentationTime - int64(s.DecodeTime)
+		if cto != 0 {
+			flags |= mp4.SampleCompositionTimeOffsetsPresent
+		}
+		if cto < 0 {
+			trun.Version = 1
+		}
+		sf := uint32(nonSyncSampleFlags)
+		if s.IsSync {
+			sf = syncSampleFlags
+		}
+		trun.Samples = append(trun.Samples, &mp4.Sample{
+			SampleDuration:              s.Duration,
+			SampleSize:                  s.Size,
+			SampleFlags:                 sf,
+			SampleCompositionTimeOffset: uint32(cto),
+		})
+	}
+	trun.Flags = [3]byte{byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return &mp4.TrafBox{Tfhd: tfhd, Tfdt: tfdt, Trun: []*mp4.TrunBox{trun}}
+}
+
+// EncodeFragment encodes the fragment i (starting at 0) to a writer : its moof box, and its mdat box
+func (f *Fragmenter) EncodeFragment(w io.Writer, i int) error {
+	moof, extents, err := f.fragment(i)
+	if err != nil {
+		return err
+	}
+	err = moof.Encode(w)
+	if err != nil {
+		return err
+	}
+	var size uint64
+	for _, e := range extents {
+		size += e.size
+	}
+	err = mp4.EncodeHeader(mp4.NewMdatBox(nil, 0, size), w)
+	if err != nil {
+		return err
+	}
+	for _, e := range extents {
+		err = f.copyData(w, e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyData copies sample data of the source to a writer
+func (f *Fragmenter) copyData(w io.Writer, e extent) error {
+	for _, s := range f.spans {
+		if e.offset >= s.Start && e.offset+e.size <= s.End {
+			n, err := io.Copy(w, io.NewSectionReader(s.Mdat, int64(e.offset-s.Start), int64(e.size)))
+			if err == nil && uint64(n) != e.size {
+				err = mp4.ErrTruncatedBody
+			}
+			return err
+		}
+	}
+	return ErrSampleOutsideMdat
+}
+
+// EncodeSegment encodes the media segment i (starting at 0) to a writer : a styp box, followed by the
+// fragment i
+func (f *Fragmenter) EncodeSegment(w io.Writer, i int) error {
+	styp := &mp4.StypBox{MajorBrand: "msdh", CompatibleBrands: []string{"msdh"}}
+	err := styp.Encode(w)
+	if err != nil {
+		return err
+	}
+	return f.EncodeFragment(w, i)
+}
+
+// Encode encodes the fragmented media to a writer, as a single file : the init segment followed by the
+// fragments
+func (f *Fragmenter) Encode(w io.Writer) error {
+	err := f.EncodeInit(w)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < f.count; i++ {
+		err = f.EncodeFragment(w, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
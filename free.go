@@ -5,19 +5,20 @@ import (
 	"io"
 )
 
-// File Type Box (ftyp - mandatory)
+// Free Space Box (free - optional)
 //
-// Status: decoded
+// Status: not decoded
 type FreeBox struct {
 	notDecoded []byte
 }
 
+// NewFreeBox returns a free box of the given size, header included (at least BoxHeaderSize)
+func NewFreeBox(size int) *FreeBox {
+	return &FreeBox{make([]byte, size-BoxHeaderSize)}
+}
+
 func DecodeFree(h BoxHeader, r io.Reader) (Box, error) {
-	if h.Size <= BoxHeaderSize {
-		return &FreeBox{nil}, nil
-	}
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +33,7 @@ func (b *FreeBox) Type() string {
 }
 
 func (b *FreeBox) Size() int {
-	return BoxHeaderSize + len(b.notDecoded)
+	return sizeWithHeader(false, uint64(len(b.notDecoded)))
 }
 
 func (b *FreeBox) Encode(w io.Writer) error {
@@ -16,11 +16,13 @@ type FtypBox struct {
 }
 
 func DecodeFtyp(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 8 || len(data)%4 != 0 {
+		return nil, ErrBadFormat
+	}
 	b := &FtypBox{
 		MajorBrand:       string(data[0:4]),
 		MinorVersion:     binary.BigEndian.Uint32(data[4:8]),
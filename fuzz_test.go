@@ -0,0 +1,162 @@
+package mp4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fuzz targets are seeded with the media of the sample directory, and their boxes :
+//
+//	go test -fuzz FuzzDecodeBox
+
+// fuzzLimits keep the memory used by the targets low
+var fuzzLimits = Limits{
+	MaxBoxSize: 4 << 20,
+	MaxDepth:   16,
+	MaxEntries: 1 << 16,
+}
+
+// fuzzContainers are the boxes scanned by the targets
+var fuzzContainers = map[string]bool{
+	"moov": true, "trak": true, "edts": true, "mdia": true, "minf": true, "dinf": true, "stbl": true,
+	"mvex": true, "moof": true, "traf": true, "mfra": true, "udta": true,
+}
+
+// maxFuzzSamples is the number of samples read by the targets, for each track
+const maxFuzzSamples = 1000
+
+// samples returns the content of the media of the sample directory
+func samples(f *testing.F) [][]byte {
+	files, err := filepath.Glob("sample/*")
+	if err != nil {
+		f.Fatal(err)
+	}
+	l := [][]byte{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		l = append(l, data)
+	}
+	return l
+}
+
+// addMedia seeds f with the media of the sample directory
+func addMedia(f *testing.F) {
+	SetLimits(fuzzLimits)
+	for _, data := range samples(f) {
+		f.Add(data)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addMedia(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, mode := range []DecodeMode{DecodeStrict, DecodeLenient} {
+			if m, err := DecodeWithOptions(bytes.NewReader(data), DecodeOptions{Mode: mode}); err == nil {
+				m.Encode(ioutil.Discard)
+			}
+		}
+		m, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		m.Encode(ioutil.Discard)
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		for _, track := range r.Tracks {
+			it := track.Samples()
+			for i := 0; i < maxFuzzSamples && it.Next(); i++ {
+				s := it.Sample()
+				track.Sample(s.Number)
+				r.ReadSample(track.ID(), s.Number)
+			}
+		}
+	})
+}
+
+func FuzzDecodeBox(f *testing.F) {
+	SetLimits(fuzzLimits)
+	// the boxes of the samples, at any depth
+	for _, data := range samples(f) {
+		s := NewScanner(bytes.NewReader(data))
+		for s.Next() {
+			b := s.Box()
+			if b.Size > 0 && b.Size <= 1<<16 {
+				f.Add(data[b.Offset : b.Offset+b.Size])
+			}
+			if fuzzContainers[b.Type()] {
+				s.Descend()
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		h, err := DecodeHeader(r)
+		if err != nil {
+			return
+		}
+		body, _ := ioutil.ReadAll(r)
+		if b, err := DecodeBox(h, rootReader(bytes.NewReader(body))); err == nil {
+			b.Encode(ioutil.Discard)
+		}
+		// the body is also given to every decoder
+		for typ, d := range decoders {
+			h.Type = typ
+			d(h, newBoxReader(bytes.NewReader(body), typ, typ, 0, int64(len(body))))
+		}
+	})
+}
+
+func FuzzScanner(f *testing.F) {
+	addMedia(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := NewScanner(bytes.NewReader(data))
+		for s.Next() {
+			if fuzzContainers[s.Box().Type()] {
+				s.Descend()
+			} else {
+				s.Decode()
+			}
+		}
+		// non-seekable reader
+		s = NewScanner(io.MultiReader(bytes.NewReader(data)))
+		for s.Next() {
+			s.Decode()
+		}
+	})
+}
+
+func FuzzParser(f *testing.F) {
+	addMedia(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		n := 0
+		p := &Parser{
+			OnSample: func(trackID uint32, s SampleInfo, data []byte) error {
+				n++
+				if n > maxFuzzSamples {
+					return io.EOF
+				}
+				return nil
+			},
+		}
+		for len(data) > 0 {
+			l := 1000
+			if l > len(data) {
+				l = len(data)
+			}
+			if _, err := p.Write(data[:l]); err != nil {
+				return
+			}
+			data = data[l:]
+		}
+		p.Close()
+	})
+}
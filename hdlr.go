@@ -21,21 +21,26 @@ type HdlrBox struct {
 	PreDefined  uint32
 	HandlerType string
 	Name        string
+	reserved    [12]byte // set by some writers (e.g. "appl" for the QuickTime metadata handler)
 }
 
 func DecodeHdlr(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
-	return &HdlrBox{
+	if err = checkFullBoxSize(data, 24, 24); err != nil {
+		return nil, err
+	}
+	b := &HdlrBox{
 		Version:     data[0],
 		Flags:       [3]byte{data[1], data[2], data[3]},
 		PreDefined:  binary.BigEndian.Uint32(data[4:8]),
 		HandlerType: string(data[8:12]),
 		Name:        string(data[24:]),
-	}, nil
+	}
+	copy(b.reserved[:], data[12:24])
+	return b, nil
 }
 
 func (b *HdlrBox) Box() Box {
@@ -60,6 +65,7 @@ func (b *HdlrBox) Encode(w io.Writer) error {
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
 	binary.BigEndian.PutUint32(buf[4:], b.PreDefined)
 	strtobuf(buf[8:], b.HandlerType, 4)
+	copy(buf[12:], b.reserved[:])
 	strtobuf(buf[24:], b.Name, len(b.Name))
 	_, err = w.Write(buf)
 	return err
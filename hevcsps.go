@@ -0,0 +1,93 @@
+package mp4
+
+// H.265 Sequence Parameter Set (ITU-T H.265, 7.3.2.2)
+//
+// Only the fields needed to describe the stream are kept (the parsing stops after the bit depths).
+// Width and Height are the displayed dimensions (after applying the conformance window).
+type HevcSPS struct {
+	ProfileSpace             byte
+	Tier                     byte
+	Profile                  byte
+	ProfileCompatibility     uint32
+	ConstraintIndicatorFlags uint64
+	Level                    byte
+	MaxSubLayers             uint32
+	ID                       uint32
+	ChromaFormat             uint32 // 0: monochrome, 1: 4:2:0, 2: 4:2:2, 3: 4:4:4
+	Width, Height            uint32
+	BitDepthLuma             uint32
+	BitDepthChroma           uint32
+}
+
+// ParseHevcSPS parses a SPS NAL unit (starting with the 2 bytes NAL unit header, as stored in the hvcC box)
+func ParseHevcSPS(nal []byte) (*HevcSPS, error) {
+	if len(nal) < 3 {
+		return nil, ErrTruncatedBody
+	}
+	if (nal[0]>>1)&0x3f != HevcNALSPS {
+		return nil, ErrBadFormat
+	}
+	r := newBitReader(unescapeRBSP(nal[2:]))
+	r.skip(4) // sps_video_parameter_set_id
+	s := &HevcSPS{
+		MaxSubLayers: r.readBits(3) + 1,
+	}
+	r.skip(1) // sps_temporal_id_nesting_flag
+	s.parseProfileTierLevel(r)
+	s.ID = r.readUE()
+	s.ChromaFormat = r.readUE()
+	separateColourPlane := false
+	if s.ChromaFormat == 3 {
+		separateColourPlane = r.readFlag()
+	}
+	s.Width = r.readUE()
+	s.Height = r.readUE()
+	if r.readFlag() {
+		left, right, top, bottom := r.readUE(), r.readUE(), r.readUE(), r.readUE()
+		cropX, cropY := uint32(1), uint32(1)
+		if !separateColourPlane {
+			if s.ChromaFormat == 1 || s.ChromaFormat == 2 {
+				cropX = 2
+			}
+			if s.ChromaFormat == 1 {
+				cropY = 2
+			}
+		}
+		s.Width -= (left + right) * cropX
+		s.Height -= (top + bottom) * cropY
+	}
+	s.BitDepthLuma = r.readUE() + 8
+	s.BitDepthChroma = r.readUE() + 8
+	if r.err != nil {
+		return nil, r.err
+	}
+	return s, nil
+}
+
+// parseProfileTierLevel parses the profile_tier_level structure (7.3.3), the sub layers are skipped
+func (s *HevcSPS) parseProfileTierLevel(r *bitReader) {
+	s.ProfileSpace = byte(r.readBits(2))
+	s.Tier = byte(r.readBits(1))
+	s.Profile = byte(r.readBits(5))
+	s.ProfileCompatibility = r.readBits(32)
+	s.ConstraintIndicatorFlags = uint64(r.readBits(16))<<32 | uint64(r.readBits(32))
+	s.Level = byte(r.readBits(8))
+	n := int(s.MaxSubLayers) - 1
+	profilePresent := make([]bool, n)
+	levelPresent := make([]bool, n)
+	for i := 0; i < n; i++ {
+		profilePresent[i] = r.readFlag()
+		levelPresent[i] = r.readFlag()
+	}
+	if n > 0 {
+		r.skip(2 * (8 - n)) // reserved_zero_2bits
+	}
+	for i := 0; i < n; i++ {
+		if profilePresent[i] {
+			r.skip(88)
+		}
+		if levelPresent[i] {
+			r.skip(8)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package mp4
+
+import (
+	"reflect"
+	"testing"
+)
+
+// hevcPTL describes the profile_tier_level of the tests
+type hevcPTL struct {
+	space, tier, profile byte
+	compatibility        uint32
+	constraints          uint64
+	level                byte
+}
+
+// hevcSPS returns a SPS NAL unit of the given dimensions, with maxSubLayers sub layers (the first one with
+// profile information, the second one with level information)
+func hevcSPS(p hevcPTL, maxSubLayers int, chroma, width, height uint32, window [4]uint32, bitDepth uint32) []byte {
+	w := &bitWriter{}
+	w.bits(0, 4).bits(uint64(maxSubLayers-1), 3).flag(true)
+	w.bits(uint64(p.space), 2).bits(uint64(p.tier), 1).bits(uint64(p.profile), 5)
+	w.bits(uint64(p.compatibility), 32).bits(p.constraints, 48).bits(uint64(p.level), 8)
+	if n := maxSubLayers - 1; n > 0 {
+		for i := 0; i < n; i++ {
+			w.flag(i == 0).flag(i == 1)
+		}
+		w.bits(0, 2*(8-n))
+		for i := 0; i < n; i++ {
+			if i == 0 {
+				w.bits(0, 88)
+			}
+			if i == 1 {
+				w.bits(0xff, 8)
+			}
+		}
+	}
+	w.ue(0).ue(chroma)
+	if chroma == 3 {
+		w.flag(false)
+	}
+	w.ue(width).ue(height)
+	w.flag(window != [4]uint32{})
+	if window != [4]uint32{} {
+		w.ue(window[0]).ue(window[1]).ue(window[2]).ue(window[3])
+	}
+	w.ue(bitDepth - 8).ue(bitDepth - 8)
+	w.ue(4) // log2_max_pic_order_cnt_lsb_minus4, not parsed
+	return append([]byte{HevcNALSPS << 1, 1}, escapeRBSP(w.rbsp())...)
+}
+
+var (
+	hevcMain   = hevcPTL{0, 0, 1, 0x60000000, 0x900000000000, 120}
+	hevcMain10 = hevcPTL{0, 1, 2, 0x20000000, 0xb00000000000, 153}
+	hevcRExt   = hevcPTL{1, 0, 4, 0x08000000, 0x800000000001, 93}
+)
+
+func TestParseHevcSPS(t *testing.T) {
+	tests := []struct {
+		name string
+		nal  []byte
+		want HevcSPS
+	}{
+		{
+			"main", hevcSPS(hevcMain, 1, 1, 1920, 1088, [4]uint32{0, 0, 0, 4}, 8),
+			HevcSPS{Profile: 1, ProfileCompatibility: 0x60000000, ConstraintIndicatorFlags: 0x900000000000, Level: 120,
+				MaxSubLayers: 1, ChromaFormat: 1, Width: 1920, Height: 1080, BitDepthLuma: 8, BitDepthChroma: 8},
+		},
+		{
+			"main 10, sub layers", hevcSPS(hevcMain10, 3, 1, 3840, 2160, [4]uint32{}, 10),
+			HevcSPS{Tier: 1, Profile: 2, ProfileCompatibility: 0x20000000, ConstraintIndicatorFlags: 0xb00000000000,
+				Level: 153, MaxSubLayers: 3, ChromaFormat: 1, Width: 3840, Height: 2160, BitDepthLuma: 10, BitDepthChroma: 10},
+		},
+		{
+			"4:4:4", hevcSPS(hevcRExt, 2, 3, 1280, 720, [4]uint32{1, 1, 0, 2}, 12),
+			HevcSPS{ProfileSpace: 1, Profile: 4, ProfileCompatibility: 0x08000000, ConstraintIndicatorFlags: 0x800000000001,
+				Level: 93, MaxSubLayers: 2, ChromaFormat: 3, Width: 1278, Height: 718, BitDepthLuma: 12, BitDepthChroma: 12},
+		},
+	}
+	for _, tt := range tests {
+		s, err := ParseHevcSPS(tt.nal)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*s, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *s, tt.want)
+		}
+	}
+	nal := hevcSPS(hevcMain, 1, 1, 1920, 1088, [4]uint32{}, 8)
+	if _, err := ParseHevcSPS(append([]byte{HevcNALPPS << 1, 1}, nal[2:]...)); err != ErrBadFormat {
+		t.Errorf("PPS: %v", err)
+	}
+	if _, err := ParseHevcSPS(nal[:12]); err != ErrTruncatedBody {
+		t.Errorf("truncated: %v", err)
+	}
+}
+
+// hvcC returns a hvcC box with a SPS array
+func hvcC(p hevcPTL, sps []byte) []byte {
+	w := &bitWriter{}
+	w.bits(1, 8)
+	w.bits(uint64(p.space), 2).bits(uint64(p.tier), 1).bits(uint64(p.profile), 5)
+	w.bits(uint64(p.compatibility), 32).bits(p.constraints, 48).bits(uint64(p.level), 8)
+	w.bits(0xf, 4).bits(0, 12).bits(0x3f, 6).bits(0, 2)
+	w.bits(0x3f, 6).bits(1, 2).bits(0x1f, 5).bits(0, 3).bits(0x1f, 5).bits(0, 3)
+	w.bits(0, 16).bits(0, 2).bits(1, 3).flag(true).bits(3, 2)
+	w.bits(1, 8)
+	w.flag(true).flag(false).bits(HevcNALSPS, 6).bits(1, 16).bits(uint64(len(sps)), 16)
+	body := append(w.data, sps...)
+	return append([]byte{0, 0, 0, byte(8 + len(body)), 'h', 'v', 'c', 'C'}, body...)
+}
+
+func TestHvcCCodec(t *testing.T) {
+	tests := []struct {
+		format string
+		ptl    hevcPTL
+		want   string
+	}{
+		{"hvc1", hevcMain, "hvc1.1.6.L120.90"},
+		{"hev1", hevcMain10, "hev1.2.4.H153.B0"},
+		{"hvc1", hevcRExt, "hvc1.A4.10.L93.80.0.0.0.0.1"},
+		{"hvc1", hevcPTL{0, 0, 1, 0x60000000, 0, 93}, "hvc1.1.6.L93"},
+	}
+	for _, tt := range tests {
+		sps := hevcSPS(tt.ptl, 1, 1, 1920, 1080, [4]uint32{}, 8)
+		b := decodeBox(t, hvcC(tt.ptl, sps)).(*HvcCBox)
+		if c := b.Codec(tt.format); c != tt.want {
+			t.Errorf("codec %s, want %s", c, tt.want)
+		}
+		if b.NALUnitLength() != 4 || b.NumTemporalLayers != 1 || !b.TemporalIdNested {
+			t.Errorf("got %+v", b)
+		}
+		l := b.NALUnits(HevcNALSPS)
+		if len(l) != 1 || len(b.NALUnits(HevcNALPPS)) != 0 {
+			t.Fatalf("SPS %x", l)
+		}
+		s, err := ParseHevcSPS(l[0])
+		if err != nil || s.Width != 1920 || s.Profile != tt.ptl.profile {
+			t.Errorf("SPS %+v, %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+)
+
+// HEVC NAL unit types stored in the hvcC arrays
+const (
+	HevcNALVPS       = 32
+	HevcNALSPS       = 33
+	HevcNALPPS       = 34
+	HevcNALPrefixSEI = 39
+	HevcNALSuffixSEI = 40
+)
+
+// HEVC Configuration Box (hvcC - mandatory for H.265 video tracks)
+//
+// Contained in : Visual Sample Entry (hvc1, hev1)
+//
+// Status: decoded
+//
+// Contains the HEVCDecoderConfigurationRecord (ISO/IEC 14496-15) : profile, tier, level, chroma format,
+// bit depths, size of the NAL unit length fields in samples (LengthSizeMinusOne + 1 bytes) and the
+// arrays of parameter sets (VPS, SPS, PPS) and SEI NAL units.
+//
+// ConstraintIndicatorFlags holds the 48 bits of general_constraint_indicator_flags.
+type HvcCBox struct {
+	ConfigurationVersion     byte
+	ProfileSpace             byte
+	Tier                     byte
+	Profile                  byte
+	ProfileCompatibility     uint32
+	ConstraintIndicatorFlags uint64
+	Level                    byte
+	MinSpatialSegmentation   uint16
+	ParallelismType          byte
+	ChromaFormat             byte
+	BitDepthLumaMinus8       byte
+	BitDepthChromaMinus8     byte
+	AvgFrameRate             uint16
+	ConstantFrameRate        byte
+	NumTemporalLayers        byte
+	TemporalIdNested         bool
+	LengthSizeMinusOne       byte
+	Arrays                   []HvcCArray
+	notDecoded               []byte
+}
+
+// HvcCArray is a list of NAL units of the same type (VPS, SPS, PPS or SEI)
+type HvcCArray struct {
+	Completeness bool
+	NALUnitType  byte
+	NALUnits     [][]byte
+}
+
+func DecodeHvcC(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 23 {
+		return nil, ErrTruncatedBody
+	}
+	b := &HvcCBox{
+		ConfigurationVersion:     data[0],
+		ProfileSpace:             data[1] >> 6,
+		Tier:                     (data[1] >> 5) & 0x01,
+		Profile:                  data[1] & 0x1f,
+		ProfileCompatibility:     binary.BigEndian.Uint32(data[2:6]),
+		ConstraintIndicatorFlags: uint64(binary.BigEndian.Uint16(data[6:8]))<<32 | uint64(binary.BigEndian.Uint32(data[8:12])),
+		Level:                    data[12],
+		MinSpatialSegmentation:   binary.BigEndian.Uint16(data[13:15]) & 0x0fff,
+		ParallelismType:          data[15] & 0x03,
+		ChromaFormat:             data[16] & 0x03,
+		BitDepthLumaMinus8:       data[17] & 0x07,
+		BitDepthChromaMinus8:     data[18] & 0x07,
+		AvgFrameRate:             binary.BigEndian.Uint16(data[19:21]),
+		ConstantFrameRate:        data[21] >> 6,
+		NumTemporalLayers:        (data[21] >> 3) & 0x07,
+		TemporalIdNested:         data[21]&0x04 != 0,
+		LengthSizeMinusOne:       data[21] & 0x03,
+	}
+	count := int(data[22])
+	off := 23
+	for i := 0; i < count; i++ {
+		if off+3 > len(data) {
+			return nil, ErrTruncatedBody
+		}
+		a := HvcCArray{
+			Completeness: data[off]&0x80 != 0,
+			NALUnitType:  data[off] & 0x3f,
+		}
+		n := int(binary.BigEndian.Uint16(data[off+1 : off+3]))
+		off += 3
+		for j := 0; j < n; j++ {
+			if off+2 > len(data) {
+				return nil, ErrTruncatedBody
+			}
+			sz := int(binary.BigEndian.Uint16(data[off : off+2]))
+			off += 2
+			if off+sz > len(data) {
+				return nil, ErrTruncatedBody
+			}
+			a.NALUnits = append(a.NALUnits, data[off:off+sz])
+			off += sz
+		}
+		b.Arrays = append(b.Arrays, a)
+	}
+	b.notDecoded = data[off:]
+	return b, nil
+}
+
+func (b *HvcCBox) Box() Box {
+	return b
+}
+
+func (b *HvcCBox) Type() string {
+	return "hvcC"
+}
+
+func (b *HvcCBox) Size() int {
+	sz := BoxHeaderSize + 23 + len(b.notDecoded)
+	for _, a := range b.Arrays {
+		sz += 3
+		for _, nal := range a.NALUnits {
+			sz += 2 + len(nal)
+		}
+	}
+	return sz
+}
+
+// NALUnitLength returns the size (in bytes) of the length field preceding each NAL unit in samples
+func (b *HvcCBox) NALUnitLength() int {
+	return int(b.LengthSizeMinusOne) + 1
+}
+
+// NALUnits returns the NAL units of the given type (HevcNALVPS, HevcNALSPS, ...)
+func (b *HvcCBox) NALUnits(nalType byte) [][]byte {
+	l := [][]byte{}
+	for _, a := range b.Arrays {
+		if a.NALUnitType == nalType {
+			l = append(l, a.NALUnits...)
+		}
+	}
+	return l
+}
+
+// Codec returns the RFC 6381 codec string (e.g. hvc1.2.4.L153.B0) for a sample entry format (hvc1 or
+// hev1), as defined by ISO/IEC 14496-15 Annex E
+func (b *HvcCBox) Codec(format string) string {
+	s := format + "."
+	if b.ProfileSpace > 0 {
+		s += string('A' + b.ProfileSpace - 1)
+	}
+	s += fmt.Sprintf("%d.%X.", b.Profile, bits.Reverse32(b.ProfileCompatibility))
+	if b.Tier == 0 {
+		s += "L"
+	} else {
+		s += "H"
+	}
+	s += fmt.Sprintf("%d", b.Level)
+	// Constraint bytes, trailing zero bytes are omitted
+	c := []string{}
+	for i := 5; i >= 0; i-- {
+		c = append(c, fmt.Sprintf("%X", byte(b.ConstraintIndicatorFlags>>(uint(i)*8))))
+	}
+	for len(c) > 0 && c[len(c)-1] == "0" {
+		c = c[:len(c)-1]
+	}
+	if len(c) > 0 {
+		s += "." + strings.Join(c, ".")
+	}
+	return s
+}
+
+func (b *HvcCBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.ConfigurationVersion
+	buf[1] = b.ProfileSpace<<6 | (b.Tier&0x01)<<5 | b.Profile&0x1f
+	binary.BigEndian.PutUint32(buf[2:], b.ProfileCompatibility)
+	binary.BigEndian.PutUint16(buf[6:], uint16(b.ConstraintIndicatorFlags>>32))
+	binary.BigEndian.PutUint32(buf[8:], uint32(b.ConstraintIndicatorFlags))
+	buf[12] = b.Level
+	binary.BigEndian.PutUint16(buf[13:], 0xf000|b.MinSpatialSegmentation&0x0fff)
+	buf[15] = 0xfc | b.ParallelismType&0x03
+	buf[16] = 0xfc | b.ChromaFormat&0x03
+	buf[17] = 0xf8 | b.BitDepthLumaMinus8&0x07
+	buf[18] = 0xf8 | b.BitDepthChromaMinus8&0x07
+	binary.BigEndian.PutUint16(buf[19:], b.AvgFrameRate)
+	buf[21] = b.ConstantFrameRate<<6 | (b.NumTemporalLayers&0x07)<<3 | b.LengthSizeMinusOne&0x03
+	if b.TemporalIdNested {
+		buf[21] |= 0x04
+	}
+	buf[22] = byte(len(b.Arrays))
+	off := 23
+	for _, a := range b.Arrays {
+		buf[off] = a.NALUnitType & 0x3f
+		if a.Completeness {
+			buf[off] |= 0x80
+		}
+		binary.BigEndian.PutUint16(buf[off+1:], uint16(len(a.NALUnits)))
+		off += 3
+		for _, nal := range a.NALUnits {
+			binary.BigEndian.PutUint16(buf[off:], uint16(len(nal)))
+			off += 2 + copy(buf[off+2:], nal)
+		}
+	}
+	copy(buf[off:], b.notDecoded)
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *HvcCBox) Dump() {
+	fmt.Printf("HEVC Configuration Box\n")
+	fmt.Printf("+- Profile: %d\n", b.Profile)
+	fmt.Printf("+- Tier: %d\n", b.Tier)
+	fmt.Printf("+- Level: %d\n", b.Level)
+	fmt.Printf("+- Chroma format: %d, luma %d bits, chroma %d bits\n", b.ChromaFormat, b.BitDepthLumaMinus8+8, b.BitDepthChromaMinus8+8)
+	fmt.Printf("+- NAL unit length: %d bytes\n", b.NALUnitLength())
+	for _, ps := range b.NALUnits(HevcNALSPS) {
+		sps, err := ParseHevcSPS(ps)
+		if err != nil {
+			fmt.Printf("+- SPS: %s\n", err)
+			continue
+		}
+		fmt.Printf("+- SPS: %dx%d, chroma format %d, %d bits\n", sps.Width, sps.Height, sps.ChromaFormat, sps.BitDepthLuma)
+	}
+}
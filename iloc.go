@@ -17,63 +17,122 @@ type IlocBox struct {
 	LenghSize      uint8
 	BaseOffsetSize uint8
 	IndexSize      uint8
-	ItemCount      uint16
+	ItemCount      uint32
 	Items          []*Item
 }
 type Item struct {
-	ItemId             uint16
+	ItemId             uint32
 	Reserved           uint16
 	ConstructionMethod uint8
 	DataReferenceIndex uint16
-	BaseOffset         uint32
+	BaseOffset         uint64
 	ExtentCount        uint16
 	Extents            []*Extent
 }
 
 type Extent struct {
-	ExtentIndex  uint32
-	ExtentOffset uint32
-	ExtentLength uint32
+	ExtentIndex  uint64
+	ExtentOffset uint64
+	ExtentLength uint64
 }
 
 func DecodeIloc(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 8 {
+		return nil, ErrBadFormat
+	}
 	b := &IlocBox{
 		Version: data[0],
 		Flags:   [3]byte{data[1], data[2], data[3]},
 	}
-	offsetLength := data[4]
-	b.OffsetSize = (0xf0 ^ offsetLength) >> 4
-	b.LenghSize = (0x0f ^ offsetLength)
-	baseIndex := data[5]
-	b.BaseOffsetSize = (0xf0 ^ baseIndex) >> 4
-	b.IndexSize = (0x0f ^ baseIndex)
-	b.ItemCount = binary.BigEndian.Uint16(data[6:8])
-	offset := 8
-	for i := 0; i < int(b.ItemCount); i++ {
-		bi := &Item{}
-		bi.ItemId = binary.BigEndian.Uint16(data[offset+i*2 : offset+2+i*2])
-		offset += 2
-		revCon := binary.BigEndian.Uint16(data[offset+i*2 : offset+2+i*2])
-		offset += 2
-		bi.Reserved = (0xf0 ^ revCon) >> 4
-		bi.ConstructionMethod = uint8(0x0f ^ revCon)
-		bi.DataReferenceIndex = binary.BigEndian.Uint16(data[offset+i*2 : offset+2+i*2])
+	b.OffsetSize = data[4] >> 4
+	b.LenghSize = data[4] & 0x0f
+	b.BaseOffsetSize = data[5] >> 4
+	if b.Version == 1 || b.Version == 2 {
+		b.IndexSize = data[5] & 0x0f
+	}
+	for _, s := range []uint8{b.OffsetSize, b.LenghSize, b.BaseOffsetSize, b.IndexSize} {
+		if s != 0 && s != 4 && s != 8 {
+			return nil, ErrBadFormat
+		}
+	}
+	offset := 6
+	if b.Version < 2 {
+		b.ItemCount = uint32(binary.BigEndian.Uint16(data[6:8]))
 		offset += 2
-		bi.BaseOffset = binary.BigEndian.Uint32(data[offset+i*4 : offset+4+i*4])
+	} else {
+		if len(data) < 10 {
+			return nil, ErrBadFormat
+		}
+		b.ItemCount = binary.BigEndian.Uint32(data[6:10])
 		offset += 4
-		bi.ExtentCount = binary.BigEndian.Uint16(data[offset+i*2 : offset+2+i*2])
-		offset += 2
-		offset2 := offset
-		for j := 0; i < int(bi.ExtentCount); j++ {
+	}
+	// item ID, data reference index and extent count at least
+	if err := checkEntries(r, uint64(b.ItemCount), 6, offset, data); err != nil {
+		return nil, err
+	}
+	// read returns the next field of size bytes
+	read := func(size int) (uint64, error) {
+		if offset+size > len(data) {
+			return 0, ErrBadFormat
+		}
+		var v uint64
+		switch size {
+		case 2:
+			v = uint64(binary.BigEndian.Uint16(data[offset:]))
+		case 4:
+			v = uint64(binary.BigEndian.Uint32(data[offset:]))
+		case 8:
+			v = binary.BigEndian.Uint64(data[offset:])
+		}
+		offset += size
+		return v, nil
+	}
+	for i := 0; i < int(b.ItemCount); i++ {
+		bi := &Item{}
+		idSize := 2
+		if b.Version == 2 {
+			idSize = 4
+		}
+		v, err := read(idSize)
+		if err != nil {
+			return nil, err
+		}
+		bi.ItemId = uint32(v)
+		if b.Version == 1 || b.Version == 2 {
+			if v, err = read(2); err != nil {
+				return nil, err
+			}
+			bi.Reserved = uint16(v >> 4)
+			bi.ConstructionMethod = uint8(v & 0x0f)
+		}
+		if v, err = read(2); err != nil {
+			return nil, err
+		}
+		bi.DataReferenceIndex = uint16(v)
+		if bi.BaseOffset, err = read(int(b.BaseOffsetSize)); err != nil {
+			return nil, err
+		}
+		if v, err = read(2); err != nil {
+			return nil, err
+		}
+		bi.ExtentCount = uint16(v)
+		for j := 0; j < int(bi.ExtentCount); j++ {
 			e := &Extent{}
-			e.ExtentIndex = binary.BigEndian.Uint32(data[offset2+i*4 : offset2+4+i*4])
-			e.ExtentOffset = binary.BigEndian.Uint32(data[offset2+i*4 : offset2+4+i*4])
-			e.ExtentLength = binary.BigEndian.Uint32(data[offset2+i*4 : offset2+4+i*4])
+			if b.Version == 1 || b.Version == 2 {
+				if e.ExtentIndex, err = read(int(b.IndexSize)); err != nil {
+					return nil, err
+				}
+			}
+			if e.ExtentOffset, err = read(int(b.OffsetSize)); err != nil {
+				return nil, err
+			}
+			if e.ExtentLength, err = read(int(b.LenghSize)); err != nil {
+				return nil, err
+			}
 			bi.Extents = append(bi.Extents, e)
 		}
 		b.Items = append(b.Items, bi)
@@ -91,10 +150,18 @@ func (b *IlocBox) Type() string {
 
 func (b *IlocBox) Size() int {
 	l := BoxHeaderSize + 8
+	idSize := 2
+	if b.Version == 2 {
+		l += 2
+		idSize = 4
+	}
 	for _, i := range b.Items {
-		l = l + 12 + int(i.ExtentCount)*24
+		l += idSize + 4 + int(b.BaseOffsetSize)
+		if b.Version == 1 || b.Version == 2 {
+			l += 2 + len(i.Extents)*int(b.IndexSize)
+		}
+		l += len(i.Extents) * int(b.OffsetSize+b.LenghSize)
 	}
-
 	return l
 }
 
@@ -106,7 +173,42 @@ func (b *IlocBox) Encode(w io.Writer) error {
 	buf := makebuf(b)
 	buf[0] = b.Version
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	// TODO:
+	buf[4] = b.OffsetSize<<4 | b.LenghSize
+	buf[5] = b.BaseOffsetSize<<4 | b.IndexSize
+	offset := 6
+	// put writes the next field of size bytes
+	put := func(size int, v uint64) {
+		switch size {
+		case 2:
+			binary.BigEndian.PutUint16(buf[offset:], uint16(v))
+		case 4:
+			binary.BigEndian.PutUint32(buf[offset:], uint32(v))
+		case 8:
+			binary.BigEndian.PutUint64(buf[offset:], v)
+		}
+		offset += size
+	}
+	idSize := 2
+	if b.Version == 2 {
+		idSize = 4
+	}
+	put(idSize, uint64(len(b.Items)))
+	for _, i := range b.Items {
+		put(idSize, uint64(i.ItemId))
+		if b.Version == 1 || b.Version == 2 {
+			put(2, uint64(i.Reserved)<<4|uint64(i.ConstructionMethod))
+		}
+		put(2, uint64(i.DataReferenceIndex))
+		put(int(b.BaseOffsetSize), i.BaseOffset)
+		put(2, uint64(len(i.Extents)))
+		for _, e := range i.Extents {
+			if b.Version == 1 || b.Version == 2 {
+				put(int(b.IndexSize), e.ExtentIndex)
+			}
+			put(int(b.OffsetSize), e.ExtentOffset)
+			put(int(b.LenghSize), e.ExtentLength)
+		}
+	}
 	_, err = w.Write(buf)
 	return err
 }
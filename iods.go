@@ -9,20 +9,38 @@ import (
 //
 // Contained in : Movie Box (‘moov’)
 //
-// Status: not decoded
+// Status: decoded
+//
+// Contains the initial object descriptor (ISO/IEC 14496-1), with the audio and visual profile levels.
 type IodsBox struct {
+	Version    byte
+	Flags      [3]byte
+	Descriptor Descriptor
 	notDecoded []byte
 }
 
 func DecodeIods(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, ErrTruncatedBody
+	}
+	b := &IodsBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	if len(data) == 4 {
+		return b, nil
+	}
+	d, n, err := DecodeDescriptor(data[4:])
 	if err != nil {
 		return nil, err
 	}
-	return &IodsBox{
-		notDecoded: data,
-	}, nil
+	b.Descriptor = d
+	b.notDecoded = data[4+n:]
+	return b, nil
 }
 
 func (b *IodsBox) Box() Box {
@@ -33,7 +51,11 @@ func (b *IodsBox) Type() string {
 }
 
 func (b *IodsBox) Size() int {
-	return BoxHeaderSize + len(b.notDecoded)
+	sz := BoxHeaderSize + 4 + len(b.notDecoded)
+	if b.Descriptor != nil {
+		sz += b.Descriptor.Size()
+	}
+	return sz
 }
 
 func (b *IodsBox) Encode(w io.Writer) error {
@@ -41,11 +63,23 @@ func (b *IodsBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	_, err = w.Write([]byte{b.Version, b.Flags[0], b.Flags[1], b.Flags[2]})
+	if err != nil {
+		return err
+	}
+	if b.Descriptor != nil {
+		err = b.Descriptor.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
 	_, err = w.Write(b.notDecoded)
 	return err
 }
 
 func (b *IodsBox) Dump() {
 	fmt.Printf("Object Descriptor Container Box\n")
-	fmt.Printf("+- Payload: %s\n", string(b.notDecoded))
+	if b.Descriptor != nil {
+		dumpDescriptors([]Descriptor{b.Descriptor}, "")
+	}
 }
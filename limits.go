@@ -0,0 +1,110 @@
+package mp4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+)
+
+var (
+	ErrLimitExceeded = errors.New("decoding limit exceeded")
+)
+
+// Limits protect the applications decoding untrusted media against the boxes that would exhaust their
+// memory. A zero value means no limit.
+type Limits struct {
+	MaxBoxSize int64 // of the boxes read in memory (all but mdat, unless read from a non-seekable reader)
+	MaxDepth   int   // of the nested boxes, the top-level boxes being at depth 1
+	MaxEntries int   // of the tables (stts, stsz, trun, sidx, ...)
+}
+
+// DefaultLimits are the limits used until SetLimits is called
+var DefaultLimits = Limits{
+	MaxBoxSize: 256 << 20,
+	MaxDepth:   32,
+	MaxEntries: 8 << 20,
+}
+
+var limits atomic.Pointer[Limits]
+
+// preallocSize is the size up to which the body of a box is allocated before being read
+const preallocSize = 1 << 20
+
+func init() {
+	SetLimits(DefaultLimits)
+}
+
+// SetLimits sets the limits of the decoding
+func SetLimits(l Limits) {
+	limits.Store(&l)
+}
+
+// limitsOf returns the limits of the decoding of the box read by r
+func limitsOf(r io.Reader) *Limits {
+	if br, ok := r.(*boxReader); ok && br.limits != nil {
+		return br.limits
+	}
+	return limits.Load()
+}
+
+// readBoxData reads the body of a box in memory, or what remains of it if the beginning was read
+func readBoxData(h BoxHeader, r io.Reader) ([]byte, error) {
+	max := limitsOf(r).MaxBoxSize
+	size, unbounded := bodySize(h, r)
+	if unbounded {
+		// up to the end of the media
+		lr := r
+		if max > 0 {
+			lr = io.LimitReader(r, max+1)
+		}
+		data, err := ioutil.ReadAll(lr)
+		if err == nil && max > 0 && int64(len(data)) > max {
+			err = ErrLimitExceeded
+		}
+		return data, err
+	}
+	if size < 0 {
+		return nil, ErrBadFormat
+	}
+	if max > 0 && size > max {
+		return nil, ErrLimitExceeded
+	}
+	if size > preallocSize {
+		// the memory grows with the data actually read, the size of the box being untrusted
+		buf := bytes.NewBuffer(make([]byte, 0, preallocSize))
+		n, err := io.CopyN(buf, r, size)
+		if err == io.EOF && n < size {
+			err = ErrTruncatedBody
+		}
+		return buf.Bytes(), err
+	}
+	data := make([]byte, size)
+	_, err := io.ReadFull(r, data)
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && size > 0) {
+		err = ErrTruncatedBody
+	}
+	return data, err
+}
+
+// checkEntries checks that a table of n entries of entrySize bytes, starting at off, fits in data and in
+// the limits of the decoding
+func checkEntries(r io.Reader, n uint64, entrySize, off int, data []byte) error {
+	if max := limitsOf(r).MaxEntries; max > 0 && n > uint64(max) {
+		return ErrLimitExceeded
+	}
+	if off > len(data) || n*uint64(entrySize) > uint64(len(data)-off) {
+		return ErrBadFormat
+	}
+	return nil
+}
+
+// checkFullBoxSize checks that the body of a full box holds at least size0 bytes in version 0, and size1 in
+// version 1
+func checkFullBoxSize(data []byte, size0, size1 int) error {
+	if len(data) < 4 || len(data) < size0 || (data[0] == 1 && len(data) < size1) {
+		return ErrBadFormat
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package mp4
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	SetLogger(nil)
+}
+
+// SetLogger sets the logger of the library, which is silent by default (nil). The decoding of the boxes is
+// logged at the debug level, the unknown boxes at the warning level.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	logger.Store(l)
+}
+
+// Logger returns the logger of the library (see SetLogger)
+func Logger() *slog.Logger {
+	return logger.Load()
+}
@@ -0,0 +1,44 @@
+package mp4
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetLogger(t *testing.T) {
+	data, err := os.ReadFile("sample/hls-init.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unknown top-level box
+	data = append(data, 0, 0, 0, 9, 'a', 'b', 'c', 'd', 0)
+
+	buf := &bytes.Buffer{}
+	SetLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+	if _, err := Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	log := buf.String()
+	for _, s := range []string{
+		`level=DEBUG msg="decoding box" type=moov path=moov offset=`,
+		`level=DEBUG msg="decoding box" type=stsz path=moov/trak/mdia/minf/stbl/stsz offset=`,
+		`level=WARN msg="unknown box type" type=abcd path=abcd offset=`,
+	} {
+		if !strings.Contains(log, s) {
+			t.Errorf("%q not logged", s)
+		}
+	}
+
+	SetLogger(nil)
+	buf.Reset()
+	if _, err := Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("%d bytes logged without logger", buf.Len())
+	}
+}
@@ -1,38 +1,83 @@
 package mp4
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 )
 
 // Media Data Box (mdat - optional)
 //
 // Status: not decoded
 //
-// The mdat box contains media chunks/samples.
+// The mdat box contains media chunks/samples. A media can contain several mdat boxes.
 //
-// It is not read, only the io.Reader is stored, and will be used to Encode (io.Copy) the box to a io.Writer.
+// The data is not decoded, the box only references it :
+//
+// - if the media is decoded from an io.ReaderAt that is also an io.Seeker (os.File, bytes.Reader,
+// io.SectionReader, ...), the data is skipped, and read again from its offset each time it is needed,
+// - otherwise (pipes, network streams, ...), the data is read and kept in memory.
+//
+// Either way, Encode and Reader always give the data of the box, whatever was read from the media since.
 type MdatBox struct {
-	ContentSize uint64
-	r           io.Reader
+	ContentSize  uint64
+	ra           io.ReaderAt
+	offset       int64 // of the data in ra
+	large        bool  // 64 bits largesize in the source
+	referenced   bool  // decoded from a media, the chunks and track runs of which refer to sourceOffset
+	sourceOffset int64 // of the data in the media it was decoded from
+}
+
+// NewMdatBox returns a mdat box referencing size bytes of data at offset in ra
+func NewMdatBox(ra io.ReaderAt, offset int64, size uint64) *MdatBox {
+	return &MdatBox{
+		ContentSize: size,
+		ra:          ra,
+		offset:      offset,
+	}
 }
 
 func DecodeMdat(h BoxHeader, r io.Reader) (Box, error) {
-	dataSize := uint64(h.Size - BoxHeaderSize)
-	if h.Size == 1 {
-		dataSize = h.LargeSize - uint64(BoxHeaderSize*2)
+	// r is limited to the box
+	size, unbounded := bodySize(h, r)
+	sourceOffset := position(r)
+	src := sourceReader(r)
+	ra, isReaderAt := src.(io.ReaderAt)
+	s, seekable := src.(io.Seeker)
+	if isReaderAt && seekable {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if unbounded {
+			size = end - pos
+		}
+		if size > end-pos {
+			return nil, ErrTruncatedBody
+		}
+		_, err = s.Seek(pos+size, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		skipped(r, size)
+		b := NewMdatBox(ra, pos, uint64(size))
+		b.large = h.Size == 1
+		b.referenced, b.sourceOffset = true, sourceOffset
+		return b, nil
 	}
-	// r is a LimitedReader
-	if lr, limited := r.(*io.LimitedReader); limited {
-		r = lr.R
+	// spooled, within the limits of the boxes read in memory (up to the end of the stream if unbounded)
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
 	}
-	// FIXME:
-	io.CopyN(ioutil.Discard, r, int64(dataSize))
-	return &MdatBox{
-		r:           r,
-		ContentSize: dataSize,
-	}, nil
+	b := NewMdatBox(bytes.NewReader(data), 0, uint64(len(data)))
+	b.large = h.Size == 1
+	b.referenced, b.sourceOffset = true, sourceOffset
+	return b, nil
 }
 
 func (b *MdatBox) Box() Box {
@@ -43,13 +88,40 @@ func (b *MdatBox) Type() string {
 	return "mdat"
 }
 
+// Size returns the size of the box, the header using a 64 bits largesize if the data is larger than 4 GiB
+// (or if it did in the source)
 func (b *MdatBox) Size() int {
-	// FIXME:
-	return BoxHeaderSize + int(b.ContentSize)
+	return sizeWithHeader(b.large, b.ContentSize)
+}
+
+func (b *MdatBox) largeHeader() bool {
+	return b.large
+}
+
+// Offset returns the offset of the data of the box in the io.ReaderAt it was decoded from (0 when the data
+// was read in memory)
+func (b *MdatBox) Offset() int64 {
+	return b.offset
 }
 
-func (b *MdatBox) Reader() io.Reader {
-	return b.r
+// SourceOffset returns the offset of the data of the box in the media it was decoded from, whether the data
+// was read in memory or not, and false if the box was not decoded (see NewMdatBox)
+func (b *MdatBox) SourceOffset() (int64, bool) {
+	return b.sourceOffset, b.referenced
+}
+
+// Reader returns a new reader over the data of the box
+func (b *MdatBox) Reader() *io.SectionReader {
+	ra := b.ra
+	if ra == nil {
+		ra = bytes.NewReader(nil)
+	}
+	return io.NewSectionReader(ra, b.offset, int64(b.ContentSize))
+}
+
+// ReadAt reads the data of the box at offset off (from the beginning of the data)
+func (b *MdatBox) ReadAt(p []byte, off int64) (int, error) {
+	return b.Reader().ReadAt(p, off)
 }
 
 func (b *MdatBox) Encode(w io.Writer) error {
@@ -57,7 +129,10 @@ func (b *MdatBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(w, b.r)
+	n, err := io.Copy(w, b.Reader())
+	if err == nil && uint64(n) != b.ContentSize {
+		err = ErrTruncatedBody
+	}
 	return err
 }
 
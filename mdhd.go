@@ -11,35 +11,48 @@ import (
 //
 // Contained in : Media Box (mdia)
 //
-// Status : only version 0 is decoded. version 1 is not supported
+// Status : decoded
 //
 // Timescale defines the timescale used for tracks.
 // Language is a ISO-639-2/T language code stored as 1bit padding + [3]int5
+//
+// Version 0 stores times and duration on 32 bits, version 1 on 64 bits.
 type MdhdBox struct {
 	Version          byte
 	Flags            [3]byte
-	CreationTime     uint32
-	ModificationTime uint32
+	CreationTime     uint64
+	ModificationTime uint64
 	Timescale        uint32
-	Duration         uint32
+	Duration         uint64
 	Language         uint16
 }
 
 func DecodeMdhd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
-	return &MdhdBox{
-		Version:          data[0],
-		Flags:            [3]byte{data[1], data[2], data[3]},
-		CreationTime:     binary.BigEndian.Uint32(data[4:8]),
-		ModificationTime: binary.BigEndian.Uint32(data[8:12]),
-		Timescale:        binary.BigEndian.Uint32(data[12:16]),
-		Duration:         binary.BigEndian.Uint32(data[16:20]),
-		Language:         binary.BigEndian.Uint16(data[20:22]),
-	}, nil
+	if err = checkFullBoxSize(data, 22, 34); err != nil {
+		return nil, err
+	}
+	b := &MdhdBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	if b.Version == 1 {
+		b.CreationTime = binary.BigEndian.Uint64(data[4:12])
+		b.ModificationTime = binary.BigEndian.Uint64(data[12:20])
+		b.Timescale = binary.BigEndian.Uint32(data[20:24])
+		b.Duration = binary.BigEndian.Uint64(data[24:32])
+		b.Language = binary.BigEndian.Uint16(data[32:34])
+	} else {
+		b.CreationTime = uint64(binary.BigEndian.Uint32(data[4:8]))
+		b.ModificationTime = uint64(binary.BigEndian.Uint32(data[8:12]))
+		b.Timescale = binary.BigEndian.Uint32(data[12:16])
+		b.Duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+		b.Language = binary.BigEndian.Uint16(data[20:22])
+	}
+	return b, nil
 }
 
 func (b *MdhdBox) Box() Box {
@@ -50,12 +63,20 @@ func (b *MdhdBox) Type() string {
 	return "mdhd"
 }
 
+// version returns the version of the box, 1 if a value does not fit in 32 bits
+func (b *MdhdBox) version() byte {
+	return fullBoxVersion(b.Version, b.CreationTime, b.ModificationTime, b.Duration)
+}
+
 func (b *MdhdBox) Size() int {
+	if b.version() == 1 {
+		return BoxHeaderSize + 36
+	}
 	return BoxHeaderSize + 24
 }
 
 func (b *MdhdBox) Dump() {
-	fmt.Printf("Media Header:\n Timescale: %d units/sec\n Duration: %d units (%s)\n", b.Timescale, b.Duration, time.Duration(b.Duration/b.Timescale)*time.Second)
+	fmt.Printf("Media Header:\n Timescale: %d units/sec\n Duration: %d units (%s)\n", b.Timescale, b.Duration, time.Duration(b.Duration/uint64(b.Timescale))*time.Second)
 
 }
 
@@ -65,13 +86,21 @@ func (b *MdhdBox) Encode(w io.Writer) error {
 		return err
 	}
 	buf := makebuf(b)
-	buf[0] = b.Version
+	buf[0] = b.version()
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	binary.BigEndian.PutUint32(buf[4:], b.CreationTime)
-	binary.BigEndian.PutUint32(buf[8:], b.ModificationTime)
-	binary.BigEndian.PutUint32(buf[12:], b.Timescale)
-	binary.BigEndian.PutUint32(buf[16:], b.Duration)
-	binary.BigEndian.PutUint16(buf[20:], b.Language)
+	if b.version() == 1 {
+		binary.BigEndian.PutUint64(buf[4:], b.CreationTime)
+		binary.BigEndian.PutUint64(buf[12:], b.ModificationTime)
+		binary.BigEndian.PutUint32(buf[20:], b.Timescale)
+		binary.BigEndian.PutUint64(buf[24:], b.Duration)
+		binary.BigEndian.PutUint16(buf[32:], b.Language)
+	} else {
+		binary.BigEndian.PutUint32(buf[4:], uint32(b.CreationTime))
+		binary.BigEndian.PutUint32(buf[8:], uint32(b.ModificationTime))
+		binary.BigEndian.PutUint32(buf[12:], b.Timescale)
+		binary.BigEndian.PutUint32(buf[16:], uint32(b.Duration))
+		binary.BigEndian.PutUint16(buf[20:], b.Language)
+	}
 	_, err = w.Write(buf)
 	return err
 }
@@ -15,6 +15,7 @@ type MdiaBox struct {
 	Minf *MinfBox `json:"minf,"`
 	//Elng *ElngBox `json:"elng,omitempty"`
 	Boxes []Box `json:",omitempty"`
+	order []string
 }
 
 func DecodeMdia(h BoxHeader, r io.Reader) (Box, error) {
@@ -22,8 +23,12 @@ func DecodeMdia(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MdiaBox{}
+	m := &MdiaBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "mdhd":
 			m.Mdhd = b.(*MdhdBox)
@@ -46,15 +51,23 @@ func (b *MdiaBox) Type() string {
 	return "mdia"
 }
 
-func (b *MdiaBox) Size() int {
-	sz := b.Mdhd.Size()
+// boxes returns the boxes of the mdia box, in the decoding order
+func (b *MdiaBox) boxes() []Box {
+	l := []Box{}
+	if b.Mdhd != nil {
+		l = append(l, b.Mdhd)
+	}
 	if b.Hdlr != nil {
-		sz += b.Hdlr.Size()
+		l = append(l, b.Hdlr)
 	}
 	if b.Minf != nil {
-		sz += b.Minf.Size()
+		l = append(l, b.Minf)
 	}
-	return sz + BoxHeaderSize
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *MdiaBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *MdiaBox) Dump() {
@@ -62,6 +75,9 @@ func (b *MdiaBox) Dump() {
 	if b.Minf != nil {
 		b.Minf.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func (b *MdiaBox) Encode(w io.Writer) error {
@@ -69,15 +85,5 @@ func (b *MdiaBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	err = b.Mdhd.Encode(w)
-	if err != nil {
-		return err
-	}
-	if b.Hdlr != nil {
-		err = b.Hdlr.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	return b.Minf.Encode(w)
+	return encodeBoxes(b.boxes(), w)
 }
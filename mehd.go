@@ -4,29 +4,40 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"io/ioutil"
 )
 
-// Box Type: ‘mehd’
-// Container: Movie Extends Box(‘mvex’)
-// Mandatory: No
-// Quantity: Zero or one
+// Movie Extends Header Box (mehd - optional)
+//
+// Contained in : Movie Extends Box (mvex)
+//
+// Status: decoded
+//
+// The duration of the whole fragmented movie, fragments included, in the timescale of the movie. Version 0
+// stores the duration on 32 bits, version 1 on 64 bits.
 type MehdBox struct {
 	Version          byte
-	Flags            []byte
-	FragmentDuration uint32
+	Flags            [3]byte
+	FragmentDuration uint64
 }
 
-func DecodeMehdBox(r io.Reader) (Box, error) {
-	data, err := ioutil.ReadAll(r)
+func DecodeMehd(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
-	return &MehdBox{
-		Version:          data[0],
-		Flags:            []byte{data[1], data[2], data[3]},
-		FragmentDuration: binary.BigEndian.Uint32(data[4:8]),
-	}, nil
+	if err = checkFullBoxSize(data, 8, 12); err != nil {
+		return nil, err
+	}
+	b := &MehdBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	if b.Version == 1 {
+		b.FragmentDuration = binary.BigEndian.Uint64(data[4:12])
+	} else {
+		b.FragmentDuration = uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	return b, nil
 }
 
 func (b *MehdBox) Box() Box {
@@ -37,8 +48,16 @@ func (b *MehdBox) Type() string {
 	return "mehd"
 }
 
+// version returns the version of the box, 1 if a value does not fit in 32 bits
+func (b *MehdBox) version() byte {
+	return fullBoxVersion(b.Version, b.FragmentDuration)
+}
+
 func (b *MehdBox) Size() int {
-	return BoxHeaderSize + 4 + 4
+	if b.version() == 1 {
+		return BoxHeaderSize + 12
+	}
+	return BoxHeaderSize + 8
 }
 
 func (b *MehdBox) Encode(w io.Writer) error {
@@ -47,15 +66,18 @@ func (b *MehdBox) Encode(w io.Writer) error {
 		return err
 	}
 	buf := makebuf(b)
-	buf[0] = b.Version
+	buf[0] = b.version()
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	binary.BigEndian.PutUint32(buf[4:], b.FragmentDuration)
+	if b.version() == 1 {
+		binary.BigEndian.PutUint64(buf[4:], b.FragmentDuration)
+	} else {
+		binary.BigEndian.PutUint32(buf[4:], uint32(b.FragmentDuration))
+	}
 	_, err = w.Write(buf)
 	return err
 }
 
 func (b *MehdBox) Dump() {
 	fmt.Printf("Movie Extends Header Box\n")
-	fmt.Printf(" Fragment Duration: %d\n", b.FragmentDuration)
-
+	fmt.Printf("+- Fragment Duration: %d\n", b.FragmentDuration)
 }
@@ -11,24 +11,50 @@ import (
 //	 Movie	Fragment	Box	(‘moof’)	or	Track	Fragment	Box	(‘traf’)
 // Mandatory:	No
 // Quantity:	 Zero	or	one	(in	File,	‘moov’,	and	‘trak’),	One	or	more	(in	‘meco’)
+//
+// The meta box is a full box, but QuickTime files store it without version and flags : both forms are
+// decoded, and encoded as they were.
 type MetaBox struct {
-	Hdlr *HdlrBox `json:"hdlr,"`
-	Bxml *BxmlBox `json:"bxml,omitempty"`
-	Dinf *DinfBox `json:"dinf,omitempty"`
-	Iloc *IlocBox `json:"iloc,omitempty"`
+	Version byte
+	Flags   [3]byte
+	Hdlr    *HdlrBox `json:"hdlr,"`
+	Bxml    *BxmlBox `json:"bxml,omitempty"`
+	Dinf    *DinfBox `json:"dinf,omitempty"`
+	Iloc    *IlocBox `json:"iloc,omitempty"`
 	//Pitm *PitmBox `json:"pitm,omitempty"`
 	//Ipro *IproBox `json:"ipro,omitempty
 	//Iinf *IinfBox `json:"iinf,omitempty"`
-	Boxes []Box `json:",omitempty"`
+	Boxes     []Box `json:",omitempty"`
+	order     []string
+	quickTime bool // no version and flags
 }
 
 func DecodeMeta(h BoxHeader, r io.Reader) (Box, error) {
-	l, err := DecodeContainer(r)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
 	m := &MetaBox{}
+	if len(data) >= 8 && string(data[4:8]) == "hdlr" {
+		m.quickTime = true
+	} else {
+		if len(data) < 4 {
+			return nil, ErrTruncatedBody
+		}
+		m.Version = data[0]
+		m.Flags = [3]byte{data[1], data[2], data[3]}
+		data = data[4:]
+	}
+	l, err := DecodeContainer(childReader(r, data))
+	if err != nil {
+		return nil, err
+	}
+	m.order = boxTypes(l)
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "hdlr":
 			m.Hdlr = b.(*HdlrBox)
@@ -53,13 +79,28 @@ func (b *MetaBox) Type() string {
 	return "meta"
 }
 
-func (b *MetaBox) Size() int {
-	l := BoxHeaderSize
+// boxes returns the boxes of the meta box, in the decoding order
+func (b *MetaBox) boxes() []Box {
+	l := []Box{}
 	if b.Hdlr != nil {
-		l += b.Hdlr.Size()
+		l = append(l, b.Hdlr)
 	}
 	if b.Dinf != nil {
-		l += b.Dinf.Size()
+		l = append(l, b.Dinf)
+	}
+	if b.Iloc != nil {
+		l = append(l, b.Iloc)
+	}
+	if b.Bxml != nil {
+		l = append(l, b.Bxml)
+	}
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *MetaBox) Size() int {
+	l := BoxHeaderSize + boxesSize(b.boxes())
+	if !b.quickTime {
+		l += 4
 	}
 	return l
 }
@@ -69,19 +110,13 @@ func (b *MetaBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	if b.Hdlr != nil {
-		err = b.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Dinf != nil {
-		err = b.Encode(w)
+	if !b.quickTime {
+		_, err = w.Write([]byte{b.Version, b.Flags[0], b.Flags[1], b.Flags[2]})
 		if err != nil {
 			return err
 		}
 	}
-	return err
+	return encodeBoxes(b.boxes(), w)
 }
 
 func (b *MetaBox) Dump() {
@@ -92,4 +127,7 @@ func (b *MetaBox) Dump() {
 	if b.Dinf != nil {
 		b.Dinf.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
@@ -46,11 +46,13 @@ func (b *MfhdBox) Dump() {
 }
 
 func DecodeMfhd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	return &MfhdBox{
 		Version:        data[0],
 		Flags:          [3]byte{data[1], data[2], data[3]},
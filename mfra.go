@@ -10,9 +10,10 @@ import (
 // Mandatory:	No
 // Quantity:	 Zero	or	one
 type MfraBox struct {
-	Tfra  *TfraBox `json:"tfra,omitempty"`
-	Mfro  *MfroBox `json:"mfro,"`
-	Boxes []Box    `json:",omitempty"`
+	Tfra  []*TfraBox `json:"tfra,omitempty"`
+	Mfro  *MfroBox   `json:"mfro,"`
+	Boxes []Box      `json:",omitempty"`
+	order []string
 }
 
 func DecodeMfra(h BoxHeader, r io.Reader) (Box, error) {
@@ -20,11 +21,15 @@ func DecodeMfra(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MfraBox{}
+	m := &MfraBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "tfra":
-			m.Tfra = b.(*TfraBox)
+			m.Tfra = append(m.Tfra, b.(*TfraBox))
 		case "mfro":
 			m.Mfro = b.(*MfroBox)
 		default:
@@ -42,15 +47,20 @@ func (b *MfraBox) Type() string {
 	return "mfra"
 }
 
-func (b *MfraBox) Size() int {
-	l := BoxHeaderSize
-	if b.Tfra != nil {
-		l += b.Tfra.Size()
+// boxes returns the boxes of the mfra box, in the decoding order
+func (b *MfraBox) boxes() []Box {
+	l := []Box{}
+	for _, t := range b.Tfra {
+		l = append(l, t)
 	}
 	if b.Mfro != nil {
-		l += b.Mfro.Size()
+		l = append(l, b.Mfro)
 	}
-	return l
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *MfraBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *MfraBox) Encode(w io.Writer) error {
@@ -58,27 +68,18 @@ func (b *MfraBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	if b.Tfra != nil {
-		err = b.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Mfro != nil {
-		err = b.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	return err
+	return encodeBoxes(b.boxes(), w)
 }
 
 func (b *MfraBox) Dump() {
 	fmt.Printf("Movie Fragment Random Access Box\n")
-	if b.Tfra != nil {
-		b.Tfra.Dump()
+	for _, t := range b.Tfra {
+		t.Dump()
 	}
 	if b.Mfro != nil {
 		b.Mfro.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
@@ -17,11 +17,13 @@ type MfroBox struct {
 }
 
 func DecodeMfro(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	return &MfroBox{
 		Version: data[0],
 		Flags:   []byte{data[1], data[2], data[3]},
@@ -15,6 +15,7 @@ type MinfBox struct {
 	Dinf  *DinfBox `json:"dinf,"`
 	Hdlr  *HdlrBox `json:"hdlr,omitempty"` // ISO IEC 14496-12, does not contain hdlr in minf
 	Boxes []Box    `json:",omitempty"`
+	order []string
 }
 
 func DecodeMinf(h BoxHeader, r io.Reader) (Box, error) {
@@ -22,8 +23,12 @@ func DecodeMinf(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MinfBox{}
+	m := &MinfBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "vmhd":
 			m.Vmhd = b.(*VmhdBox)
@@ -50,26 +55,36 @@ func (b *MinfBox) Type() string {
 	return "minf"
 }
 
-func (b *MinfBox) Size() int {
-	sz := 0
+// boxes returns the boxes of the minf box, in the decoding order
+func (b *MinfBox) boxes() []Box {
+	l := []Box{}
 	if b.Vmhd != nil {
-		sz += b.Vmhd.Size()
+		l = append(l, b.Vmhd)
 	}
 	if b.Smhd != nil {
-		sz += b.Smhd.Size()
+		l = append(l, b.Smhd)
 	}
-	sz += b.Stbl.Size()
 	if b.Dinf != nil {
-		sz += b.Dinf.Size()
+		l = append(l, b.Dinf)
+	}
+	if b.Stbl != nil {
+		l = append(l, b.Stbl)
 	}
 	if b.Hdlr != nil {
-		sz += b.Hdlr.Size()
+		l = append(l, b.Hdlr)
 	}
-	return sz + BoxHeaderSize
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *MinfBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *MinfBox) Dump() {
 	b.Stbl.Dump()
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func (b *MinfBox) Encode(w io.Writer) error {
@@ -77,28 +92,5 @@ func (b *MinfBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	if b.Vmhd != nil {
-		err = b.Vmhd.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Smhd != nil {
-		err = b.Smhd.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	err = b.Dinf.Encode(w)
-	if err != nil {
-		return err
-	}
-	err = b.Stbl.Encode(w)
-	if err != nil {
-		return err
-	}
-	if b.Hdlr != nil {
-		return b.Hdlr.Encode(w)
-	}
-	return nil
+	return encodeBoxes(b.boxes(), w)
 }
@@ -13,6 +13,9 @@ type MoofBox struct {
 	Mfhd  *MfhdBox   `json:"mfhd,"`
 	Traf  []*TrafBox `json:"traf,omitempty"`
 	Boxes []Box      `json:",omitempty"`
+	order []string
+	// offset of the box in the media, the base of the data offsets of the fragment
+	offset int64
 }
 
 func (b *MoofBox) Box() Box {
@@ -23,39 +26,44 @@ func (b *MoofBox) Type() string {
 	return "moof"
 }
 
-func (b *MoofBox) Size() int {
-	l := BoxHeaderSize + b.Mfhd.Size()
-	for _, e := range b.Traf {
-		l = l + e.Size()
+// boxes returns the boxes of the moof box, in the decoding order
+func (b *MoofBox) boxes() []Box {
+	l := []Box{}
+	if b.Mfhd != nil {
+		l = append(l, b.Mfhd)
+	}
+	for _, t := range b.Traf {
+		l = append(l, t)
 	}
-	return l
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *MoofBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
+
 func (b *MoofBox) Encode(w io.Writer) error {
 	err := EncodeHeader(b, w)
 	if err != nil {
 		return err
 	}
-	err = b.Mfhd.Encode(w)
-	if err != nil {
-		return err
-	}
+	return encodeBoxes(b.boxes(), w)
+}
 
-	if b.Traf != nil {
-		for _, t := range b.Traf {
-			err := t.Encode(w)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return err
+// Offset returns the offset of the box from the beginning of the media (set by Decode)
+func (b *MoofBox) Offset() int64 {
+	return b.offset
 }
+
 func (b *MoofBox) Dump() {
 	fmt.Printf("Movie Fragment Box\n")
 	b.Mfhd.Dump()
 	for _, t := range b.Traf {
 		t.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func DecodeMoof(h BoxHeader, r io.Reader) (Box, error) {
@@ -63,8 +71,12 @@ func DecodeMoof(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MoofBox{}
+	m := &MoofBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "mfhd":
 			m.Mfhd = b.(*MfhdBox)
@@ -18,6 +18,7 @@ type MoovBox struct {
 	Udta  *UdtaBox   `json:"udta,omitempty"`
 	Meta  *MetaBox   `json:"meta,omitempty"`
 	Boxes []Box      `json:",omitempty"`
+	order []string
 }
 
 func DecodeMoov(h BoxHeader, r io.Reader) (Box, error) {
@@ -25,8 +26,12 @@ func DecodeMoov(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MoovBox{}
+	m := &MoovBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "mvhd":
 			m.Mvhd = b.(*MvhdBox)
@@ -55,24 +60,32 @@ func (b *MoovBox) Type() string {
 	return "moov"
 }
 
-func (b *MoovBox) Size() int {
-	sz := b.Mvhd.Size()
+// boxes returns the boxes of the moov box, in the decoding order
+func (b *MoovBox) boxes() []Box {
+	l := []Box{}
+	if b.Mvhd != nil {
+		l = append(l, b.Mvhd)
+	}
 	if b.Iods != nil {
-		sz += b.Iods.Size()
+		l = append(l, b.Iods)
 	}
 	for _, t := range b.Trak {
-		sz += t.Size()
+		l = append(l, t)
 	}
 	if b.Udta != nil {
-		sz += b.Udta.Size()
+		l = append(l, b.Udta)
 	}
 	if b.Mvex != nil {
-		sz += b.Mvex.Size()
+		l = append(l, b.Mvex)
 	}
 	if b.Meta != nil {
-		sz += b.Meta.Size()
+		l = append(l, b.Meta)
 	}
-	return sz + BoxHeaderSize
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *MoovBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *MoovBox) Dump() {
@@ -84,6 +97,9 @@ func (b *MoovBox) Dump() {
 	if b.Mvex != nil {
 		b.Mvex.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func (b *MoovBox) Encode(w io.Writer) error {
@@ -91,40 +107,5 @@ func (b *MoovBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	err = b.Mvhd.Encode(w)
-	if err != nil {
-		return err
-	}
-	if b.Iods != nil {
-		err = b.Iods.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	for _, t := range b.Trak {
-		err = t.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Udta != nil {
-		err = b.Udta.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Mvex != nil {
-		err = b.Mvex.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Meta != nil {
-		err = b.Meta.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return encodeBoxes(b.boxes(), w)
 }
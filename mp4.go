@@ -10,7 +10,7 @@ import (
 //
 //   ftyp : the file type box
 //   moov : the movie box (meta-data)
-//   mdat : the media data (chunks and samples)
+//   mdat : the media data (chunks and samples), possibly in several boxes
 //
 // Other boxes can also be present (pdin, moof, mfra, free, ...)
 type MP4 struct {
@@ -19,7 +19,7 @@ type MP4 struct {
 	Pdin *PdinBox   `json:"pdin,omitempty"`
 	Moov *MoovBox   `json:"moov,omitempty"`
 	Moof []*MoofBox `json:"moof,omitempty"`
-	Mdat *MdatBox   `json:"mdat,omitempty"`
+	Mdat []*MdatBox `json:"mdat,omitempty"`
 	Free []*FreeBox `json:"free,omitempty"`
 	//Skip  []*SkipBox `json:"skip,omitempty"`
 	Udta *UdtaBox   `json:"udta,omitempty"`
@@ -29,29 +29,39 @@ type MP4 struct {
 	Mfra *MfraBox `json:"mfra,omitempty"`
 	Meta *MetaBox `json:"meta,omitempty"`
 	//Meco  *MetaBox `json:"meco,omitempty"`
-	boxes []Box `json:",omitempty"`
-}
-
-type fMP4 struct {
-	Styp *StypBox `json:"styp,omitempty"`
-	//Pdin  *PdinBox `json:"pdin,omitempty"`
-	Moof  []*MoofBox `json:"moof,omitempty"`
-	Mfra  *MfraBox   `json:"mfra,omitempty"`
-	Mdat  *MdatBox   `json:"mdat,omitempty"`
-	Free  []*FreeBox `json:"free,omitempty"`
-	boxes []Box      `json:",omitempty"`
+	// Other top-level boxes : the registered boxes (see RegisterBox) and the unknown boxes
+	Other []Box `json:"boxes,omitempty"`
+	// The errors recovered from in lenient mode (see DecodeOptions)
+	Warnings []*DecodeError `json:"warnings,omitempty"`
+	order    []string
 }
 
 // Decode decodes a media from a Reader
 func Decode(r io.Reader) (*MP4, error) {
+	return DecodeWithOptions(r, DecodeOptions{})
+}
+
+// DecodeWithOptions decodes a media from a Reader, with a mode and limits of its own
+func DecodeWithOptions(r io.Reader, o DecodeOptions) (*MP4, error) {
 	v := &MP4{
-		boxes: []Box{},
+		Other: []Box{},
 	}
-	l, err := DecodeContainer(r)
+	root := rootReader(r)
+	if o.Limits != nil {
+		root.limits = o.Limits
+	}
+	root.decoding = &decoding{mode: o.Mode}
+	l, offsets, err := decodeContainer(root)
 	if err != nil {
 		return nil, err
 	}
-	for _, b := range l {
+	v.order = boxTypes(l)
+	v.Warnings = root.decoding.warnings
+	for i, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			v.Other = append(v.Other, b)
+			continue
+		}
 		switch b.Type() {
 		case "ftyp":
 			v.Ftyp = b.(*FtypBox)
@@ -62,11 +72,13 @@ func Decode(r io.Reader) (*MP4, error) {
 		case "meta":
 			v.Meta = b.(*MetaBox)
 		case "moof":
-			v.Moof = append(v.Moof, b.(*MoofBox))
+			moof := b.(*MoofBox)
+			moof.offset = offsets[i]
+			v.Moof = append(v.Moof, moof)
 		case "moov":
 			v.Moov = b.(*MoovBox)
 		case "mdat":
-			v.Mdat = b.(*MdatBox)
+			v.Mdat = append(v.Mdat, b.(*MdatBox))
 		case "sidx":
 			v.Sidx = append(v.Sidx, b.(*SidxBox))
 		case "free":
@@ -76,11 +88,7 @@ func Decode(r io.Reader) (*MP4, error) {
 		//case "udta":
 		//	v.Udta = b.(*UdtaBox)
 		default:
-			if decoders[b.Type()] != nil {
-				v.boxes = append(v.boxes, b.Box())
-			} else {
-				v.boxes = append(v.boxes, b.(*UkwnBox))
-			}
+			v.Other = append(v.Other, b)
 		}
 	}
 	return v, nil
@@ -106,8 +114,8 @@ func (m *MP4) Dump() {
 	if m.Meta != nil {
 		m.Meta.Dump()
 	}
-	if m.Mdat != nil {
-		m.Mdat.Dump()
+	for _, d := range m.Mdat {
+		d.Dump()
 	}
 	for _, s := range m.Sidx {
 		s.Dump()
@@ -115,67 +123,79 @@ func (m *MP4) Dump() {
 	for _, f := range m.Moof {
 		f.Dump()
 	}
-	for _, b := range m.boxes {
+	for _, b := range m.Other {
 		b.Dump()
 	}
 }
 
-// Boxes lists the top-level boxes from a media
+// Boxes lists the top-level boxes from a media, in the order in which they were decoded
 func (m *MP4) Boxes() []Box {
-	return m.boxes
-}
-
-// Encode encodes a media to a Writer
-func (m *MP4) Encode(w io.Writer) error {
+	l := []Box{}
 	if m.Ftyp != nil {
-		err := m.Ftyp.Encode(w)
-		if err != nil {
-			return err
-		}
+		l = append(l, m.Ftyp)
 	}
 	if m.Styp != nil {
-		err := m.Styp.Encode(w)
-		if err != nil {
-			return err
-		}
+		l = append(l, m.Styp)
+	}
+	if m.Pdin != nil {
+		l = append(l, m.Pdin)
 	}
 	if m.Moov != nil {
-		err := m.Moov.Encode(w)
-		if err != nil {
-			return err
-		}
+		l = append(l, m.Moov)
 	}
-	if m.Mdat != nil {
-		err := m.Mdat.Encode(w)
-		if err != nil {
-			return err
-		}
+	if m.Meta != nil {
+		l = append(l, m.Meta)
 	}
-	for _, f := range m.Free {
-		err := f.Encode(w)
-		if err != nil {
-			return err
-		}
+	if m.Udta != nil {
+		l = append(l, m.Udta)
 	}
 	for _, s := range m.Sidx {
-		err := s.Encode(w)
-		if err != nil {
-			return err
-		}
+		l = append(l, s)
+	}
+	for _, f := range m.Moof {
+		l = append(l, f)
+	}
+	for _, d := range m.Mdat {
+		l = append(l, d)
+	}
+	for _, f := range m.Free {
+		l = append(l, f)
 	}
 	if m.Mfra != nil {
-		err := m.Mfra.Encode(w)
-		if err != nil {
-			return err
-		}
+		l = append(l, m.Mfra)
 	}
-	for _, b := range m.boxes {
-		if b.Type() != "ftyp" && b.Type() != "moov" {
-			err := b.Encode(w)
-			if err != nil {
-				return err
-			}
+	return orderedBoxes(m.order, append(l, m.Other...))
+}
+
+// MdatSpan is the data of a mdat box, at the offsets of the media it was decoded from
+type MdatSpan struct {
+	Start, End uint64
+	Mdat       *MdatBox
+}
+
+// MdatSpans returns the spans of the data of the mdat boxes (m.Mdat) in the media they were decoded from,
+// i.e. the offsets the chunks and the track runs refer to. The boxes that were not decoded (see
+// NewMdatBox) are placed after the boxes preceding them.
+func (m *MP4) MdatSpans() []MdatSpan {
+	spans := []MdatSpan{}
+	var off uint64
+	for _, b := range m.Boxes() {
+		d, ok := b.(*MdatBox)
+		if !ok {
+			off += uint64(b.Size())
+			continue
 		}
+		start := off + uint64(d.Size()) - d.ContentSize
+		if offset, ok := d.SourceOffset(); ok {
+			start = uint64(offset)
+		}
+		off = start + d.ContentSize
+		spans = append(spans, MdatSpan{Start: start, End: off, Mdat: d})
 	}
-	return nil
+	return spans
+}
+
+// Encode encodes a media to a Writer, the boxes being written in the order in which they were decoded
+func (m *MP4) Encode(w io.Writer) error {
+	return encodeBoxes(m.Boxes(), w)
 }
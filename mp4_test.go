@@ -0,0 +1,181 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeEncode(t *testing.T) {
+	files, err := filepath.Glob("sample/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := m.Encode(buf); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: the encoded media differs from the source", file)
+		}
+	}
+}
+
+// decodeBox decodes a box encoded in data
+func decodeBox(t *testing.T, data []byte) Box {
+	t.Helper()
+	r := rootReader(bytes.NewReader(data))
+	h, err := DecodeHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := DecodeBox(h, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeMdatSpooled(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xab}, 100)
+	data := append(boxBytes("mdat", payload), boxBytes("free", nil)...)
+	// the mdat of a non-seekable reader is read in memory
+	r := struct{ io.Reader }{bytes.NewReader(data)}
+	m, err := Decode(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Mdat) != 1 || len(m.Free) != 1 {
+		t.Fatalf("got %d mdat, %d free", len(m.Mdat), len(m.Free))
+	}
+	body, err := io.ReadAll(m.Mdat[0].Reader())
+	if err != nil || !bytes.Equal(body, payload) {
+		t.Errorf("mdat body % x (%v)", body, err)
+	}
+	// within the size limit of the boxes read in memory
+	r = struct{ io.Reader }{bytes.NewReader(data)}
+	_, err = DecodeWithOptions(r, DecodeOptions{Limits: &Limits{MaxBoxSize: 50}})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("mdat larger than MaxBoxSize: %v", err)
+	}
+}
+
+func TestMdatSpans(t *testing.T) {
+	data := append(boxBytes("free", nil), boxBytes("mdat", make([]byte, 100))...)
+	for _, r := range []io.Reader{bytes.NewReader(data), struct{ io.Reader }{bytes.NewReader(data)}} {
+		m, err := Decode(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the offsets of the source, whatever the boxes preceding the mdat box once decoded
+		m.Free = nil
+		if spans := m.MdatSpans(); len(spans) != 1 || spans[0].Start != 16 || spans[0].End != 116 || spans[0].Mdat != m.Mdat[0] {
+			t.Errorf("%T: spans %v, want [{16 116}]", r, spans)
+		}
+		// the boxes not decoded follow the boxes preceding them
+		m.Mdat = append(m.Mdat, NewMdatBox(bytes.NewReader(nil), 0, 0))
+		if spans := m.MdatSpans(); len(spans) != 2 || spans[1].Start != 124 || spans[1].End != 124 {
+			t.Errorf("%T: spans %v, want [{16 116} {124 124}]", r, spans)
+		}
+	}
+}
+
+func TestDecodeErrorOffset(t *testing.T) {
+	data, err := os.ReadFile("sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sample count of the first stsz, after the mdat
+	i := bytes.Index(data, []byte("stsz")) - 4
+	data[i+16], data[i+17], data[i+18], data[i+19] = 0x7f, 0xff, 0xff, 0xff
+	_, err = Decode(bytes.NewReader(data))
+	var e *DecodeError
+	if !errors.As(err, &e) || e.Path != "moov/trak/mdia/minf/stbl/stsz" || e.Offset != int64(i) {
+		t.Errorf("got %v, want stsz at offset %d", err, i)
+	}
+}
+
+func TestDecodeSizeToEnd(t *testing.T) {
+	ftyp := boxBytes("ftyp", []byte{'i', 's', 'o', 'm', 0, 0, 0, 1})
+	payload := bytes.Repeat([]byte{0xab}, 100)
+	for _, typ := range []string{"free", "mdat"} {
+		// a box extending to the end of a media of unknown size
+		data := append(bytes.Clone(ftyp), 0, 0, 0, 0, typ[0], typ[1], typ[2], typ[3])
+		data = append(data, payload...)
+		m, err := Decode(struct{ io.Reader }{bytes.NewReader(data)})
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		var body []byte
+		switch typ {
+		case "free":
+			if len(m.Free) != 1 {
+				t.Fatalf("%s: %d boxes", typ, len(m.Free))
+			}
+			body = m.Free[0].notDecoded
+		case "mdat":
+			if len(m.Mdat) != 1 {
+				t.Fatalf("%s: %d boxes", typ, len(m.Mdat))
+			}
+			body, err = io.ReadAll(m.Mdat[0].Reader())
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(body, payload) {
+			t.Errorf("%s: body % x", typ, body)
+		}
+	}
+}
+
+func TestDecodeEncodeUnknownBoxes(t *testing.T) {
+	data, err := os.ReadFile("sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// find the moov box and its mvhd box
+	var moov int
+	for moov < len(data) && string(data[moov+4:moov+8]) != "moov" {
+		moov += int(binary.BigEndian.Uint32(data[moov:]))
+	}
+	if moov >= len(data) || string(data[moov+12:moov+16]) != "mvhd" {
+		t.Fatal("moov box not found")
+	}
+	mvhd := moov + 8
+	mvhdEnd := mvhd + int(binary.BigEndian.Uint32(data[mvhd:]))
+	// unknown boxes before and after the mvhd box
+	unknown := func(typ string) []byte {
+		return append([]byte{0, 0, 0, 12}, append([]byte(typ), 1, 2, 3, 4)...)
+	}
+	src := append([]byte{}, data[:mvhd]...)
+	src = append(src, unknown("abcd")...)
+	src = append(src, data[mvhd:mvhdEnd]...)
+	src = append(src, unknown("efgh")...)
+	src = append(src, data[mvhdEnd:]...)
+	binary.BigEndian.PutUint32(src[moov:], binary.BigEndian.Uint32(data[moov:])+24)
+
+	m, err := Decode(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := m.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), src) {
+		t.Error("the encoded media differs from the source")
+	}
+}
@@ -5,9 +5,19 @@ import (
 	"io"
 )
 
+// Movie Extends Box (mvex - optional)
+//
+// Contained in : Movie Box (moov)
+//
+// Status: decoded
+//
+// Signals that the media contains movie fragments, and holds the sample defaults of each track (one trex
+// per track).
 type MvexBox struct {
-	Trex *TrexBox `json:"trex"`
-	Mehd *MehdBox `json:"mehd,omitempty"`
+	Trex  []*TrexBox `json:"trex"`
+	Mehd  *MehdBox   `json:"mehd,omitempty"`
+	Boxes []Box      `json:",omitempty"`
+	order []string
 }
 
 func DecodeMvex(h BoxHeader, r io.Reader) (Box, error) {
@@ -15,13 +25,19 @@ func DecodeMvex(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &MvexBox{}
+	m := &MvexBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			m.Boxes = append(m.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "trex":
-			m.Trex = b.(*TrexBox)
+			m.Trex = append(m.Trex, b.(*TrexBox))
 		case "mehd":
 			m.Mehd = b.(*MehdBox)
+		default:
+			m.Boxes = append(m.Boxes, b.Box())
 		}
 	}
 	return m, err
@@ -35,34 +51,52 @@ func (b *MvexBox) Type() string {
 	return "mvex"
 }
 
-func (b *MvexBox) Size() int {
-	l := BoxHeaderSize + b.Trex.Size()
+// boxes returns the boxes of the mvex box, in the decoding order
+func (b *MvexBox) boxes() []Box {
+	l := []Box{}
 	if b.Mehd != nil {
-		l = l + b.Mehd.Size()
+		l = append(l, b.Mehd)
+	}
+	for _, t := range b.Trex {
+		l = append(l, t)
 	}
-	return l
+	return orderedBoxes(b.order, append(l, b.Boxes...))
 }
 
-func (b *MvexBox) Encode(w io.Writer) error {
-	err := EncodeHeader(b, w)
-	if err != nil {
-		return err
+func (b *MvexBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
+}
+
+// TrackExtends returns the trex box of a track, nil if not found or if b is nil
+func (b *MvexBox) TrackExtends(trackID uint32) *TrexBox {
+	if b == nil {
+		return nil
 	}
-	err = b.Trex.Encode(w)
-	if err != nil {
-		return err
+	for _, t := range b.Trex {
+		if t.TrackId == trackID {
+			return t
+		}
 	}
-	err = b.Mehd.Encode(w)
+	return nil
+}
+
+func (b *MvexBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
 	if err != nil {
 		return err
 	}
-	return err
+	return encodeBoxes(b.boxes(), w)
 }
 
 func (b *MvexBox) Dump() {
 	fmt.Printf("Movie Extends Box\n")
-	b.Trex.Dump()
 	if b.Mehd != nil {
 		b.Mehd.Dump()
 	}
+	for _, t := range b.Trex {
+		t.Dump()
+	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
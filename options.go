@@ -0,0 +1,110 @@
+package mp4
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	ErrBoxSizeMismatch = errors.New("box size does not match its content")
+)
+
+// DecodeMode defines how the violations of the specification found in a media are handled
+type DecodeMode int
+
+const (
+	// DecodeDefault fails on the boxes that cannot be decoded, and tolerates the minor violations
+	DecodeDefault DecodeMode = iota
+	// DecodeStrict also fails on the boxes which size does not match their content (data left at the end,
+	// ...), and on the boxes of size 0 that are not top-level boxes
+	DecodeStrict
+	// DecodeLenient keeps the boxes that cannot be decoded as RawBox, and continues with the next box. The
+	// errors are returned as warnings (see MP4.Warnings).
+	DecodeLenient
+)
+
+func (m DecodeMode) String() string {
+	switch m {
+	case DecodeStrict:
+		return "strict"
+	case DecodeLenient:
+		return "lenient"
+	}
+	return "default"
+}
+
+// DecodeOptions are the options of DecodeWithOptions
+type DecodeOptions struct {
+	Mode   DecodeMode
+	Limits *Limits // the limits set with SetLimits if nil
+}
+
+// decoding holds the options of a decoding, and the warnings of the lenient mode
+type decoding struct {
+	mode     DecodeMode
+	warnings []*DecodeError
+}
+
+// decodeMode returns the mode of the decoding of the box read by r
+func decodeMode(r io.Reader) DecodeMode {
+	if br, ok := r.(*boxReader); ok && br.decoding != nil {
+		return br.decoding.mode
+	}
+	return DecodeDefault
+}
+
+// warn records an error recovered from in lenient mode
+func warn(r *boxReader, err error) {
+	de, ok := err.(*DecodeError)
+	if !ok {
+		de = &DecodeError{Path: r.path, Offset: position(r), Err: err}
+	}
+	Logger().Warn("box not decoded", "path", de.Path, "offset", de.Offset, "error", de.Err)
+	if r.decoding != nil {
+		r.decoding.warnings = append(r.decoding.warnings, de)
+	}
+}
+
+// decodeBoxLenient decodes a box read by r in lenient mode. Its body is read first, so that the box can be
+// kept as a RawBox if it cannot be decoded.
+func decodeBoxLenient(h BoxHeader, r *boxReader) (Box, error) {
+	size := h.boxSize() - h.headerSize()
+	if h.Type == "mdat" || h.Size == 0 || size < 0 {
+		// not read in memory
+		return DecodeBox(h, r)
+	}
+	if max := limitsOf(r).MaxBoxSize; max > 0 && size > max {
+		return DecodeBox(h, r)
+	}
+	pos := position(r)
+	data, err := ioutil.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return nil, err
+	}
+	var de *DecodeError
+	if int64(len(data)) < size {
+		de = &DecodeError{Path: childPath(r, h.Type), Offset: pos - h.headerSize(), Err: ErrTruncatedBody}
+	} else {
+		b, err := DecodeBox(h, siblingReader(r, data, pos))
+		if err == nil {
+			return b, nil
+		}
+		if !errors.As(err, &de) {
+			de = &DecodeError{Path: r.path, Offset: pos - h.headerSize(), Err: err}
+		}
+	}
+	warn(r, de)
+	return &RawBox{Header: h, Data: data, Err: de}, nil
+}
+
+// checkStrict checks a box decoded in strict mode, body being the reader of its body
+func checkStrict(h BoxHeader, b Box, body *boxReader) error {
+	if _, ok := b.(*UkwnBox); ok || h.Size <= 1 {
+		return nil
+	}
+	if int64(b.Size()) != h.boxSize() {
+		return ErrBoxSizeMismatch
+	}
+	return nil
+}
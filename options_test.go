@@ -0,0 +1,73 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+// grow inserts extra at the end of the box of the given type, found after the boxes containing it (e.g.
+// "moov", "trak", "tkhd" for the first tkhd box), whose sizes are increased
+func grow(t *testing.T, data []byte, extra []byte, path ...string) ([]byte, int) {
+	t.Helper()
+	data = append([]byte{}, data...)
+	offset := 0
+	for _, typ := range path {
+		i := bytes.Index(data[offset:], []byte(typ)) - 4
+		if i < 0 {
+			t.Fatalf("%s box not found", typ)
+		}
+		offset += i
+		binary.BigEndian.PutUint32(data[offset:], binary.BigEndian.Uint32(data[offset:])+uint32(len(extra)))
+	}
+	end := offset + int(binary.BigEndian.Uint32(data[offset:])) - len(extra)
+	return append(data[:end], append(extra, data[end:]...)...), offset
+}
+
+func TestDecodeModes(t *testing.T) {
+	data, err := os.ReadFile("sample/hls-init.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stsz box of the first track declares 1000 samples, with the size of a single one
+	corrupted, stsz := grow(t, data, []byte{0, 0, 0, 1}, "moov", "trak", "mdia", "minf", "stbl", "stsz")
+	binary.BigEndian.PutUint32(corrupted[stsz+16:], 1000)
+	path := "moov/trak/mdia/minf/stbl/stsz"
+
+	for _, mode := range []DecodeMode{DecodeDefault, DecodeStrict} {
+		_, err := DecodeWithOptions(bytes.NewReader(corrupted), DecodeOptions{Mode: mode})
+		var de *DecodeError
+		if !errors.As(err, &de) || de.Path != path || de.Offset != int64(stsz) {
+			t.Errorf("%s: got %v, want an error of the box %s at offset %d", mode, err, path, stsz)
+		}
+	}
+
+	m, err := DecodeWithOptions(bytes.NewReader(corrupted), DecodeOptions{Mode: DecodeLenient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Warnings) != 1 || m.Warnings[0].Path != path || m.Warnings[0].Offset != int64(stsz) {
+		t.Fatalf("lenient: got the warnings %v, want one warning of the box %s at offset %d", m.Warnings, path, stsz)
+	}
+	// the box is kept as is
+	buf := &bytes.Buffer{}
+	if err := m.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), corrupted) {
+		t.Error("lenient: the encoded media differs from the source")
+	}
+
+	// data left at the end of the tkhd box of the first track, only rejected in strict mode
+	corrupted, _ = grow(t, data, []byte{0, 0, 0, 0}, "moov", "trak", "tkhd")
+	if _, err := DecodeWithOptions(bytes.NewReader(corrupted), DecodeOptions{Mode: DecodeDefault}); err != nil {
+		t.Errorf("default: %v", err)
+	}
+	_, err = DecodeWithOptions(bytes.NewReader(corrupted), DecodeOptions{Mode: DecodeStrict})
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Path != "moov/trak/tkhd" || !errors.Is(err, ErrBoxSizeMismatch) {
+		t.Errorf("strict: got %v, want an ErrBoxSizeMismatch error of the box moov/trak/tkhd", err)
+	}
+}
@@ -0,0 +1,232 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Parser decodes a media written to it in pieces of any size, typically a live fMP4 stream received from
+// the network :
+//
+//	p := &mp4.Parser{
+//		OnBox: func(b mp4.Box, offset int64) error { ... },
+//		OnSample: func(trackID uint32, s mp4.SampleInfo, data []byte) error { ... },
+//	}
+//	for ... {
+//		_, err := p.Write(data)
+//	}
+//	err := p.Close()
+//
+// Each top-level box (ftyp, moov, styp, sidx, moof, mdat, emsg, ...) is decoded as soon as it is complete,
+// and given to OnBox. The boxes without decoder are given as UkwnBox (see RegisterBox).
+//
+// The samples of the movie fragments are given to OnSample when the mdat following a moof is complete. They
+// are described by the tracks of the init segment (moov) : the last one of the stream, or the one set in
+// Moov before the first fragment. The samples are numbered from 1 in each fragment, and their offsets are
+// from the beginning of the stream. The tracks without sample tables are skipped.
+//
+// The boxes are held in memory until they are complete, mdat included : a box larger than the MaxBoxSize of
+// the limits stops the parsing with ErrLimitExceeded.
+type Parser struct {
+	OnBox    func(b Box, offset int64) error
+	OnSample func(trackID uint32, s SampleInfo, data []byte) error
+	// The init segment
+	Moov *MoovBox
+	// The limits set with SetLimits if nil
+	Limits *Limits
+
+	buf         []byte
+	offset      int64    // of buf in the stream
+	moof        *MoofBox // waiting for its mdat
+	decodeTimes map[uint32]uint64
+	err         error
+}
+
+// Write writes a part of the stream to the parser, and decodes the boxes that are complete. The errors
+// returned by the decoders or the callbacks stop the parsing, and are returned by the following calls.
+func (p *Parser) Write(data []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	p.buf = append(p.buf, data...)
+	consumed := 0
+	for {
+		b := p.buf[consumed:]
+		size, headerSize, err := boxSizeOf(b, p.limits().MaxBoxSize)
+		if err != nil {
+			p.err = &DecodeError{Path: string(b[4:8]), Offset: p.offset, Err: err}
+			return 0, p.err
+		}
+		if size < 0 || int64(len(b)) < size {
+			break
+		}
+		p.err = p.parseBox(b[:size], headerSize)
+		if p.err != nil {
+			return 0, p.err
+		}
+		consumed += int(size)
+		p.offset += size
+	}
+	if consumed > 0 {
+		// the decoded boxes may reference the data of the buffer (mdat)
+		p.buf = append([]byte(nil), p.buf[consumed:]...)
+	}
+	return len(data), nil
+}
+
+// Close decodes the last box, if it extends to the end of the stream (size 0), and returns
+// ErrTruncatedBody if the stream ends with an incomplete box
+func (p *Parser) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+	if len(p.buf) == 0 {
+		return nil
+	}
+	if len(p.buf) < BoxHeaderSize || binary.BigEndian.Uint32(p.buf) != 0 {
+		return ErrTruncatedBody
+	}
+	p.err = p.parseBox(p.buf, BoxHeaderSize)
+	if p.err != nil {
+		return p.err
+	}
+	p.offset += int64(len(p.buf))
+	p.buf = nil
+	return nil
+}
+
+// limits returns the limits of the parsing
+func (p *Parser) limits() *Limits {
+	if p.Limits != nil {
+		return p.Limits
+	}
+	return limits.Load()
+}
+
+// boxSizeOf returns the size of the box starting at b, and the size of its header. The size is -1 when the
+// header is incomplete, or the box extends to the end of the stream. ErrLimitExceeded is returned when the
+// body of the box is larger than max (if not 0), as soon as its header is complete.
+func boxSizeOf(b []byte, max int64) (int64, int, error) {
+	if len(b) < BoxHeaderSize {
+		return -1, 0, nil
+	}
+	size := int64(binary.BigEndian.Uint32(b))
+	headerSize := BoxHeaderSize
+	switch size {
+	case 0:
+		if max > 0 && int64(len(b)-BoxHeaderSize) > max {
+			return 0, 0, ErrLimitExceeded
+		}
+		return -1, 0, nil
+	case 1:
+		if len(b) < 2*BoxHeaderSize {
+			return -1, 0, nil
+		}
+		size = int64(binary.BigEndian.Uint64(b[BoxHeaderSize:]))
+		headerSize += BoxHeaderSize
+	}
+	if size < int64(headerSize) {
+		return 0, 0, ErrBadFormat
+	}
+	if max > 0 && size-int64(headerSize) > max {
+		return 0, 0, ErrLimitExceeded
+	}
+	return size, headerSize, nil
+}
+
+// parseBox decodes a complete top-level box
+func (p *Parser) parseBox(data []byte, headerSize int) error {
+	h := BoxHeader{
+		Type: string(data[4:8]),
+		Size: binary.BigEndian.Uint32(data),
+	}
+	if h.Size == 1 {
+		h.LargeSize = binary.BigEndian.Uint64(data[BoxHeaderSize:])
+	} else if h.Size == 0 {
+		// the actual size of the box
+		if int64(len(data)) > int64(^uint32(0)) {
+			h.Size, h.LargeSize = 1, uint64(len(data))+BoxHeaderSize
+		} else {
+			h.Size = uint32(len(data))
+		}
+	}
+	body := data[headerSize:]
+	r := newBoxReader(bytes.NewReader(body), "", "", p.offset+int64(headerSize), int64(len(body)))
+	r.limits = p.limits()
+	b, err := DecodeBox(h, r)
+	if err != nil {
+		return err
+	}
+	switch b := b.(type) {
+	case *MoovBox:
+		p.Moov = b
+		p.decodeTimes = nil
+	case *MoofBox:
+		b.offset = p.offset
+		p.moof = b
+	case *MdatBox:
+		if p.moof != nil {
+			err = p.parseSamples(data[headerSize:], p.offset+int64(headerSize))
+			p.moof = nil
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if p.OnBox != nil {
+		return p.OnBox(b, p.offset)
+	}
+	return nil
+}
+
+// parseSamples gives the samples of the last moof to OnSample, data being the content of the mdat that
+// follows it, at offset in the stream
+func (p *Parser) parseSamples(data []byte, offset int64) error {
+	if p.OnSample == nil || p.Moov == nil {
+		return nil
+	}
+	if p.decodeTimes == nil {
+		p.decodeTimes = map[uint32]uint64{}
+	}
+	for _, trak := range p.Moov.Trak {
+		t, err := NewTrack(trak)
+		if err != nil {
+			continue
+		}
+		id := t.ID()
+		if dts, ok := p.decodeTimes[id]; ok {
+			// without tfdt, the fragment follows the previous one
+			t.fragmentDecodeTime = dts
+		}
+		// the samples of the sample tables of the init segment are not given
+		n := t.SampleCount()
+		err = t.AddFragments(p.Moov.Mvex, p.moof)
+		if err != nil {
+			return err
+		}
+		if t.fragmentSamples == 0 {
+			continue
+		}
+		p.decodeTimes[id] = t.fragmentDecodeTime
+		it := t.Samples()
+		for it.Next() {
+			s := it.Sample()
+			if s.Number <= n {
+				continue
+			}
+			s.Number -= n
+			start := int64(s.Offset) - offset
+			if start < 0 || start+int64(s.Size) > int64(len(data)) {
+				return ErrTruncatedMedia
+			}
+			err = p.OnSample(id, s, data[start:start+int64(s.Size)])
+			if err != nil {
+				return err
+			}
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package mp4
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestParserMaxBoxSize(t *testing.T) {
+	free := boxBytes("free", make([]byte, 40))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"declared size", boxBytes("mdat", make([]byte, 100))},
+		{"large size", append([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0, 0, 0, 0, 0, 0, 116}, make([]byte, 100)...)},
+		{"to the end of the stream", append([]byte{0, 0, 0, 0, 'm', 'd', 'a', 't'}, make([]byte, 100)...)},
+	}
+	for _, tt := range tests {
+		var boxes []string
+		p := &Parser{
+			OnBox: func(b Box, offset int64) error {
+				boxes = append(boxes, b.Type())
+				return nil
+			},
+			Limits: &Limits{MaxBoxSize: 50},
+		}
+		// a declared size is rejected as soon as the header is received, a size to the end of the stream
+		// when the body received exceeds the limit
+		_, err := p.Write(append(bytes.Clone(free), tt.data[:16]...))
+		if err == nil && tt.data[3] == 0 {
+			_, err = p.Write(tt.data[16:])
+		}
+		var e *DecodeError
+		if !errors.As(err, &e) || e.Err != ErrLimitExceeded || e.Offset != int64(len(free)) {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(boxes) != 1 || boxes[0] != "free" {
+			t.Errorf("%s: boxes %v", tt.name, boxes)
+		}
+		if p.Close() != err {
+			t.Errorf("%s: the error is not returned by Close", tt.name)
+		}
+	}
+
+	// within the limit
+	p := &Parser{Limits: &Limits{MaxBoxSize: 100}}
+	data := append(bytes.Clone(free), tests[0].data...)
+	for i := range data {
+		if _, err := p.Write(data[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParserSamples(t *testing.T) {
+	var data []byte
+	for _, file := range []string{"sample/hls-init.mp4", "sample/hls_sequence_00000.m4s"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type sample struct {
+		info SampleInfo
+		data []byte
+	}
+	got := map[uint32][]sample{}
+	p := &Parser{
+		OnSample: func(trackID uint32, s SampleInfo, data []byte) error {
+			got[trackID] = append(got[trackID], sample{s, bytes.Clone(data)})
+			return nil
+		},
+	}
+	for i := 0; i < len(data); i += 1000 {
+		if _, err := p.Write(data[i:min(i+1000, len(data))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tr := range r.Tracks {
+		samples := got[tr.ID()]
+		if len(samples) != int(tr.SampleCount()) {
+			t.Errorf("track %d: %d samples, want %d", tr.ID(), len(samples), tr.SampleCount())
+			continue
+		}
+		for _, s := range samples {
+			want, err := tr.Sample(s.info.Number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.info != want {
+				t.Errorf("track %d: got the sample %+v, want %+v", tr.ID(), s.info, want)
+				break
+			}
+			wantData, err := r.ReadSample(tr.ID(), s.info.Number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(s.data, wantData) {
+				t.Errorf("track %d: the data of the sample %d differs", tr.ID(), s.info.Number)
+				break
+			}
+		}
+	}
+}
@@ -37,32 +37,31 @@ func (b *PdinBox) Encode(w io.Writer) error {
 	buf[0] = b.Version
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
 
-	for _, p := range b.InfoBoxes {
-		binary.BigEndian.PutUint32(buf[4:], p.Rate)
-		binary.BigEndian.PutUint32(buf[8:], p.InitialDelay)
+	for i, p := range b.InfoBoxes {
+		binary.BigEndian.PutUint32(buf[4+i*8:], p.Rate)
+		binary.BigEndian.PutUint32(buf[8+i*8:], p.InitialDelay)
 	}
+	_, err = w.Write(buf)
 	return err
 }
 
 func (b *PdinBox) Size() int {
-	l := BoxHeaderSize
-	for _, _ = range b.InfoBoxes {
-		l = l + 8 + 8
-	}
-	return l
+	return BoxHeaderSize + 4 + len(b.InfoBoxes)*8
 }
 
 func DecodePdin(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 4 {
+		return nil, ErrBadFormat
+	}
 	b := &PdinBox{
 		Version: data[0],
 		Flags:   []byte{data[1], data[2], data[3]},
 	}
-	for i := 4; 4 < h.Size-BoxHeaderSize; i += 4 {
+	for i := 4; i+8 <= len(data); i += 8 {
 		ib := &InfoBox{
 			Rate:         binary.BigEndian.Uint32(data[i : i+4]),
 			InitialDelay: binary.BigEndian.Uint32(data[i+4 : i+8]),
@@ -0,0 +1,51 @@
+package mp4
+
+import (
+	"fmt"
+	"io"
+)
+
+// Raw Box
+//
+// Status: not decoded
+//
+// In lenient mode (see DecodeOptions), the boxes that cannot be decoded are kept as is, with the error
+// that occurred. They are stored with the unknown boxes of their container, and encoded without change
+// (but the size of the truncated boxes).
+type RawBox struct {
+	Header BoxHeader
+	Data   []byte // the body, user type included (uuid boxes)
+	Err    *DecodeError
+}
+
+func (b *RawBox) Box() Box {
+	return b
+}
+
+func (b *RawBox) Type() string {
+	return b.Header.Type
+}
+
+func (b *RawBox) Size() int {
+	return sizeWithHeader(b.Header.Size == 1, uint64(len(b.Data)))
+}
+
+func (b *RawBox) largeHeader() bool {
+	return b.Header.Size == 1
+}
+
+func (b *RawBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.Data)
+	return err
+}
+
+func (b *RawBox) Dump() {
+	fmt.Printf("Raw Box\n")
+	fmt.Printf("+- Type: %s\n", b.Type())
+	fmt.Printf("+- Data length: %d\n", len(b.Data))
+	fmt.Printf("+- Error: %v\n", b.Err)
+}
@@ -0,0 +1,130 @@
+package mp4
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrNoMovie        = errors.New("no movie box")
+	ErrTrackNotFound  = errors.New("track not found")
+	ErrTruncatedMedia = errors.New("truncated media data")
+)
+
+// Reader gives random access to the samples of a media stored in an io.ReaderAt (os.File, bytes.Reader, ...).
+//
+// Only the boxes are decoded when the media is opened, the media data (mdat) is skipped and only read when
+// samples are requested.
+type Reader struct {
+	MP4    *MP4
+	Tracks []*Track
+	r      io.ReaderAt
+	size   int64
+}
+
+// NewReader decodes the boxes of a media of the given size, and returns a Reader for its samples. The tracks
+// without sample tables are skipped.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	m, err := Decode(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	if m.Moov == nil {
+		return nil, ErrNoMovie
+	}
+	mr := &Reader{
+		MP4:  m,
+		r:    r,
+		size: size,
+	}
+	for _, trak := range m.Moov.Trak {
+		t, err := NewTrack(trak)
+		if err != nil {
+			continue
+		}
+		if m.Moov.Mvhd != nil {
+			t.MovieTimescale = m.Moov.Mvhd.Timescale
+		}
+		if len(m.Moof) > 0 {
+			err = t.AddFragments(m.Moov.Mvex, m.Moof...)
+			if err != nil {
+				return nil, err
+			}
+		}
+		mr.Tracks = append(mr.Tracks, t)
+	}
+	return mr, nil
+}
+
+// Track returns the track with the given ID
+func (r *Reader) Track(id uint32) (*Track, error) {
+	for _, t := range r.Tracks {
+		if t.ID() == id {
+			return t, nil
+		}
+	}
+	return nil, ErrTrackNotFound
+}
+
+// ReadSample returns the data of the sample n (starting at 1) of a track
+func (r *Reader) ReadSample(trackID, n uint32) ([]byte, error) {
+	t, err := r.Track(trackID)
+	if err != nil {
+		return nil, err
+	}
+	s, err := t.Sample(n)
+	if err != nil {
+		return nil, err
+	}
+	if s.Offset > uint64(r.size) || s.Offset+uint64(s.Size) > uint64(r.size) {
+		return nil, ErrTruncatedMedia
+	}
+	data := make([]byte, s.Size)
+	_, err = r.r.ReadAt(data, int64(s.Offset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// SampleReader returns a reader over the data of the samples first to last (included) of a track, in
+// decoding order. Consecutive samples stored contiguously are read at once.
+func (r *Reader) SampleReader(trackID, first, last uint32) (io.Reader, error) {
+	t, err := r.Track(trackID)
+	if err != nil {
+		return nil, err
+	}
+	if first == 0 || first > last || last > t.SampleCount() {
+		return nil, ErrInvalidSampleNumber
+	}
+	readers := []io.Reader{}
+	var off, sz int64
+	it := t.Samples()
+	for it.Next() {
+		s := it.Sample()
+		if s.Number < first {
+			continue
+		}
+		if s.Number > last {
+			break
+		}
+		if s.Offset > uint64(r.size) || s.Offset+uint64(s.Size) > uint64(r.size) {
+			return nil, ErrTruncatedMedia
+		}
+		if sz > 0 && off+sz == int64(s.Offset) {
+			sz += int64(s.Size)
+			continue
+		}
+		if sz > 0 {
+			readers = append(readers, io.NewSectionReader(r.r, off, sz))
+		}
+		off, sz = int64(s.Offset), int64(s.Size)
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	if sz > 0 {
+		readers = append(readers, io.NewSectionReader(r.r, off, sz))
+	}
+	return io.MultiReader(readers...), nil
+}
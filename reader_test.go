@@ -0,0 +1,81 @@
+package mp4
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// chunkSamples returns the byte ranges of the samples of a track, from its stsc, stco and stsz boxes
+func chunkSamples(stbl *StblBox) [][2]uint64 {
+	l := [][2]uint64{}
+	stsc := stbl.Stsc
+	n := uint32(1)
+	for chunk, offset := range stbl.Stco.ChunkOffset {
+		i := len(stsc.FirstChunk) - 1
+		for i > 0 && stsc.FirstChunk[i] > uint32(chunk+1) {
+			i--
+		}
+		off := uint64(offset)
+		for j := uint32(0); j < stsc.SamplesPerChunk[i]; j++ {
+			size := uint64(stbl.Stsz.SampleUniformSize)
+			if size == 0 {
+				size = uint64(stbl.Stsz.SampleSize[n-1])
+			}
+			l = append(l, [2]uint64{off, off + size})
+			off += size
+			n++
+		}
+	}
+	return l
+}
+
+func TestReader(t *testing.T) {
+	data, err := os.ReadFile("sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Tracks) != 2 {
+		t.Fatalf("%d tracks, want 2", len(r.Tracks))
+	}
+	for _, tr := range r.Tracks {
+		ranges := chunkSamples(tr.Trak.Mdia.Minf.Stbl)
+		if int(tr.SampleCount()) != len(ranges) {
+			t.Errorf("track %d: %d samples, want %d", tr.ID(), tr.SampleCount(), len(ranges))
+		}
+		for i, rg := range ranges {
+			got, err := r.ReadSample(tr.ID(), uint32(i+1))
+			if err != nil {
+				t.Fatalf("track %d, sample %d: %v", tr.ID(), i+1, err)
+			}
+			if !bytes.Equal(got, data[rg[0]:rg[1]]) {
+				t.Errorf("track %d, sample %d: data differs from %d-%d", tr.ID(), i+1, rg[0], rg[1])
+			}
+		}
+	}
+
+	// a track without sample tables
+	m, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Moov.Trak[0].Mdia.Minf = nil
+	buf := &bytes.Buffer{}
+	if err := m.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Tracks) != 1 || r.Tracks[0].ID() != 2 {
+		t.Errorf("%d tracks, want the track 2 only", len(r.Tracks))
+	}
+	if _, err := r.ReadSample(1, 1); err != ErrTrackNotFound {
+		t.Errorf("track 1: %v", err)
+	}
+}
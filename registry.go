@@ -0,0 +1,86 @@
+package mp4
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Decoders registered by the applications
+var registry = struct {
+	sync.RWMutex
+	decoders map[string]BoxDecoder            // by box type
+	scoped   map[string]map[string]BoxDecoder // by parent box type, then box type
+	uuid     map[[16]byte]BoxDecoder          // by user type
+}{
+	decoders: map[string]BoxDecoder{},
+	scoped:   map[string]map[string]BoxDecoder{},
+	uuid:     map[[16]byte]BoxDecoder{},
+}
+
+// RegisterBox registers the decoder of a box type that is not decoded by the library (vendor or
+// application specific boxes). If parent box types are given, the decoder is only used for the boxes
+// contained in those boxes ("" for the top-level boxes).
+//
+// The decoded boxes are stored in the Boxes attribute of their container (MP4.Other at the top-level),
+// and are dumped, marshaled to JSON and encoded as any other box.
+//
+// RegisterBox panics if the box type is decoded by the library, or is "uuid" (see RegisterUUIDBox).
+func RegisterBox(boxType string, d BoxDecoder, parents ...string) {
+	if len(boxType) != 4 || boxType == "uuid" || decoders[boxType] != nil {
+		panic(fmt.Sprintf("mp4: can not register box type %q", boxType))
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if len(parents) == 0 {
+		registry.decoders[boxType] = d
+		return
+	}
+	for _, p := range parents {
+		if registry.scoped[p] == nil {
+			registry.scoped[p] = map[string]BoxDecoder{}
+		}
+		registry.scoped[p][boxType] = d
+	}
+}
+
+// RegisterUUIDBox registers the decoder of an extended type box (uuid), identified by its user type.
+//
+// The decoder is given the header of the box (with its UserType), and a reader over the data that follows
+// the user type. The box must encode its header followed by the user type (see EncodeUUIDHeader), and
+// count them in its size.
+func RegisterUUIDBox(userType [16]byte, d BoxDecoder) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.uuid[userType] = d
+}
+
+// EncodeUUIDHeader encodes the header of an extended type box (uuid) : size, type and user type
+func EncodeUUIDHeader(b Box, userType [16]byte, w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(userType[:])
+	return err
+}
+
+// decoder returns the decoder of a box contained in a box of type parent, nil if unknown
+func decoder(parent, boxType string) BoxDecoder {
+	if d := decoders[boxType]; d != nil {
+		return d
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	if d := registry.scoped[parent][boxType]; d != nil {
+		return d
+	}
+	return registry.decoders[boxType]
+}
+
+// uuidDecoder returns the decoder of an extended type box, nil if unknown
+func uuidDecoder(userType [16]byte) BoxDecoder {
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.uuid[userType]
+}
@@ -0,0 +1,131 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+// testBox is an application box, registered by the tests
+type testBox struct {
+	typ      string
+	userType *[16]byte
+	data     []byte
+}
+
+func decodeTestBox(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b := &testBox{typ: h.Type, data: data}
+	if h.Type == "uuid" {
+		b.userType = &h.UserType
+	}
+	return b, nil
+}
+
+func (b *testBox) Box() Box     { return b }
+func (b *testBox) Type() string { return b.typ }
+func (b *testBox) Dump()        {}
+
+func (b *testBox) Size() int {
+	if b.userType != nil {
+		return BoxHeaderSize + 16 + len(b.data)
+	}
+	return BoxHeaderSize + len(b.data)
+}
+
+func (b *testBox) Encode(w io.Writer) error {
+	var err error
+	if b.userType != nil {
+		err = EncodeUUIDHeader(b, *b.userType, w)
+	} else {
+		err = EncodeHeader(b, w)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.data)
+	return err
+}
+
+func TestRegisterBox(t *testing.T) {
+	userType := [16]byte{0x12, 0x34, 0x56, 0x78, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	RegisterBox("tst1", decodeTestBox)
+	RegisterBox("tst2", decodeTestBox, "moov")
+	RegisterUUIDBox(userType, decodeTestBox)
+
+	data, err := os.ReadFile("sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	box := func(typ string, body ...byte) []byte {
+		b := make([]byte, 4, BoxHeaderSize+len(body))
+		binary.BigEndian.PutUint32(b, uint32(BoxHeaderSize+len(body)))
+		return append(append(b, typ...), body...)
+	}
+	// tst1 and tst2 boxes at the end of the moov box, tst1, tst2 and uuid boxes at the top-level
+	var moov int
+	for string(data[moov+4:moov+8]) != "moov" {
+		moov += int(binary.BigEndian.Uint32(data[moov:]))
+	}
+	moovEnd := moov + int(binary.BigEndian.Uint32(data[moov:]))
+	inMoov := append(box("tst1", 1, 2), box("tst2", 3, 4)...)
+	src := append([]byte{}, data[:moovEnd]...)
+	src = append(src, inMoov...)
+	src = append(src, data[moovEnd:]...)
+	binary.BigEndian.PutUint32(src[moov:], uint32(moovEnd-moov+len(inMoov)))
+	src = append(src, box("tst1", 5)...)
+	src = append(src, box("tst2", 6)...)
+	src = append(src, box("uuid", append(userType[:], 7, 8, 9)...)...)
+	src = append(src, box("uuid", append(make([]byte, 16), 10)...)...)
+
+	m, err := Decode(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(where string, l []Box, types ...string) {
+		t.Helper()
+		got := []string{}
+		for _, b := range l {
+			switch b := b.(type) {
+			case *testBox:
+				got = append(got, b.Type())
+			case *UkwnBox:
+				if b.Type() == "tst2" || b.Type() == "uuid" {
+					got = append(got, "unknown "+b.Type())
+				}
+			}
+		}
+		if len(got) != len(types) {
+			t.Errorf("%s: got %q, want %q", where, got, types)
+			return
+		}
+		for i := range got {
+			if got[i] != types[i] {
+				t.Errorf("%s: got %q, want %q", where, got, types)
+				return
+			}
+		}
+	}
+	check("moov", m.Moov.Boxes, "tst1", "tst2")
+	check("top-level", m.Other, "tst1", "unknown tst2", "uuid", "unknown uuid")
+
+	buf := &bytes.Buffer{}
+	if err := m.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), src) {
+		t.Error("the encoded media differs from the source")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a box decoded by the library did not panic")
+		}
+	}()
+	RegisterBox("moov", decodeTestBox)
+}
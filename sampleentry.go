@@ -0,0 +1,68 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Sample entries (stsd entries) all start with 6 reserved bytes followed by the data reference index.
+const sampleEntryHeaderSize = 8
+
+func decodeSampleEntryHeader(data []byte) uint16 {
+	return binary.BigEndian.Uint16(data[6:8])
+}
+
+func encodeSampleEntryHeader(buf []byte, dataReferenceIndex uint16) {
+	binary.BigEndian.PutUint16(buf[6:], dataReferenceIndex)
+}
+
+// decodeSampleEntryBoxes decodes the boxes that follow the format specific fields of a sample entry.
+//
+// Some encoders end the list with a few zero bytes (usually 4), they are returned as is so that the
+// entry can be encoded without loss.
+func decodeSampleEntryBoxes(r io.Reader, data []byte) ([]Box, []byte, error) {
+	off := 0
+	for off+BoxHeaderSize <= len(data) {
+		sz := int(binary.BigEndian.Uint32(data[off : off+4]))
+		if sz < BoxHeaderSize || off+sz > len(data) {
+			break
+		}
+		off += sz
+	}
+	c := childReader(r, data)
+	c.N, c.size = int64(off), int64(off)
+	l, err := DecodeContainer(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, data[off:], nil
+}
+
+func sampleEntryBoxesSize(boxes []Box, trailing []byte) int {
+	sz := len(trailing)
+	for _, b := range boxes {
+		sz += b.Size()
+	}
+	return sz
+}
+
+func encodeSampleEntryBoxes(w io.Writer, boxes []Box, trailing []byte) error {
+	for _, b := range boxes {
+		err := b.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(trailing)
+	return err
+}
+
+// cstring reads a null terminated string, and returns it with the number of bytes read (including the terminator)
+func cstring(data []byte) (string, int) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return string(data), len(data)
+	}
+	return string(data[:i]), i + 1
+}
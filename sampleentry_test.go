@@ -0,0 +1,82 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestVisualSampleEntryLossless(t *testing.T) {
+	fields := make([]byte, 78)
+	binary.BigEndian.PutUint16(fields[6:], 1)
+	fields[0], fields[10] = 0x12, 0x34 // reserved
+	binary.BigEndian.PutUint16(fields[24:], 640)
+	binary.BigEndian.PutUint16(fields[26:], 360)
+	binary.BigEndian.PutUint16(fields[40:], 1)
+	// compressor name without a valid length
+	copy(fields[42:74], "A compressor name of 32 bytes..!")
+	binary.BigEndian.PutUint16(fields[74:], 0x18)
+	binary.BigEndian.PutUint16(fields[76:], 0x1234) // pre-defined
+	src := append([]byte{0, 0, 0, 86, 'a', 'v', 'c', '1'}, fields...)
+
+	b := decodeBox(t, src)
+	buf := &bytes.Buffer{}
+	if err := b.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), src) {
+		t.Errorf("encoded %x, want %x", buf.Bytes(), src)
+	}
+
+	// a new name is encoded with its length
+	e := b.(*VisualSampleEntry)
+	e.CompressorName = "x264"
+	buf.Reset()
+	if err := e.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{4}, "x264"...)
+	want = append(want, make([]byte, 27)...)
+	if got := buf.Bytes()[8+42 : 8+74]; !bytes.Equal(got, want) {
+		t.Errorf("compressor name %x, want %x", got, want)
+	}
+}
+
+func TestTextSampleEntryLossless(t *testing.T) {
+	header := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	for _, tt := range []struct {
+		format string
+		body   string
+	}{
+		{"stpp", "http://www.w3.org/ns/ttml\x00\x00\x00"},
+		// the last string, or all of them, without terminator
+		{"stpp", "http://www.w3.org/ns/ttml\x00\x00image/png"},
+		{"stpp", "http://www.w3.org/ns/ttml"},
+		{"stpp", ""},
+		{"stxt", "utf-8\x00text/plain\x00"},
+		{"stxt", "\x00text/plain"},
+	} {
+		body := append(append([]byte{}, header...), tt.body...)
+		src := append([]byte{0, 0, 0, byte(8 + len(body))}, tt.format...)
+		src = append(src, body...)
+		b := decodeBox(t, src)
+		buf := &bytes.Buffer{}
+		if err := b.Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), src) || b.Size() != len(src) {
+			t.Errorf("%s %q: encoded %q, want %q", tt.format, tt.body, buf.Bytes(), src)
+		}
+	}
+
+	// a string following an unterminated one
+	b := decodeBox(t, append([]byte{0, 0, 0, 21, 's', 't', 'x', 't'}, append(header, "utf-8"...)...)).(*TextSampleEntry)
+	b.MimeFormat = "text/plain"
+	buf := &bytes.Buffer{}
+	if err := b.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "utf-8\x00text/plain\x00"; string(buf.Bytes()[16:]) != want {
+		t.Errorf("strings %q, want %q", buf.Bytes()[16:], want)
+	}
+}
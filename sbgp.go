@@ -17,30 +17,44 @@ type Entry struct {
 }
 
 type SbgpBox struct {
-	Version      byte
-	Flags        [3]byte
-	GroupingType uint32
-	EntryCount   uint32
-	Entries      []*Entry
+	Version               byte
+	Flags                 [3]byte
+	GroupingType          uint32
+	GroupingTypeParameter uint32 `json:",omitempty"` // Version == 1
+	EntryCount            uint32
+	Entries               []*Entry
 }
 
 func DecodeSbgp(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 12 {
+		return nil, ErrTruncatedBody
+	}
 	b := &SbgpBox{
 		Version:      data[0],
 		Flags:        [3]byte{data[1], data[2], data[3]},
 		GroupingType: binary.BigEndian.Uint32(data[4:8]),
-		EntryCount:   binary.BigEndian.Uint32(data[8:12]),
 	}
-	offset := 12
+	offset := 8
+	if b.Version == 1 {
+		if len(data) < 16 {
+			return nil, ErrTruncatedBody
+		}
+		b.GroupingTypeParameter = binary.BigEndian.Uint32(data[8:12])
+		offset += 4
+	}
+	b.EntryCount = binary.BigEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	if err = checkEntries(r, uint64(b.EntryCount), 8, offset, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(b.EntryCount); i++ {
 		e := &Entry{
-			SampleCount:           binary.BigEndian.Uint32(data[offset+i*4 : offset+4+i*4]),
-			GroupDescriptionIndex: binary.BigEndian.Uint32(data[offset+4+i*4 : offset+8+i*4]),
+			SampleCount:           binary.BigEndian.Uint32(data[offset : offset+4]),
+			GroupDescriptionIndex: binary.BigEndian.Uint32(data[offset+4 : offset+8]),
 		}
 		offset += 8
 		b.Entries = append(b.Entries, e)
@@ -57,7 +71,11 @@ func (b *SbgpBox) Type() string {
 }
 
 func (b *SbgpBox) Size() int {
-	return int(BoxHeaderSize + 4 + 8 + (b.EntryCount * 8))
+	sz := BoxHeaderSize + 12 + len(b.Entries)*8
+	if b.Version == 1 {
+		sz += 4
+	}
+	return sz
 }
 
 func (b *SbgpBox) Encode(w io.Writer) error {
@@ -69,8 +87,13 @@ func (b *SbgpBox) Encode(w io.Writer) error {
 	buf[0] = b.Version
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
 	binary.BigEndian.PutUint32(buf[4:8], b.GroupingType)
-	binary.BigEndian.PutUint32(buf[8:12], b.EntryCount)
-	offset := 12
+	offset := 8
+	if b.Version == 1 {
+		binary.BigEndian.PutUint32(buf[offset:], b.GroupingTypeParameter)
+		offset += 4
+	}
+	binary.BigEndian.PutUint32(buf[offset:], b.EntryCount)
+	offset += 4
 	for _, e := range b.Entries {
 		binary.BigEndian.PutUint32(buf[offset:], e.SampleCount)
 		offset += 4
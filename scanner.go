@@ -0,0 +1,336 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	ErrBoxAlreadyRead = errors.New("box payload already read")
+	ErrUnknownBoxSize = errors.New("unknown box size")
+)
+
+// BoxInfo describes a box found by a Scanner
+type BoxInfo struct {
+	Header     BoxHeader
+	Path       string // e.g. moov/trak[1]/mdia, the index being given if the box is not the first of its type
+	Offset     int64  // of the box, from the position of the reader when the scan started
+	Size       int64  // of the box, header included, -1 if the box extends to the end of a non-seekable stream
+	HeaderSize int    // size, largesize and user type (uuid boxes) included
+	Depth      int    // 0 for the top-level boxes
+}
+
+// Type returns the type of the box
+func (i BoxInfo) Type() string {
+	return i.Header.Type
+}
+
+// Scanner reads the boxes of a media one by one, without decoding or buffering them, so that media of any
+// size can be traversed with a constant memory.
+//
+// Next moves to the next box, the part of the current box that was not read being skipped (with Seek if
+// the reader is an io.Seeker). The payload of the current box can be read with Read, decoded with Decode,
+// or its children can be scanned after a call to Descend :
+//
+//	s := mp4.NewScanner(f)
+//	for s.Next() {
+//		b := s.Box()
+//		if b.Type() == "moov" || b.Type() == "trak" {
+//			s.Descend()
+//		}
+//	}
+//	err := s.Err()
+type Scanner struct {
+	r      io.Reader
+	start  int64 // position of r when the scan started (seekable readers)
+	pos    int64 // from start
+	box    BoxInfo
+	hasBox bool
+	end    int64    // end of the current box, -1 if unknown
+	parent []int64  // ends of the boxes descended into
+	types  []string // of the boxes descended into
+	paths  []string
+	counts []map[string]int // of the boxes read at each depth, by type
+	index  int              // of the current box amongst the boxes of its type
+	err    error
+}
+
+// NewScanner returns a scanner reading the boxes from r
+func NewScanner(r io.Reader) *Scanner {
+	s := &Scanner{r: r}
+	if rs, ok := r.(io.Seeker); ok {
+		pos, err := rs.Seek(0, io.SeekCurrent)
+		if err == nil {
+			s.start = pos
+		}
+	}
+	return s
+}
+
+// Next moves to the next box, returns false at the end of the media or when an error occurs (see Err).
+//
+// When the last box of the boxes descended into is reached, the scan continues with the next box of the
+// parent box (see Box().Depth).
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.hasBox {
+		s.hasBox = false
+		if s.err = s.skipTo(s.end); s.err != nil {
+			return false
+		}
+	}
+	for len(s.parent) > 0 {
+		end := s.parent[len(s.parent)-1]
+		if end < 0 || end-s.pos >= BoxHeaderSize {
+			break
+		}
+		// end of the parent box, the padding being skipped
+		if s.err = s.skipTo(end); s.err != nil {
+			return false
+		}
+		s.parent = s.parent[:len(s.parent)-1]
+		s.types = s.types[:len(s.types)-1]
+		s.paths = s.paths[:len(s.paths)-1]
+		s.counts = s.counts[:len(s.counts)-1]
+	}
+	b, err := s.readHeader()
+	if err == io.EOF && (len(s.parent) == 0 || s.parent[len(s.parent)-1] < 0) {
+		return false
+	}
+	if err != nil {
+		s.err = &DecodeError{Path: b.Path, Offset: b.Offset, Err: err}
+		return false
+	}
+	s.box, s.hasBox = b, true
+	s.end = -1
+	if b.Size >= 0 {
+		s.end = b.Offset + b.Size
+	}
+	return true
+}
+
+// readHeader reads the header of the box at the current position
+func (s *Scanner) readHeader() (BoxInfo, error) {
+	b := BoxInfo{
+		Offset:     s.pos,
+		HeaderSize: BoxHeaderSize,
+		Depth:      len(s.parent),
+	}
+	buf := make([]byte, BoxHeaderSize)
+	n, err := io.ReadFull(s.r, buf)
+	s.pos += int64(n)
+	if err == io.EOF {
+		return b, err
+	}
+	if err != nil {
+		return b, ErrTruncatedHeader
+	}
+	b.Header.Type = string(buf[4:8])
+	if len(s.counts) == 0 {
+		s.counts = append(s.counts, map[string]int{})
+	}
+	counts := s.counts[len(s.counts)-1]
+	s.index = counts[b.Header.Type]
+	counts[b.Header.Type]++
+	b.Path = boxPath(s.parentPath(), b.Header.Type, s.index)
+	b.Header.Size = binary.BigEndian.Uint32(buf[0:4])
+	b.Size = int64(b.Header.Size)
+	if b.Header.Size == 1 {
+		n, err = io.ReadFull(s.r, buf)
+		s.pos += int64(n)
+		if err != nil {
+			return b, ErrTruncatedHeader
+		}
+		b.Header.LargeSize = binary.BigEndian.Uint64(buf)
+		b.Size = int64(b.Header.LargeSize)
+		b.HeaderSize += BoxHeaderSize
+	}
+	var parentEnd int64 = -1
+	if len(s.parent) > 0 {
+		parentEnd = s.parent[len(s.parent)-1]
+	}
+	if b.Header.Size == 0 {
+		// the box extends to the end of its parent, or of the media
+		b.Size, err = s.sizeToEnd(parentEnd, b.Offset)
+		if err != nil {
+			return b, err
+		}
+	}
+	if b.Header.Type == "uuid" {
+		n, err = io.ReadFull(s.r, b.Header.UserType[:])
+		s.pos += int64(n)
+		if err != nil {
+			return b, ErrTruncatedHeader
+		}
+		b.HeaderSize += len(b.Header.UserType)
+	}
+	if b.Size >= 0 && b.Size < int64(b.HeaderSize) {
+		return b, ErrBadFormat
+	}
+	if parentEnd >= 0 && (b.Size < 0 || b.Offset+b.Size > parentEnd) {
+		return b, ErrBadFormat
+	}
+	return b, nil
+}
+
+// sizeToEnd returns the size of a box extending to the end of its parent, or of the media (-1 if the
+// reader is not seekable)
+func (s *Scanner) sizeToEnd(parentEnd, offset int64) (int64, error) {
+	if parentEnd >= 0 {
+		return parentEnd - offset, nil
+	}
+	rs, ok := s.r.(io.Seeker)
+	if !ok {
+		return -1, nil
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	_, err = rs.Seek(s.start+s.pos, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return end - s.start - offset, nil
+}
+
+// skipTo skips the data up to the offset end (to the end of the media if end is -1)
+func (s *Scanner) skipTo(end int64) error {
+	if end >= 0 && end <= s.pos {
+		return nil
+	}
+	if rs, ok := s.r.(io.Seeker); ok {
+		var err error
+		if end < 0 {
+			var pos int64
+			pos, err = rs.Seek(0, io.SeekEnd)
+			s.pos = pos - s.start
+		} else {
+			_, err = rs.Seek(end-s.pos, io.SeekCurrent)
+			s.pos = end
+		}
+		return err
+	}
+	if end < 0 {
+		n, err := io.Copy(ioutil.Discard, s.r)
+		s.pos += n
+		return err
+	}
+	n, err := io.CopyN(ioutil.Discard, s.r, end-s.pos)
+	s.pos += n
+	if err == io.EOF {
+		return ErrTruncatedBody
+	}
+	return err
+}
+
+// Box returns the current box
+func (s *Scanner) Box() BoxInfo {
+	return s.box
+}
+
+// Err returns the error that stopped the scan, nil at the end of the media
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Descend makes the next call to Next return the first child of the current box. The children start at the
+// current position : the beginning of the payload, or after the part of the payload that was already read
+// (e.g. the fields of the meta or stsd boxes).
+func (s *Scanner) Descend() {
+	if !s.hasBox {
+		return
+	}
+	s.hasBox = false
+	s.parent = append(s.parent, s.end)
+	s.types = append(s.types, s.box.Header.Type)
+	s.paths = append(s.paths, s.box.Path)
+	s.counts = append(s.counts, map[string]int{})
+}
+
+// Read reads the payload of the current box
+func (s *Scanner) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if !s.hasBox {
+		return 0, io.EOF
+	}
+	if s.end >= 0 {
+		if s.pos >= s.end {
+			return 0, io.EOF
+		}
+		if int64(len(p)) > s.end-s.pos {
+			p = p[:s.end-s.pos]
+		}
+	}
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	if err == io.EOF && s.end >= 0 && s.pos < s.end {
+		err = ErrTruncatedBody
+	}
+	return n, err
+}
+
+// Decode decodes the current box, with the decoders of the library or the ones registered by the
+// application. Its payload is read in memory (except for mdat boxes read from a seekable reader), and must
+// not have been read.
+func (s *Scanner) Decode() (Box, error) {
+	if !s.hasBox || s.pos != s.box.Offset+int64(s.box.HeaderSize) {
+		return nil, ErrBoxAlreadyRead
+	}
+	if s.box.Size < 0 {
+		return nil, ErrUnknownBoxSize
+	}
+	h := s.box.Header
+	if h.Size == 0 {
+		// the actual size of the box
+		if s.box.Size > int64(^uint32(0)) {
+			h.Size, h.LargeSize = 1, uint64(s.box.Size)+BoxHeaderSize
+		} else {
+			h.Size = uint32(s.box.Size)
+		}
+	}
+	if ra, ok := s.r.(io.ReaderAt); ok && h.Type == "mdat" {
+		if _, ok := s.r.(io.Seeker); ok {
+			// the data is referenced by the box, and skipped by Next
+			b := NewMdatBox(ra, s.start+s.pos, uint64(s.end-s.pos))
+			b.large = h.Size == 1
+			b.referenced, b.sourceOffset = true, s.pos
+			return b, nil
+		}
+	}
+	var r io.Reader = s
+	offset, n := s.pos, s.end-s.pos
+	if h.Type == "uuid" {
+		// read again by DecodeBox
+		r = io.MultiReader(bytes.NewReader(h.UserType[:]), s)
+		offset -= int64(len(h.UserType))
+		n += int64(len(h.UserType))
+	}
+	br := newBoxReader(r, s.parentType(), s.parentPath(), offset, n)
+	br.counts = map[string]int{h.Type: s.index}
+	br.depth = s.box.Depth
+	return DecodeBox(h, br)
+}
+
+// parentPath returns the path of the box descended into, "" at the top-level
+func (s *Scanner) parentPath() string {
+	if len(s.paths) == 0 {
+		return ""
+	}
+	return s.paths[len(s.paths)-1]
+}
+
+// parentType returns the type of the box descended into, "" at the top-level
+func (s *Scanner) parentType() string {
+	if len(s.types) == 0 {
+		return ""
+	}
+	return s.types[len(s.types)-1]
+}
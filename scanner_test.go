@@ -0,0 +1,112 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// decodedBox is a box logged by DecodeBox
+type decodedBox struct {
+	Path   string
+	Offset int64
+	Size   int64
+}
+
+// logBoxes returns the boxes decoded by decode
+func logBoxes(t *testing.T, decode func()) []decodedBox {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	SetLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+	decode()
+	l := []decodedBox{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r struct {
+			Msg    string
+			Path   string
+			Offset int64
+			Size   int64
+		}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Msg == "decoding box" {
+			l = append(l, decodedBox{r.Path, r.Offset, r.Size})
+		}
+	}
+	return l
+}
+
+func TestScanner(t *testing.T) {
+	data, err := os.ReadFile("sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logBoxes(t, func() {
+		if _, err := Decode(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// the containers are scanned, the other boxes decoded
+	got := []decodedBox{}
+	s := NewScanner(bytes.NewReader(data))
+	for s.Next() {
+		i := s.Box()
+		switch i.Type() {
+		case "moov", "trak", "edts", "mdia", "minf", "dinf", "stbl":
+			got = append(got, decodedBox{i.Path, i.Offset, i.Size})
+			s.Descend()
+			continue
+		}
+		var b Box
+		got = append(got, logBoxes(t, func() { b, err = s.Decode() })...)
+		if err != nil {
+			t.Fatalf("%s: %v", i.Path, err)
+		}
+		if int64(b.Size()) != i.Size {
+			t.Errorf("%s: size %d, want %d", i.Path, b.Size(), i.Size)
+		}
+		if d, ok := b.(*MdatBox); ok {
+			// not decoded by DecodeBox
+			got = append(got, decodedBox{i.Path, i.Offset, i.Size})
+			if offset, ok := d.SourceOffset(); !ok || offset != i.Offset+int64(i.HeaderSize) {
+				t.Errorf("mdat offset %d, want %d", offset, i.Offset+int64(i.HeaderSize))
+			}
+		}
+		buf := &bytes.Buffer{}
+		if err := b.Encode(buf); err != nil {
+			t.Fatalf("%s: %v", i.Path, err)
+		}
+		if !bytes.Equal(buf.Bytes(), data[i.Offset:i.Offset+i.Size]) {
+			t.Errorf("%s: the box is not encoded as read", i.Path)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("boxes %v, want %v", got, want)
+	}
+
+	// the depth of the boxes decoded by the scanner
+	SetLimits(Limits{MaxDepth: 5})
+	defer SetLimits(DefaultLimits)
+	s = NewScanner(bytes.NewReader(data))
+	for s.Next() {
+		switch s.Box().Type() {
+		case "moov", "trak", "mdia", "minf":
+			s.Descend()
+		case "stbl":
+			if _, err := s.Decode(); !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("stbl at depth 5: %v", err)
+			}
+		}
+	}
+}
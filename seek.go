@@ -0,0 +1,289 @@
+package mp4
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+var (
+	ErrTimeOutOfRange      = errors.New("time out of range")
+	ErrUnsupportedEditList = errors.New("unsupported edit list (media rate other than 1)")
+)
+
+// edit is an entry of the edit list, with its times in track time units
+type edit struct {
+	start     int64 // on the presentation timeline
+	duration  int64
+	mediaTime int64 // -1 for an empty edit
+}
+
+// edits returns the entries of the edit list, nil if the track has no edit list or an empty one. The dwells
+// (media rate 0) and the slow or fast motion edits are not supported, and ErrBadFormat is returned when the
+// presentation does not fit in an int64 in track time units.
+func (t *Track) edits() ([]edit, error) {
+	if t.Trak.Edts == nil || t.Trak.Edts.Elst == nil || len(t.Trak.Edts.Elst.MediaTime) == 0 {
+		return nil, nil
+	}
+	elst := t.Trak.Edts.Elst
+	movieTimescale := t.MovieTimescale
+	if movieTimescale == 0 {
+		movieTimescale = t.Timescale()
+	}
+	l := []edit{}
+	var start int64
+	for i, mt := range elst.MediaTime {
+		if i >= len(elst.SegmentDuration) || i >= len(elst.MediaRateInteger) || i >= len(elst.MediaRateFraction) {
+			return nil, ErrBadFormat
+		}
+		if mt != -1 && (elst.MediaRateInteger[i] != 1 || elst.MediaRateFraction[i] != 0) {
+			return nil, ErrUnsupportedEditList
+		}
+		hi, lo := bits.Mul64(elst.SegmentDuration[i], uint64(t.Timescale()))
+		if hi >= uint64(movieTimescale) {
+			return nil, ErrBadFormat
+		}
+		q, _ := bits.Div64(hi, lo, uint64(movieTimescale))
+		if q > uint64(math.MaxInt64-start) {
+			return nil, ErrBadFormat
+		}
+		d := int64(q)
+		if d == 0 && i == len(elst.MediaTime)-1 {
+			// fragmented media : the last edit lasts until the end of the media
+			d = math.MaxInt64 - start
+		}
+		l = append(l, edit{start: start, duration: d, mediaTime: mt})
+		start += d
+	}
+	return l, nil
+}
+
+// movieTime returns the time on the presentation timeline of the media time v. A media time that is not
+// presented is placed relative to the next media edit, or to the last one : the samples skipped at the
+// beginning get a negative time.
+func movieTime(edits []edit, v int64) int64 {
+	var next, last *edit
+	for i := range edits {
+		e := &edits[i]
+		if e.mediaTime == -1 {
+			continue
+		}
+		if v >= e.mediaTime && v-e.mediaTime < e.duration {
+			return e.start + v - e.mediaTime
+		}
+		if next == nil && e.mediaTime > v {
+			next = e
+		}
+		last = e
+	}
+	if next == nil {
+		next = last
+	}
+	if next == nil {
+		return v
+	}
+	return next.start + v - next.mediaTime
+}
+
+// mediaTime returns the media time presented at the time v of the presentation timeline, or
+// ErrTimeOutOfRange if no media is presented at v
+func mediaTime(edits []edit, v int64) (int64, error) {
+	if edits == nil {
+		return v, nil
+	}
+	for _, e := range edits {
+		if v >= e.start && v-e.start < e.duration {
+			if e.mediaTime == -1 {
+				break
+			}
+			return e.mediaTime + v - e.start, nil
+		}
+	}
+	return 0, ErrTimeOutOfRange
+}
+
+// presentationBounds holds the bounds of the composition offsets of the samples of a track, and the end of
+// their presentation, in track time units
+type presentationBounds struct {
+	minOffset, maxOffset int64
+	end                  int64
+}
+
+// presentationBounds returns the bounds of the presentation of the samples, computed on the first call
+func (t *Track) presentationBounds() (*presentationBounds, error) {
+	if t.bounds != nil {
+		return t.bounds, nil
+	}
+	b := &presentationBounds{}
+	it := t.Samples()
+	for it.Next() {
+		s := it.Sample()
+		offset := s.PresentationTime - int64(s.DecodeTime)
+		if s.Number == 1 || offset < b.minOffset {
+			b.minOffset = offset
+		}
+		if s.Number == 1 || offset > b.maxOffset {
+			b.maxOffset = offset
+		}
+		if e := s.PresentationTime + int64(s.Duration); e > b.end {
+			b.end = e
+		}
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	t.bounds = b
+	return b, nil
+}
+
+// sampleAtDecodeTime returns the number of the last sample decoded at or before v, 0 if there is none
+func (t *Track) sampleAtDecodeTime(v int64) uint32 {
+	if v < 0 {
+		return 0
+	}
+	dt := uint64(v)
+	if i := sort.Search(len(t.runs), func(i int) bool { return t.runs[i].decodeTime > dt }) - 1; i >= 0 {
+		r := &t.runs[i]
+		n, dts := r.first, r.decodeTime
+		for j := 1; j < len(r.trun.Samples); j++ {
+			dts += uint64(r.duration(j - 1))
+			if dts > dt {
+				break
+			}
+			n++
+		}
+		return n
+	}
+	i := sort.Search(len(t.sttsTime), func(i int) bool { return t.sttsTime[i] > dt }) - 1
+	if i < 0 {
+		return 0
+	}
+	stts := t.stbl.Stts
+	k := stts.SampleCount[i]
+	if delta := uint64(stts.SampleTimeDelta[i]); delta > 0 && (dt-t.sttsTime[i])/delta < uint64(k) {
+		k = uint32((dt-t.sttsTime[i])/delta) + 1
+	}
+	return min(t.sttsFirst[i]+k, t.stblSampleCount())
+}
+
+// toDuration converts a time in track time units to a time.Duration
+func (t *Track) toDuration(v int64) time.Duration {
+	ts := int64(t.Timescale())
+	if ts == 0 {
+		return 0
+	}
+	return time.Duration(v/ts)*time.Second + time.Duration(v%ts)*time.Second/time.Duration(ts)
+}
+
+// fromDuration converts a time.Duration to a time in track time units (rounded down)
+func (t *Track) fromDuration(d time.Duration) int64 {
+	ts := int64(t.Timescale())
+	sec := int64(d / time.Second)
+	rem := int64(d % time.Second)
+	v := sec*ts + rem*ts/int64(time.Second)
+	if rem < 0 && rem*ts%int64(time.Second) != 0 {
+		v--
+	}
+	return v
+}
+
+// TimeOfSample returns the presentation time of the sample n (starting at 1), once the composition
+// offset and the edit list are applied. It is negative for the samples skipped at the beginning by the
+// edit list.
+func (t *Track) TimeOfSample(n uint32) (time.Duration, error) {
+	s, err := t.Sample(n)
+	if err != nil {
+		return 0, err
+	}
+	edits, err := t.edits()
+	if err != nil {
+		return 0, err
+	}
+	return t.toDuration(movieTime(edits, s.PresentationTime)), nil
+}
+
+// SampleAtTime returns the sample presented at the time d of the presentation timeline, i.e. the sample
+// with the latest presentation time before or at d. The samples are found with binary searches, only the
+// samples whose composition offsets could reorder them around d are read.
+func (t *Track) SampleAtTime(d time.Duration) (SampleInfo, error) {
+	edits, err := t.edits()
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	pt, err := mediaTime(edits, t.fromDuration(d))
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	b, err := t.presentationBounds()
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	if pt >= b.end {
+		return SampleInfo{}, ErrTimeOutOfRange
+	}
+	// the samples presented before or at pt are decoded before or at pt - minOffset
+	last := t.sampleAtDecodeTime(pt - b.minOffset)
+	// the sample decoded at pt - maxOffset is presented before or at pt, the samples decoded before its
+	// presentation time - maxOffset are presented before it
+	first := uint32(1)
+	if n := t.sampleAtDecodeTime(pt - b.maxOffset); n > 0 {
+		s, err := t.Sample(n)
+		if err != nil {
+			return SampleInfo{}, err
+		}
+		first = max(first, t.sampleAtDecodeTime(s.PresentationTime-b.maxOffset))
+	}
+	var found bool
+	var best SampleInfo
+	for n := first; n <= last; n++ {
+		s, err := t.Sample(n)
+		if err != nil {
+			return SampleInfo{}, err
+		}
+		if s.PresentationTime <= pt && (!found || s.PresentationTime > best.PresentationTime) {
+			best, found = s, true
+		}
+	}
+	if !found {
+		return SampleInfo{}, ErrTimeOutOfRange
+	}
+	return best, nil
+}
+
+// SyncSampleBefore returns the sync sample from which the decoding must start to present the sample at
+// the time d, i.e. the last sync sample preceding (in decoding order) the sample returned by SampleAtTime.
+func (t *Track) SyncSampleBefore(d time.Duration) (SampleInfo, error) {
+	s, err := t.SampleAtTime(d)
+	if err != nil || s.IsSync {
+		return s, err
+	}
+	return t.syncSampleBefore(s.Number)
+}
+
+// syncSampleBefore returns the last sync sample preceding the sample n
+func (t *Track) syncSampleBefore(n uint32) (SampleInfo, error) {
+	// sync flags of the fragment samples, from the run of the sample n backwards
+	for i := sort.Search(len(t.runs), func(i int) bool { return t.runs[i].first >= n }) - 1; i >= 0; i-- {
+		r := &t.runs[i]
+		for j := min(int(n-r.first), len(r.trun.Samples)) - 1; j >= 0; j-- {
+			if r.flags(j)&SampleIsNonSyncSample == 0 {
+				return t.Sample(r.first + uint32(j))
+			}
+		}
+	}
+	last := min(n-1, t.stblSampleCount())
+	if last == 0 {
+		return SampleInfo{}, ErrTimeOutOfRange
+	}
+	if t.stbl.Stss == nil {
+		return t.Sample(last)
+	}
+	l := t.stbl.Stss.SampleNumber
+	i := sort.Search(len(l), func(i int) bool { return l[i] > last })
+	if i == 0 {
+		return SampleInfo{}, ErrTimeOutOfRange
+	}
+	return t.Sample(l[i-1])
+}
@@ -0,0 +1,239 @@
+package mp4
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// testTrack returns a track of 100 units long samples at 1000 units per second, in a single chunk, with the
+// composition offsets (one per sample, if any) and the sync samples given
+func testTrack(t *testing.T, count uint32, offsets []int32, sync []uint32, elst *ElstBox) *Track {
+	t.Helper()
+	stbl := &StblBox{
+		Stts: &SttsBox{SampleCount: []uint32{count}, SampleTimeDelta: []uint32{100}},
+		Stsc: &StscBox{FirstChunk: []uint32{1}, SamplesPerChunk: []uint32{count}, SampleDescriptionID: []uint32{1}},
+		Stsz: &StszBox{SampleUniformSize: 10, SampleNumber: count},
+		Stco: &StcoBox{ChunkOffset: []uint32{1000}},
+	}
+	if offsets != nil {
+		stbl.Ctts = &CttsBox{}
+		for _, o := range offsets {
+			stbl.Ctts.SampleCount = append(stbl.Ctts.SampleCount, 1)
+			stbl.Ctts.SampleOffset = append(stbl.Ctts.SampleOffset, o)
+		}
+	}
+	if sync != nil {
+		stbl.Stss = &StssBox{SampleNumber: sync}
+	}
+	trak := &TrakBox{
+		Tkhd: &TkhdBox{TrackId: 1},
+		Mdia: &MdiaBox{Mdhd: &MdhdBox{Timescale: 1000}, Minf: &MinfBox{Stbl: stbl}},
+	}
+	if elst != nil {
+		trak.Edts = &EdtsBox{Elst: elst}
+	}
+	tr, err := NewTrack(trak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+// checkSeek compares SampleAtTime and SyncSampleBefore to a scan of all the samples, around the presentation
+// of the track
+func checkSeek(t *testing.T, name string, tr *Track) {
+	t.Helper()
+	edits, err := tr.edits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var end int64
+	samples := []SampleInfo{}
+	it := tr.Samples()
+	for it.Next() {
+		s := it.Sample()
+		samples = append(samples, s)
+		end = max(end, s.PresentationTime+int64(s.Duration))
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	step := max(end/500, 1)
+	for v := -10 * step; v < end+10*step; v += step {
+		d := tr.toDuration(v)
+		got, err := tr.SampleAtTime(d)
+		sync, syncErr := tr.SyncSampleBefore(d)
+		var want, wantSync SampleInfo
+		found := false
+		if pt, err := mediaTime(edits, tr.fromDuration(d)); err == nil && pt < end {
+			for _, s := range samples {
+				if s.PresentationTime <= pt && (!found || s.PresentationTime > want.PresentationTime) {
+					want, found = s, true
+				}
+			}
+			for _, s := range samples {
+				if s.Number <= want.Number && s.IsSync {
+					wantSync = s
+				}
+			}
+		}
+		if !found {
+			if err != ErrTimeOutOfRange || syncErr != ErrTimeOutOfRange {
+				t.Fatalf("%s, %v: got sample %d (%v), %d (%v), want out of range", name, d, got.Number, err,
+					sync.Number, syncErr)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Fatalf("%s, %v: got sample %d (%v), want %d", name, d, got.Number, err, want.Number)
+		}
+		if wantSync.Number == 0 {
+			if syncErr != ErrTimeOutOfRange {
+				t.Fatalf("%s, %v: got sync sample %d (%v), want out of range", name, d, sync.Number, syncErr)
+			}
+			continue
+		}
+		if syncErr != nil || sync != wantSync {
+			t.Fatalf("%s, %v: got sync sample %d (%v), want %d", name, d, sync.Number, syncErr, wantSync.Number)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	// I P B B, decoded at 0, 100, 200, 300 and presented at 100, 400, 200, 300
+	offsets := []int32{}
+	for i := 0; i < 10; i++ {
+		offsets = append(offsets, 100, 300, 0, 0)
+	}
+	checkSeek(t, "reordered", testTrack(t, 40, offsets, []uint32{1, 9, 21, 33}, nil))
+	checkSeek(t, "negative offsets", testTrack(t, 40, func() []int32 {
+		l := []int32{}
+		for _, o := range offsets {
+			l = append(l, o-100)
+		}
+		return l
+	}(), []uint32{1, 5, 9}, nil))
+	checkSeek(t, "no sync sample table", testTrack(t, 20, nil, nil, nil))
+	checkSeek(t, "late sync sample", testTrack(t, 20, nil, []uint32{8}, nil))
+
+	for _, file := range []string{"sample/meta.test1.mp4", "sample/hls-init.mp4"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var segment *MP4
+		if m.Moov.Mvex != nil {
+			data, err := os.ReadFile("sample/hls_sequence_00299.m4s")
+			if err != nil {
+				t.Fatal(err)
+			}
+			segment, err = Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, trak := range m.Moov.Trak {
+			tr, err := NewTrack(trak)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.MovieTimescale = m.Moov.Mvhd.Timescale
+			if segment != nil {
+				if err := tr.AddFragments(m.Moov.Mvex, segment.Moof...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkSeek(t, file, tr)
+		}
+	}
+}
+
+func TestEditList(t *testing.T) {
+	elst := func(duration []uint64, mediaTime []int64, rate uint16) *ElstBox {
+		b := &ElstBox{SegmentDuration: duration, MediaTime: mediaTime}
+		for range duration {
+			b.MediaRateInteger = append(b.MediaRateInteger, rate)
+			b.MediaRateFraction = append(b.MediaRateFraction, 0)
+		}
+		return b
+	}
+	// a 500 ms delay, the samples 3 to 5, and the samples 8 and 9
+	tr := testTrack(t, 10, nil, nil, elst([]uint64{500, 300, 200}, []int64{-1, 200, 700}, 1))
+	times := map[uint32]time.Duration{
+		1:  300 * time.Millisecond, // skipped, before the first media edit
+		3:  500 * time.Millisecond,
+		5:  700 * time.Millisecond,
+		6:  600 * time.Millisecond, // skipped, before the second media edit
+		8:  800 * time.Millisecond,
+		9:  900 * time.Millisecond,
+		10: 1000 * time.Millisecond, // skipped, after the last media edit
+	}
+	for n, want := range times {
+		if got, err := tr.TimeOfSample(n); err != nil || got != want {
+			t.Errorf("sample %d: time %v (%v), want %v", n, got, err, want)
+		}
+	}
+	samples := []struct {
+		d    time.Duration
+		want uint32
+	}{
+		{0, 0},
+		{499 * time.Millisecond, 0},
+		{500 * time.Millisecond, 3},
+		{650 * time.Millisecond, 4},
+		{799 * time.Millisecond, 5},
+		{800 * time.Millisecond, 8},
+		{999 * time.Millisecond, 9},
+		{time.Second, 0},
+	}
+	for _, tt := range samples {
+		s, err := tr.SampleAtTime(tt.d)
+		if tt.want == 0 {
+			if err != ErrTimeOutOfRange {
+				t.Errorf("%v: got sample %d (%v), want out of range", tt.d, s.Number, err)
+			}
+			continue
+		}
+		if err != nil || s.Number != tt.want {
+			t.Errorf("%v: got sample %d (%v), want %d", tt.d, s.Number, err, tt.want)
+		}
+	}
+
+	// the last edit of a fragmented media lasts until the end of the media
+	tr = testTrack(t, 10, nil, nil, elst([]uint64{0}, []int64{200}, 1))
+	if s, err := tr.SampleAtTime(500 * time.Millisecond); err != nil || s.Number != 8 {
+		t.Errorf("unbounded edit: got sample %d (%v)", s.Number, err)
+	}
+
+	// empty edit list
+	tr = testTrack(t, 10, nil, nil, elst(nil, nil, 1))
+	if s, err := tr.SampleAtTime(500 * time.Millisecond); err != nil || s.Number != 6 {
+		t.Errorf("empty edit list: got sample %d (%v)", s.Number, err)
+	}
+
+	// durations that do not fit in 64 bits once converted to the track timescale, or once added
+	for _, duration := range [][]uint64{{math.MaxUint64}, {math.MaxInt64 / 1000, math.MaxInt64 / 1000, 500}} {
+		mediaTime := make([]int64, len(duration))
+		tr = testTrack(t, 10, nil, nil, elst(duration, mediaTime, 1))
+		tr.MovieTimescale = 1
+		if _, err := tr.SampleAtTime(0); err != ErrBadFormat {
+			t.Errorf("overflow %v: %v", duration, err)
+		}
+	}
+
+	// dwell
+	tr = testTrack(t, 10, nil, nil, elst([]uint64{100, 500}, []int64{0, 100}, 0))
+	if _, err := tr.SampleAtTime(0); err != ErrUnsupportedEditList {
+		t.Errorf("dwell: %v", err)
+	}
+	if _, err := tr.TimeOfSample(1); err != ErrUnsupportedEditList {
+		t.Errorf("dwell: %v", err)
+	}
+}
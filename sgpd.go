@@ -10,7 +10,9 @@ import (
 // Container: Sample Table Box (‘stbl’) or Track Fragment Box (‘traf’)
 // Mandatory: No
 // Quantity: Zero or more, with one for each Sample to Group Box.
-
+//
+// The format of the entries depends on the grouping type, they are kept as is. Only version 1 gives their
+// length (DefaultLength or DescriptionLength) : the entries of the other versions are not split.
 type SgpdBox struct {
 	FullBox
 	GroupType                     uint32
@@ -18,38 +20,62 @@ type SgpdBox struct {
 	DefaultSampleDescriptionIndex uint32 // Version >= 2
 	EntryCount                    uint32
 	DescriptionLength             []uint32 // Version == 1 && DefaultLength == 0
-	SampleGroupEntry              []uint32
+	SampleGroupEntry              [][]byte // Version == 1
+	notDecoded                    []byte
 }
 
 func DecodeSgpd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 12 {
+		return nil, ErrTruncatedBody
+	}
 	b := &SgpdBox{
 		FullBox:           DecodeFullBox(data[0:4]),
 		GroupType:         binary.BigEndian.Uint32(data[4:8]),
-		EntryCount:        binary.BigEndian.Uint32(data[12:16]),
 		DescriptionLength: []uint32{},
-		SampleGroupEntry:  []uint32{},
+		SampleGroupEntry:  [][]byte{},
 	}
-	if b.Version == 1 {
-		b.DefaultLength = binary.BigEndian.Uint32(data[8:12])
-	} else if b.Version >= 2 {
-		b.DefaultSampleDescriptionIndex = binary.BigEndian.Uint32(data[8:12])
+	off := 8
+	if b.Version >= 1 {
+		if len(data) < 16 {
+			return nil, ErrTruncatedBody
+		}
+		if b.Version == 1 {
+			b.DefaultLength = binary.BigEndian.Uint32(data[8:12])
+		} else {
+			b.DefaultSampleDescriptionIndex = binary.BigEndian.Uint32(data[8:12])
+		}
+		off += 4
+	}
+	b.EntryCount = binary.BigEndian.Uint32(data[off : off+4])
+	off += 4
+	if b.Version != 1 {
+		b.notDecoded = data[off:]
+		return b, nil
+	}
+	if err = checkEntries(r, uint64(b.EntryCount), 0, off, data); err != nil {
+		return nil, err
 	}
 	for i := 0; i < int(b.EntryCount); i++ {
-		var sampleGroupEntry uint32
-		if b.Version == 1 && b.DefaultLength == 0 {
-			descriptionLength := binary.BigEndian.Uint32(data[(12 + i*4):(16 + i*4)])
-			b.DescriptionLength = append(b.DescriptionLength, descriptionLength)
-			sampleGroupEntry = binary.BigEndian.Uint32(data[(16 + i*4):(20 + i*4)])
-		} else {
-			sampleGroupEntry = binary.BigEndian.Uint32(data[(12 + i*4):(16 + i*4)])
+		l := b.DefaultLength
+		if l == 0 {
+			if off+4 > len(data) {
+				return nil, ErrTruncatedBody
+			}
+			l = binary.BigEndian.Uint32(data[off : off+4])
+			b.DescriptionLength = append(b.DescriptionLength, l)
+			off += 4
+		}
+		if uint64(off)+uint64(l) > uint64(len(data)) {
+			return nil, ErrTruncatedBody
 		}
-		b.SampleGroupEntry = append(b.SampleGroupEntry, sampleGroupEntry)
+		b.SampleGroupEntry = append(b.SampleGroupEntry, data[off:off+int(l)])
+		off += int(l)
 	}
+	b.notDecoded = data[off:]
 	return b, nil
 }
 
@@ -62,17 +88,25 @@ func (b *SgpdBox) Type() string {
 }
 
 func (b *SgpdBox) Size() int {
-	sz := BoxHeaderSize + b.FullBox.Size()
-	if b.Version == 1 && b.DefaultLength == 0 {
-		sz += len(b.DescriptionLength)
+	sz := BoxHeaderSize + b.FullBox.Size() + 8 + len(b.notDecoded)
+	if b.Version >= 1 {
+		sz += 4
+	}
+	if b.Version == 1 {
+		if b.DefaultLength == 0 {
+			sz += 4 * len(b.SampleGroupEntry)
+		}
+		for _, e := range b.SampleGroupEntry {
+			sz += len(e)
+		}
 	}
-	sz += len(b.SampleGroupEntry)
 	return sz
 }
 
 func (b *SgpdBox) Dump() {
 	fmt.Println("Sample Group Description Box:")
-	// TODO:
+	fmt.Printf("+- Grouping type: %s\n", fourCC(b.GroupType))
+	fmt.Printf("+- Entry count: %d\n", b.EntryCount)
 }
 
 func (b *SgpdBox) Encode(w io.Writer) error {
@@ -83,20 +117,26 @@ func (b *SgpdBox) Encode(w io.Writer) error {
 	buf := makebuf(b)
 	EncodeFullBox(b.FullBox, buf)
 	binary.BigEndian.PutUint32(buf[4:], b.GroupType)
+	off := 8
 	if b.Version == 1 {
-		binary.BigEndian.PutUint32(buf[8:], b.DefaultLength)
+		binary.BigEndian.PutUint32(buf[off:], b.DefaultLength)
+		off += 4
 	} else if b.Version >= 2 {
-		binary.BigEndian.PutUint32(buf[8:], b.DefaultSampleDescriptionIndex)
+		binary.BigEndian.PutUint32(buf[off:], b.DefaultSampleDescriptionIndex)
+		off += 4
 	}
-	binary.BigEndian.PutUint32(buf[12:], b.EntryCount)
-	for i := uint32(0); i < b.EntryCount; i++ {
-		if b.Version == 1 && b.DefaultLength == 0 {
-			binary.BigEndian.PutUint32(buf[(12+i*4):], b.DescriptionLength[i])
-			binary.BigEndian.PutUint32(buf[(16+i*4):], b.SampleGroupEntry[i])
-		} else {
-			binary.BigEndian.PutUint32(buf[(14+i*4):], b.SampleGroupEntry[i])
+	binary.BigEndian.PutUint32(buf[off:], b.EntryCount)
+	off += 4
+	if b.Version == 1 {
+		for _, e := range b.SampleGroupEntry {
+			if b.DefaultLength == 0 {
+				binary.BigEndian.PutUint32(buf[off:], uint32(len(e)))
+				off += 4
+			}
+			off += copy(buf[off:], e)
 		}
 	}
+	copy(buf[off:], b.notDecoded)
 	_, err = w.Write(buf)
 	return err
 }
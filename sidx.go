@@ -23,47 +23,56 @@ type SidxBox struct {
 	Flags                    [3]byte
 	ReferenceId              uint32
 	Timescale                uint32
-	EarliestPresentationTime uint32
-	FirstOffset              uint32
+	EarliestPresentationTime uint64 // 32 bits for version 0
+	FirstOffset              uint64 // 32 bits for version 0
 	Reserved                 uint16
 	ReferenceCount           uint16
 	References               []Reference
 }
 
 func DecodeSidx(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 24, 32); err != nil {
+		return nil, err
+	}
 	b := &SidxBox{
-		Version:                  data[0],
-		Flags:                    [3]byte{data[1], data[2], data[3]},
-		ReferenceId:              binary.BigEndian.Uint32(data[4:8]),
-		Timescale:                binary.BigEndian.Uint32(data[8:12]),
-		EarliestPresentationTime: binary.BigEndian.Uint32(data[12:16]),
-		FirstOffset:              binary.BigEndian.Uint32(data[16:20]),
-		Reserved:                 0,
-		ReferenceCount:           binary.BigEndian.Uint16(data[20:22]),
-		References:               []Reference{},
+		Version:     data[0],
+		Flags:       [3]byte{data[1], data[2], data[3]},
+		ReferenceId: binary.BigEndian.Uint32(data[4:8]),
+		Timescale:   binary.BigEndian.Uint32(data[8:12]),
+		References:  []Reference{},
 	}
-	rc := b.ReferenceCount
-	// TODO: validate below
-	for i := 0; i < int(rc); i++ {
-		refType := 0xfe ^ data[22+i*12]
-		refdSize := binary.BigEndian.Uint32(data[22+i*12:26+i*12]) << 1
-		subSegDur := binary.BigEndian.Uint32(data[26+i*12 : 30+i*12])
-		startWithSAP := 0xfe ^ data[30+i*12]
-		sapType := data[31+i*12] << 1
-		sapDeltaTime := binary.BigEndian.Uint32(data[34+i*12:30+i*12]) << 4
+	off := 12
+	if b.Version == 1 {
+		b.EarliestPresentationTime = binary.BigEndian.Uint64(data[off : off+8])
+		b.FirstOffset = binary.BigEndian.Uint64(data[off+8 : off+16])
+		off += 16
+	} else {
+		b.EarliestPresentationTime = uint64(binary.BigEndian.Uint32(data[off : off+4]))
+		b.FirstOffset = uint64(binary.BigEndian.Uint32(data[off+4 : off+8]))
+		off += 8
+	}
+	b.Reserved = binary.BigEndian.Uint16(data[off : off+2])
+	b.ReferenceCount = binary.BigEndian.Uint16(data[off+2 : off+4])
+	off += 4
+	if err = checkEntries(r, uint64(b.ReferenceCount), 12, off, data); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(b.ReferenceCount); i++ {
+		refd := binary.BigEndian.Uint32(data[off : off+4])
+		sap := binary.BigEndian.Uint32(data[off+8 : off+12])
 		b.References = append(b.References, Reference{
-			ReferenceType:      refType,
-			ReferencedSize:     refdSize,
-			SubSegmentDuration: subSegDur,
-			StartsWithSAP:      startWithSAP,
-			SAPType:            sapType,
-			SAPDeltaTime:       sapDeltaTime,
+			ReferenceType:      byte(refd >> 31),
+			ReferencedSize:     refd & 0x7fffffff,
+			SubSegmentDuration: binary.BigEndian.Uint32(data[off+4 : off+8]),
+			StartsWithSAP:      byte(sap >> 31),
+			SAPType:            uint8(sap>>28) & 0x07,
+			SAPDeltaTime:       sap & 0x0fffffff,
 		})
+		off += 12
 	}
 	return b, nil
 }
@@ -76,8 +85,16 @@ func (b *SidxBox) Type() string {
 	return "sidx"
 }
 
+// version returns the version of the box, 1 if a value does not fit in 32 bits
+func (b *SidxBox) version() byte {
+	return fullBoxVersion(b.Version, b.EarliestPresentationTime, b.FirstOffset)
+}
+
 func (b *SidxBox) Size() int {
-	return BoxHeaderSize + 44
+	if b.version() == 1 {
+		return BoxHeaderSize + 32 + len(b.References)*12
+	}
+	return BoxHeaderSize + 24 + len(b.References)*12
 }
 
 func (b *SidxBox) Dump() {
@@ -100,6 +117,34 @@ func (b *SidxBox) Dump() {
 }
 
 func (b *SidxBox) Encode(w io.Writer) error {
-	// TODO: encode
-	return nil
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.version()
+	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
+	binary.BigEndian.PutUint32(buf[4:], b.ReferenceId)
+	binary.BigEndian.PutUint32(buf[8:], b.Timescale)
+	off := 12
+	if b.version() == 1 {
+		binary.BigEndian.PutUint64(buf[off:], b.EarliestPresentationTime)
+		binary.BigEndian.PutUint64(buf[off+8:], b.FirstOffset)
+		off += 16
+	} else {
+		binary.BigEndian.PutUint32(buf[off:], uint32(b.EarliestPresentationTime))
+		binary.BigEndian.PutUint32(buf[off+4:], uint32(b.FirstOffset))
+		off += 8
+	}
+	binary.BigEndian.PutUint16(buf[off:], b.Reserved)
+	binary.BigEndian.PutUint16(buf[off+2:], uint16(len(b.References)))
+	off += 4
+	for _, r := range b.References {
+		binary.BigEndian.PutUint32(buf[off:], uint32(r.ReferenceType&0x01)<<31|r.ReferencedSize&0x7fffffff)
+		binary.BigEndian.PutUint32(buf[off+4:], r.SubSegmentDuration)
+		binary.BigEndian.PutUint32(buf[off+8:], uint32(r.StartsWithSAP&0x01)<<31|uint32(r.SAPType&0x07)<<28|r.SAPDeltaTime&0x0fffffff)
+		off += 12
+	}
+	_, err = w.Write(buf)
+	return err
 }
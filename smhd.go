@@ -18,11 +18,13 @@ type SmhdBox struct {
 }
 
 func DecodeSmhd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 6, 6); err != nil {
+		return nil, err
+	}
 	return &SmhdBox{
 		Version: data[0],
 		Flags:   [3]byte{data[1], data[2], data[3]},
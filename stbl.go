@@ -3,6 +3,7 @@ package mp4
 import (
 	"fmt"
 	"io"
+	"math"
 )
 
 // Sample Table Box (stbl - mandatory)
@@ -24,6 +25,7 @@ type StblBox struct {
 	Ctts  *CttsBox   `json:"ctts,omitempty"`
 	Sgpd  *SgpdBox   `json:"jspd,omitempty"`
 	Boxes []Box      `json:",omitempty"`
+	order []string
 }
 
 func DecodeStbl(h BoxHeader, r io.Reader) (Box, error) {
@@ -31,8 +33,12 @@ func DecodeStbl(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	s := &StblBox{}
+	s := &StblBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			s.Boxes = append(s.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "sbgp":
 			s.Sbgp = append(s.Sbgp, b.(*SbgpBox))
@@ -69,34 +75,51 @@ func (b *StblBox) Type() string {
 	return "stbl"
 }
 
-func (b *StblBox) Size() int {
-	sz := b.Stsd.Size()
-	for _, s := range b.Sbgp {
-		sz += s.Size()
+// boxes returns the boxes of the stbl box, in the decoding order
+func (b *StblBox) boxes() []Box {
+	l := []Box{}
+	if b.Stsd != nil {
+		l = append(l, b.Stsd)
 	}
 	if b.Stts != nil {
-		sz += b.Stts.Size()
+		l = append(l, b.Stts)
+	}
+	if b.Ctts != nil {
+		l = append(l, b.Ctts)
 	}
 	if b.Stss != nil {
-		sz += b.Stss.Size()
+		l = append(l, b.Stss)
 	}
 	if b.Stsc != nil {
-		sz += b.Stsc.Size()
+		l = append(l, b.Stsc)
 	}
 	if b.Stsz != nil {
-		sz += b.Stsz.Size()
+		l = append(l, b.Stsz)
 	}
 	if b.Stco != nil {
-		sz += b.Stco.Size()
+		l = append(l, b.Stco)
 	}
-	if b.Ctts != nil {
-		sz += b.Ctts.Size()
+	if b.Co64 != nil {
+		l = append(l, b.Co64)
+	}
+	for _, s := range b.Sbgp {
+		l = append(l, s)
 	}
-	return sz + BoxHeaderSize
+	if b.Sgpd != nil {
+		l = append(l, b.Sgpd)
+	}
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *StblBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *StblBox) Dump() {
 	fmt.Printf("Sample Table Box\n")
+	if b.Stsd != nil {
+		b.Stsd.Dump()
+	}
 	for _, s := range b.Sbgp {
 		s.Dump()
 	}
@@ -115,6 +138,12 @@ func (b *StblBox) Dump() {
 	if b.Stco != nil {
 		b.Stco.Dump()
 	}
+	if b.Co64 != nil {
+		b.Co64.Dump()
+	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func (b *StblBox) Encode(w io.Writer) error {
@@ -122,40 +151,52 @@ func (b *StblBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	for _, s := range b.Sbgp {
-		err = s.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	err = b.Stsd.Encode(w)
-	if err != nil {
-		return err
-	}
-	err = b.Stts.Encode(w)
-	if err != nil {
-		return err
+	return encodeBoxes(b.boxes(), w)
+}
+
+// ChunkOffsets returns the offsets of the chunks, from the stco or the co64 box
+func (b *StblBox) ChunkOffsets() []uint64 {
+	l := []uint64{}
+	if b.Co64 != nil {
+		return append(l, b.Co64.ChunkOffset...)
 	}
-	if b.Stss != nil {
-		err = b.Stss.Encode(w)
-		if err != nil {
-			return err
+	if b.Stco != nil {
+		for _, o := range b.Stco.ChunkOffset {
+			l = append(l, uint64(o))
 		}
 	}
-	err = b.Stsc.Encode(w)
-	if err != nil {
-		return err
+	return l
+}
+
+// SetChunkOffsets sets the offsets of the chunks. The stco box is replaced by a co64 box when an offset does
+// not fit in 32 bits, a co64 box being kept as is.
+func (b *StblBox) SetChunkOffsets(offsets []uint64) {
+	large := b.Co64 != nil
+	for _, o := range offsets {
+		if o > math.MaxUint32 {
+			large = true
+			break
+		}
 	}
-	err = b.Stsz.Encode(w)
-	if err != nil {
-		return err
+	if large {
+		if b.Co64 == nil {
+			b.Co64 = &Co64Box{}
+			for i, t := range b.order {
+				if t == "stco" {
+					b.order[i] = "co64"
+				}
+			}
+		}
+		b.Stco = nil
+		b.Co64.ChunkOffset = append([]uint64{}, offsets...)
+		b.Co64.EntryCount = uint32(len(offsets))
+		return
 	}
-	err = b.Stco.Encode(w)
-	if err != nil {
-		return err
+	if b.Stco == nil {
+		b.Stco = &StcoBox{}
 	}
-	if b.Ctts != nil {
-		return b.Ctts.Encode(w)
+	b.Stco.ChunkOffset = make([]uint32, len(offsets))
+	for i, o := range offsets {
+		b.Stco.ChunkOffset[i] = uint32(o)
 	}
-	return nil
 }
@@ -12,7 +12,7 @@ import (
 //
 // Status: decoded
 //
-// This is the 32bits version of the box, the 64bits version (co64) is not decoded.
+// This is the 32bits version of the box, the 64bits version is co64 (see StblBox.SetChunkOffsets).
 //
 // The table contains the offsets (starting at the beginning of the file) for each chunk of data for the current track.
 // A chunk contains samples, the table defining the allocation of samples to each chunk is stsc.
@@ -23,17 +23,22 @@ type StcoBox struct {
 }
 
 func DecodeStco(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	b := &StcoBox{
 		Version:     data[0],
 		Flags:       [3]byte{data[1], data[2], data[3]},
 		ChunkOffset: []uint32{},
 	}
 	ec := binary.BigEndian.Uint32(data[4:8])
+	if err = checkEntries(r, uint64(ec), 4, 8, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(ec); i++ {
 		chunk := binary.BigEndian.Uint32(data[(8 + 4*i):(12 + 4*i)])
 		b.ChunkOffset = append(b.ChunkOffset, chunk)
@@ -27,11 +27,13 @@ type StscBox struct {
 }
 
 func DecodeStsc(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 
 	b := &StscBox{
 		Version:             data[0],
@@ -41,6 +43,9 @@ func DecodeStsc(h BoxHeader, r io.Reader) (Box, error) {
 		SampleDescriptionID: []uint32{},
 	}
 	ec := binary.BigEndian.Uint32(data[4:8])
+	if err = checkEntries(r, uint64(ec), 12, 8, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(ec); i++ {
 		fc := binary.BigEndian.Uint32(data[(8 + 12*i):(12 + 12*i)])
 		spc := binary.BigEndian.Uint32(data[(12 + 12*i):(16 + 12*i)])
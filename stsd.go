@@ -1,6 +1,7 @@
 package mp4
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -9,25 +10,35 @@ import (
 //
 // Contained in : Sample Table box (stbl)
 //
-// Status: not decoded
+// Status: decoded
 //
 // This box contains information that describes how the data can be decoded.
+//
+// Each entry is a sample entry, whose type depends on the track handler :
+// VisualSampleEntry (video tracks), AudioSampleEntry (audio tracks) or TextSampleEntry (subtitles).
+// Unknown sample entries are kept as is.
 type StsdBox struct {
-	Version    byte
-	Flags      [3]byte
-	notDecoded []byte
+	Version byte
+	Flags   [3]byte
+	Entries []Box
 }
 
 func DecodeStsd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
+	l, err := DecodeContainer(childReader(r, data[8:]))
 	if err != nil {
 		return nil, err
 	}
 	return &StsdBox{
-		Version:    data[0],
-		Flags:      [3]byte{data[1], data[2], data[3]},
-		notDecoded: data[4:],
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+		Entries: l,
 	}, nil
 }
 
@@ -40,7 +51,19 @@ func (b *StsdBox) Type() string {
 }
 
 func (b *StsdBox) Size() int {
-	return BoxHeaderSize + 4 + len(b.notDecoded)
+	sz := BoxHeaderSize + 8
+	for _, e := range b.Entries {
+		sz += e.Size()
+	}
+	return sz
+}
+
+// Entry returns the sample entry referenced by a sample description index (starting at 1)
+func (b *StsdBox) Entry(index uint32) Box {
+	if index == 0 || int(index) > len(b.Entries) {
+		return nil
+	}
+	return b.Entries[index-1]
 }
 
 func (b *StsdBox) Encode(w io.Writer) error {
@@ -48,17 +71,27 @@ func (b *StsdBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	buf := makebuf(b)
+	buf := make([]byte, 8)
 	buf[0] = b.Version
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	copy(buf[4:], b.notDecoded)
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(b.Entries)))
 	_, err = w.Write(buf)
-	return err
+	if err != nil {
+		return err
+	}
+	for _, e := range b.Entries {
+		err = e.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *StsdBox) Dump() {
-	fmt.Printf("Video Media Header Box\n")
-	fmt.Printf("+- Version: %d\n", b.Version)
-	fmt.Printf("+- Flags: %v\n", b.Flags)
-	fmt.Printf("+- Payload: %s\n", string(b.notDecoded))
+	fmt.Printf("Sample Description Box\n")
+	for i, e := range b.Entries {
+		fmt.Printf(" #%d : ", i+1)
+		e.Dump()
+	}
 }
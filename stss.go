@@ -20,17 +20,22 @@ type StssBox struct {
 }
 
 func DecodeStss(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	b := &StssBox{
 		Version:      data[0],
 		Flags:        [3]byte{data[1], data[2], data[3]},
 		SampleNumber: []uint32{},
 	}
 	ec := binary.BigEndian.Uint32(data[4:8])
+	if err = checkEntries(r, uint64(ec), 4, 8, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(ec); i++ {
 		sample := binary.BigEndian.Uint32(data[(8 + 4*i):(12 + 4*i)])
 		b.SampleNumber = append(b.SampleNumber, sample)
@@ -25,11 +25,13 @@ type StszBox struct {
 }
 
 func DecodeStsz(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 12, 12); err != nil {
+		return nil, err
+	}
 	b := &StszBox{
 		Version:           data[0],
 		Flags:             [3]byte{data[1], data[2], data[3]},
@@ -38,6 +40,9 @@ func DecodeStsz(h BoxHeader, r io.Reader) (Box, error) {
 		SampleSize:        []uint32{},
 	}
 	if len(data) > 12 {
+		if err = checkEntries(r, uint64(b.SampleNumber), 4, 12, data); err != nil {
+			return nil, err
+		}
 		for i := 0; i < int(b.SampleNumber); i++ {
 			sz := binary.BigEndian.Uint32(data[(12 + 4*i):(16 + 4*i)])
 			b.SampleSize = append(b.SampleSize, sz)
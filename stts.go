@@ -25,11 +25,13 @@ type SttsBox struct {
 }
 
 func DecodeStts(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
 	b := &SttsBox{
 		Version:         data[0],
 		Flags:           [3]byte{data[1], data[2], data[3]},
@@ -37,6 +39,9 @@ func DecodeStts(h BoxHeader, r io.Reader) (Box, error) {
 		SampleTimeDelta: []uint32{},
 	}
 	ec := binary.BigEndian.Uint32(data[4:8])
+	if err = checkEntries(r, uint64(ec), 8, 8, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(ec); i++ {
 		s_count := binary.BigEndian.Uint32(data[(8 + 8*i):(12 + 8*i)])
 		s_delta := binary.BigEndian.Uint32(data[(12 + 8*i):(16 + 8*i)])
@@ -0,0 +1,152 @@
+package mp4
+
+import (
+	"fmt"
+	"io"
+)
+
+// Text Sample Entry (tx3g, wvtt, stpp, stxt, sbtt - mandatory for subtitle and text tracks)
+//
+// Contained in : Sample Description Box (stsd)
+//
+// Status: partially decoded (the tx3g display fields are not decoded)
+//
+// Describes the format of the samples of a text track :
+//
+//   - tx3g : 3GPP timed text
+//   - wvtt : WebVTT, the configuration is stored in the vttC box
+//   - stpp : XML subtitles (TTML), defined by Namespace, SchemaLocation and AuxiliaryMimeTypes
+//   - stxt, sbtt : simple text, defined by ContentEncoding and MimeFormat
+type TextSampleEntry struct {
+	Format             string
+	DataReferenceIndex uint16
+	Namespace          string `json:",omitempty"`
+	SchemaLocation     string `json:",omitempty"`
+	AuxiliaryMimeTypes string `json:",omitempty"`
+	ContentEncoding    string `json:",omitempty"`
+	MimeFormat         string `json:",omitempty"`
+	Boxes              []Box  `json:",omitempty"`
+	header             []byte // as read from the media, with the reserved values
+	notDecoded         []byte
+	terminated         []bool // of the strings read from the media, true if they ended with a null byte
+	trailing           []byte
+}
+
+func DecodeTextSampleEntry(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sampleEntryHeaderSize {
+		return nil, ErrTruncatedBody
+	}
+	b := &TextSampleEntry{
+		Format:             h.Type,
+		DataReferenceIndex: decodeSampleEntryHeader(data),
+		header:             data[:sampleEntryHeaderSize],
+	}
+	off := sampleEntryHeaderSize
+	if h.Type == "tx3g" {
+		if len(data) < off+30 {
+			return nil, ErrTruncatedBody
+		}
+		b.notDecoded = data[off : off+30]
+		off += 30
+	}
+	if b.fields() != nil {
+		b.terminated = []bool{}
+	}
+	for _, f := range b.fields() {
+		var n int
+		*f, n = cstring(data[off:])
+		off += n
+		if n > 0 {
+			b.terminated = append(b.terminated, n > len(*f))
+		}
+	}
+	b.Boxes, b.trailing, err = decodeSampleEntryBoxes(r, data[off:])
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *TextSampleEntry) Box() Box {
+	return b
+}
+
+func (b *TextSampleEntry) Type() string {
+	return b.Format
+}
+
+// fields returns the null terminated strings following the sample entry header
+func (b *TextSampleEntry) fields() []*string {
+	switch b.Format {
+	case "stpp":
+		return []*string{&b.Namespace, &b.SchemaLocation, &b.AuxiliaryMimeTypes}
+	case "stxt", "sbtt":
+		return []*string{&b.ContentEncoding, &b.MimeFormat}
+	}
+	return nil
+}
+
+// terminatorSize returns the size of the terminator of the string i : the strings are null terminated, unless
+// they were not in the media (unterminated or missing) and are followed by empty strings only
+func (b *TextSampleEntry) terminatorSize(i int) int {
+	if b.terminated == nil || (i < len(b.terminated) && b.terminated[i]) {
+		return 1
+	}
+	next := i + 1
+	if i >= len(b.terminated) {
+		// missing, the string itself being empty
+		next = i
+	}
+	for _, f := range b.fields()[next:] {
+		if *f != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (b *TextSampleEntry) Size() int {
+	sz := BoxHeaderSize + sampleEntryHeaderSize + len(b.notDecoded)
+	for i, f := range b.fields() {
+		sz += len(*f) + b.terminatorSize(i)
+	}
+	return sz + sampleEntryBoxesSize(b.Boxes, b.trailing)
+}
+
+func (b *TextSampleEntry) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, b.Size()-BoxHeaderSize-sampleEntryBoxesSize(b.Boxes, b.trailing))
+	copy(buf, b.header)
+	encodeSampleEntryHeader(buf, b.DataReferenceIndex)
+	off := sampleEntryHeaderSize
+	off += copy(buf[off:], b.notDecoded)
+	for i, f := range b.fields() {
+		off += copy(buf[off:], *f) + b.terminatorSize(i)
+	}
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+	return encodeSampleEntryBoxes(w, b.Boxes, b.trailing)
+}
+
+func (b *TextSampleEntry) Dump() {
+	fmt.Printf("Text Sample Entry\n")
+	fmt.Printf("+- Format: %s\n", b.Format)
+	if b.Namespace != "" {
+		fmt.Printf("+- Namespace: %s\n", b.Namespace)
+	}
+	if b.MimeFormat != "" {
+		fmt.Printf("+- Mime format: %s\n", b.MimeFormat)
+	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
+}
@@ -10,10 +10,12 @@ import (
 // Container:	 Track	Fragment	box	(‘traf’)
 // Mandatory:	 No
 // Quantity:	 Zero	or	one
+//
+// Version 0 stores the decode time on 32 bits, version 1 on 64 bits.
 type TfdtBox struct {
 	Version             byte
 	Flags               [3]byte
-	BaseMediaDecodeTime uint32
+	BaseMediaDecodeTime uint64
 }
 
 func (b *TfdtBox) Box() Box {
@@ -24,7 +26,15 @@ func (b *TfdtBox) Type() string {
 	return "tfdt"
 }
 
+// version returns the version of the box, 1 if a value does not fit in 32 bits
+func (b *TfdtBox) version() byte {
+	return fullBoxVersion(b.Version, b.BaseMediaDecodeTime)
+}
+
 func (b *TfdtBox) Size() int {
+	if b.version() == 1 {
+		return BoxHeaderSize + 12
+	}
 	return BoxHeaderSize + 8
 }
 func (b *TfdtBox) Encode(w io.Writer) error {
@@ -33,10 +43,14 @@ func (b *TfdtBox) Encode(w io.Writer) error {
 		return err
 	}
 	buf := makebuf(b)
-	buf[0] = b.Version
+	buf[0] = b.version()
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	binary.BigEndian.PutUint32(buf[4:], b.BaseMediaDecodeTime)
-
+	if b.version() == 1 {
+		binary.BigEndian.PutUint64(buf[4:], b.BaseMediaDecodeTime)
+	} else {
+		binary.BigEndian.PutUint32(buf[4:], uint32(b.BaseMediaDecodeTime))
+	}
+	_, err = w.Write(buf)
 	return err
 }
 func (b *TfdtBox) Dump() {
@@ -45,14 +59,21 @@ func (b *TfdtBox) Dump() {
 }
 
 func DecodeTfdt(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
-	return &TfdtBox{
-		Version:             data[0],
-		Flags:               [3]byte{data[1], data[2], data[3]},
-		BaseMediaDecodeTime: binary.BigEndian.Uint32(data[4:8]),
-	}, nil
+	if err = checkFullBoxSize(data, 8, 12); err != nil {
+		return nil, err
+	}
+	b := &TfdtBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	if b.Version == 1 {
+		b.BaseMediaDecodeTime = binary.BigEndian.Uint64(data[4:12])
+	} else {
+		b.BaseMediaDecodeTime = uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	return b, nil
 }
@@ -14,7 +14,7 @@ type TfhdBox struct {
 	Version                byte
 	Flags                  [3]byte
 	TrackId                uint32 `json:"TrackId,"` // ISO IEC 14496-12 said this is 64 bits, but actual media samples got 32 bits
-	BaseDataOffset         uint32 `json:"BaseDataOffset,omitempty"`
+	BaseDataOffset         uint64 `json:"BaseDataOffset,omitempty"`
 	SampleDescriptionIndex uint32 `json:"SampleDescriptionIndex,omitempty"`
 	DefaultSampleDuration  uint32 `json:"DefaultSampleDuration,omitempty"`
 	DefaultSampleSize      uint32 `json:"DefaultSampleSize,omitempty"`
@@ -48,16 +48,16 @@ func (b *TfhdBox) Size() int {
 		l = l + 8
 	}
 	if compareFlag(flag, SampleDescriptionIndexPresent) {
-		l = l + 8
+		l = l + 4
 	}
 	if compareFlag(flag, DefaultSampleDurationPresent) {
-		l = l + 8
+		l = l + 4
 	}
 	if compareFlag(flag, DefaultSampleSizePresent) {
-		l = l + 8
+		l = l + 4
 	}
 	if compareFlag(flag, DefaultSampleFlagsPresent) {
-		l = l + 8
+		l = l + 4
 	}
 
 	return l
@@ -75,8 +75,8 @@ func (b *TfhdBox) Encode(w io.Writer) error {
 	startOffset := 8
 	flag := BEUint28(b.Flags[:])
 	if compareFlag(flag, BaseDataOffsetPresent) {
-		binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], b.BaseDataOffset)
-		startOffset = startOffset + 4
+		binary.BigEndian.PutUint64(buf[startOffset:startOffset+8], b.BaseDataOffset)
+		startOffset = startOffset + 8
 	}
 	if compareFlag(flag, SampleDescriptionIndexPresent) {
 		binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], b.SampleDescriptionIndex)
@@ -92,9 +92,8 @@ func (b *TfhdBox) Encode(w io.Writer) error {
 	}
 	if compareFlag(flag, DefaultSampleFlagsPresent) {
 		binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], b.DefaultSampleFlags)
-		startOffset = startOffset + 4
 	}
-
+	_, err = w.Write(buf)
 	return err
 }
 func (b *TfhdBox) Dump() {
@@ -128,11 +127,26 @@ func (b *TfhdBox) Dump() {
 }
 
 func DecodeTfhd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
+	size := 8
+	if compareFlag(BEUint28(data[1:4]), BaseDataOffsetPresent) {
+		size += 8
+	}
+	for _, f := range []uint32{SampleDescriptionIndexPresent, DefaultSampleDurationPresent,
+		DefaultSampleSizePresent, DefaultSampleFlagsPresent} {
+		if compareFlag(BEUint28(data[1:4]), f) {
+			size += 4
+		}
+	}
+	if len(data) < size {
+		return nil, ErrBadFormat
+	}
 	b := &TfhdBox{
 		Version: data[0],
 		Flags:   [3]byte{data[1], data[2], data[3]},
@@ -141,8 +155,8 @@ func DecodeTfhd(h BoxHeader, r io.Reader) (Box, error) {
 	startOffset := 8
 	flag := BEUint28(b.Flags[:])
 	if compareFlag(flag, BaseDataOffsetPresent) {
-		b.BaseDataOffset = binary.BigEndian.Uint32(data[startOffset : startOffset+4])
-		startOffset = startOffset + 4
+		b.BaseDataOffset = binary.BigEndian.Uint64(data[startOffset : startOffset+8])
+		startOffset = startOffset + 8
 	}
 	if compareFlag(flag, SampleDescriptionIndexPresent) {
 		b.SampleDescriptionIndex = binary.BigEndian.Uint32(data[startOffset : startOffset+4])
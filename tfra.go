@@ -6,6 +6,13 @@ import (
 	"io"
 )
 
+// Box	Type:	 ‘tfra’
+// Container:	 Movie	Fragment	Random	Access	Box	(‘mfra’)
+// Mandatory:	No
+// Quantity:	 Zero	or	one	per	track
+//
+// Version 0 stores times and moof offsets on 32 bits, version 1 on 64 bits.
+// Traf, trun and sample numbers are stored on LengthSizeOf...Num + 1 bytes.
 type TfraBox struct {
 	Version               byte
 	Flags                 []byte
@@ -19,40 +26,57 @@ type TfraBox struct {
 }
 
 type TfraEntry struct {
-	Time         uint32
-	MoofOffset   uint32
+	Time         uint64
+	MoofOffset   uint64
 	TrafNumber   uint32
 	TrunNumber   uint32
 	SampleNumber uint32
 }
 
 func DecodeTfra(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 16, 16); err != nil {
+		return nil, err
+	}
 	b := &TfraBox{
-		Version:  data[0],
-		Flags:    []byte{data[1], data[2], data[3]},
-		TrackId:  binary.BigEndian.Uint32(data[4:8]),
-		Reserved: 0,
+		Version: data[0],
+		Flags:   []byte{data[1], data[2], data[3]},
+		TrackId: binary.BigEndian.Uint32(data[4:8]),
 	}
 	lengths := binary.BigEndian.Uint32(data[8:12])
-	b.LengthSizeOfTrafNum = (0x30 ^ uint8(lengths)) >> 4
-	b.LengthSizeOfTrunNum = (0x0C ^ uint8(lengths)) >> 2
-	b.LengthSizeOfSampleNum = (0x03 ^ uint8(lengths))
+	b.Reserved = lengths >> 6
+	b.LengthSizeOfTrafNum = uint8(lengths>>4) & 0x03
+	b.LengthSizeOfTrunNum = uint8(lengths>>2) & 0x03
+	b.LengthSizeOfSampleNum = uint8(lengths) & 0x03
 	b.NumberOfTfraEntry = binary.BigEndian.Uint32(data[12:16])
 	offset := 16
+	entrySize := 8 + 3 + int(b.LengthSizeOfTrafNum+b.LengthSizeOfTrunNum+b.LengthSizeOfSampleNum)
+	if b.Version == 1 {
+		entrySize += 8
+	}
+	if err = checkEntries(r, uint64(b.NumberOfTfraEntry), entrySize, offset, data); err != nil {
+		return nil, err
+	}
 	for i := 0; i < int(b.NumberOfTfraEntry); i++ {
-		e := &TfraEntry{
-			Time:         binary.BigEndian.Uint32(data[offset : offset+4]),
-			MoofOffset:   binary.BigEndian.Uint32(data[offset+4 : offset+8]),
-			TrafNumber:   binary.BigEndian.Uint32(data[offset+8 : offset+12]),
-			TrunNumber:   binary.BigEndian.Uint32(data[offset+12 : offset+16]),
-			SampleNumber: binary.BigEndian.Uint32(data[offset+20 : offset+24]),
+		e := &TfraEntry{}
+		if b.Version == 1 {
+			e.Time = binary.BigEndian.Uint64(data[offset : offset+8])
+			e.MoofOffset = binary.BigEndian.Uint64(data[offset+8 : offset+16])
+			offset += 16
+		} else {
+			e.Time = uint64(binary.BigEndian.Uint32(data[offset : offset+4]))
+			e.MoofOffset = uint64(binary.BigEndian.Uint32(data[offset+4 : offset+8]))
+			offset += 8
 		}
-		offset += 24
+		e.TrafNumber = beUintN(data[offset:], b.LengthSizeOfTrafNum+1)
+		offset += int(b.LengthSizeOfTrafNum) + 1
+		e.TrunNumber = beUintN(data[offset:], b.LengthSizeOfTrunNum+1)
+		offset += int(b.LengthSizeOfTrunNum) + 1
+		e.SampleNumber = beUintN(data[offset:], b.LengthSizeOfSampleNum+1)
+		offset += int(b.LengthSizeOfSampleNum) + 1
 		b.Entries = append(b.Entries, e)
 	}
 	return b, nil
@@ -66,13 +90,23 @@ func (b *TfraBox) Type() string {
 	return "tfra"
 }
 
-func (b *TfraBox) Size() int {
-	l := BoxHeaderSize + 32
-	for _, _ = range b.Entries {
-		l = l + 20
+// version returns the version of the box, 1 if a time or an offset does not fit in 32 bits
+func (b *TfraBox) version() byte {
+	for _, e := range b.Entries {
+		if fullBoxVersion(b.Version, e.Time, e.MoofOffset) == 1 {
+			return 1
+		}
 	}
+	return b.Version
+}
 
-	return l
+func (b *TfraBox) Size() int {
+	e := 8
+	if b.version() == 1 {
+		e = 16
+	}
+	e += int(b.LengthSizeOfTrafNum) + int(b.LengthSizeOfTrunNum) + int(b.LengthSizeOfSampleNum) + 3
+	return BoxHeaderSize + 16 + len(b.Entries)*e
 }
 
 func (b *TfraBox) Encode(w io.Writer) error {
@@ -81,9 +115,30 @@ func (b *TfraBox) Encode(w io.Writer) error {
 		return err
 	}
 	buf := makebuf(b)
-	buf[0] = b.Version
+	buf[0] = b.version()
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	// TODO:
+	binary.BigEndian.PutUint32(buf[4:], b.TrackId)
+	lengths := b.Reserved<<6 | uint32(b.LengthSizeOfTrafNum&0x03)<<4 | uint32(b.LengthSizeOfTrunNum&0x03)<<2 | uint32(b.LengthSizeOfSampleNum&0x03)
+	binary.BigEndian.PutUint32(buf[8:], lengths)
+	binary.BigEndian.PutUint32(buf[12:], uint32(len(b.Entries)))
+	offset := 16
+	for _, e := range b.Entries {
+		if b.version() == 1 {
+			binary.BigEndian.PutUint64(buf[offset:], e.Time)
+			binary.BigEndian.PutUint64(buf[offset+8:], e.MoofOffset)
+			offset += 16
+		} else {
+			binary.BigEndian.PutUint32(buf[offset:], uint32(e.Time))
+			binary.BigEndian.PutUint32(buf[offset+4:], uint32(e.MoofOffset))
+			offset += 8
+		}
+		bePutUintN(buf[offset:], b.LengthSizeOfTrafNum+1, e.TrafNumber)
+		offset += int(b.LengthSizeOfTrafNum) + 1
+		bePutUintN(buf[offset:], b.LengthSizeOfTrunNum+1, e.TrunNumber)
+		offset += int(b.LengthSizeOfTrunNum) + 1
+		bePutUintN(buf[offset:], b.LengthSizeOfSampleNum+1, e.SampleNumber)
+		offset += int(b.LengthSizeOfSampleNum) + 1
+	}
 	_, err = w.Write(buf)
 	return err
 }
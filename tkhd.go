@@ -8,7 +8,7 @@ import (
 
 // Track Header Box (tkhd - mandatory)
 //
-// Status : only version 0 is decoded. version 1 is not supported
+// Status : decoded
 //
 // This box describes the track. Duration is measured in time units (according to the time scale
 // defined in the movie header box).
@@ -16,13 +16,15 @@ import (
 // Volume (relevant for audio tracks) is a fixed point number (8 bits + 8 bits). Full volume is 1.0.
 // Width and Height (relevant for video tracks) are fixed point numbers (16 bits + 16 bits).
 // Video pixels are not necessarily square.
+//
+// Version 0 stores times and duration on 32 bits, version 1 on 64 bits.
 type TkhdBox struct {
 	Version          byte
 	Flags            [3]byte
-	CreationTime     uint32
-	ModificationTime uint32
+	CreationTime     uint64
+	ModificationTime uint64
 	TrackId          uint32
-	Duration         uint32
+	Duration         uint64
 	Layer            uint16
 	AlternateGroup   uint16 // should be int16
 	Volume           Fixed16
@@ -31,25 +33,37 @@ type TkhdBox struct {
 }
 
 func DecodeTkhd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
-	return &TkhdBox{
-		Version:          data[0],
-		Flags:            [3]byte{data[1], data[2], data[3]},
-		CreationTime:     binary.BigEndian.Uint32(data[4:8]),
-		ModificationTime: binary.BigEndian.Uint32(data[8:12]),
-		TrackId:          binary.BigEndian.Uint32(data[12:16]),
-		Volume:           fixed16(data[36:38]),
-		Duration:         binary.BigEndian.Uint32(data[20:24]),
-		Layer:            binary.BigEndian.Uint16(data[32:34]),
-		AlternateGroup:   binary.BigEndian.Uint16(data[34:36]),
-		Matrix:           data[40:76],
-		Width:            fixed32(data[76:80]),
-		Height:           fixed32(data[80:84]),
-	}, nil
+	if err = checkFullBoxSize(data, 84, 96); err != nil {
+		return nil, err
+	}
+	b := &TkhdBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	var off int
+	if b.Version == 1 {
+		b.CreationTime = binary.BigEndian.Uint64(data[4:12])
+		b.ModificationTime = binary.BigEndian.Uint64(data[12:20])
+		b.TrackId = binary.BigEndian.Uint32(data[20:24])
+		b.Duration = binary.BigEndian.Uint64(data[28:36])
+		off = 12
+	} else {
+		b.CreationTime = uint64(binary.BigEndian.Uint32(data[4:8]))
+		b.ModificationTime = uint64(binary.BigEndian.Uint32(data[8:12]))
+		b.TrackId = binary.BigEndian.Uint32(data[12:16])
+		b.Duration = uint64(binary.BigEndian.Uint32(data[20:24]))
+	}
+	b.Layer = binary.BigEndian.Uint16(data[off+32 : off+34])
+	b.AlternateGroup = binary.BigEndian.Uint16(data[off+34 : off+36])
+	b.Volume = fixed16(data[off+36 : off+38])
+	b.Matrix = data[off+40 : off+76]
+	b.Width = fixed32(data[off+76 : off+80])
+	b.Height = fixed32(data[off+80 : off+84])
+	return b, nil
 }
 
 func (b *TkhdBox) Box() Box {
@@ -60,7 +74,15 @@ func (b *TkhdBox) Type() string {
 	return "tkhd"
 }
 
+// version returns the version of the box, 1 if a value does not fit in 32 bits
+func (b *TkhdBox) version() byte {
+	return fullBoxVersion(b.Version, b.CreationTime, b.ModificationTime, b.Duration)
+}
+
 func (b *TkhdBox) Size() int {
+	if b.version() == 1 {
+		return BoxHeaderSize + 96
+	}
 	return BoxHeaderSize + 84
 }
 
@@ -70,18 +92,27 @@ func (b *TkhdBox) Encode(w io.Writer) error {
 		return err
 	}
 	buf := makebuf(b)
-	buf[0] = b.Version
+	buf[0] = b.version()
 	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
-	binary.BigEndian.PutUint32(buf[4:], b.CreationTime)
-	binary.BigEndian.PutUint32(buf[8:], b.ModificationTime)
-	binary.BigEndian.PutUint32(buf[12:], b.TrackId)
-	binary.BigEndian.PutUint32(buf[20:], b.Duration)
-	binary.BigEndian.PutUint16(buf[32:], b.Layer)
-	binary.BigEndian.PutUint16(buf[34:], b.AlternateGroup)
-	putFixed16(buf[36:], b.Volume)
-	copy(buf[40:], b.Matrix)
-	putFixed32(buf[76:], b.Width)
-	putFixed32(buf[80:], b.Height)
+	var off int
+	if b.version() == 1 {
+		binary.BigEndian.PutUint64(buf[4:], b.CreationTime)
+		binary.BigEndian.PutUint64(buf[12:], b.ModificationTime)
+		binary.BigEndian.PutUint32(buf[20:], b.TrackId)
+		binary.BigEndian.PutUint64(buf[28:], b.Duration)
+		off = 12
+	} else {
+		binary.BigEndian.PutUint32(buf[4:], uint32(b.CreationTime))
+		binary.BigEndian.PutUint32(buf[8:], uint32(b.ModificationTime))
+		binary.BigEndian.PutUint32(buf[12:], b.TrackId)
+		binary.BigEndian.PutUint32(buf[20:], uint32(b.Duration))
+	}
+	binary.BigEndian.PutUint16(buf[off+32:], b.Layer)
+	binary.BigEndian.PutUint16(buf[off+34:], b.AlternateGroup)
+	putFixed16(buf[off+36:], b.Volume)
+	copy(buf[off+40:], b.Matrix)
+	putFixed32(buf[off+76:], b.Width)
+	putFixed32(buf[off+80:], b.Height)
 	_, err = w.Write(buf)
 	return err
 }
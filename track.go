@@ -0,0 +1,352 @@
+package mp4
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrMissingSampleTable      = errors.New("missing sample table")
+	ErrInconsistentSampleTable = errors.New("inconsistent sample tables")
+	ErrInvalidSampleNumber     = errors.New("invalid sample number")
+)
+
+// Track gives access to the samples of a track (trak box), as defined by its sample tables :
+//
+//   - stts : decode times and durations
+//   - ctts : composition (presentation) time offsets
+//   - stsc, stco/co64 and stsz : file offsets and sizes
+//   - stss : sync samples
+//
+// and by the track runs (trun) of the movie fragments added with AddFragments. A Track must not be used
+// concurrently, the seeking functions caching the bounds of the presentation.
+type Track struct {
+	Trak *TrakBox
+	// Timescale of the movie (mvhd), used to convert the edit list durations. The track timescale is
+	// used when not set.
+	MovieTimescale uint32
+	stbl           *StblBox
+	// number of the sample preceding each stts and ctts entry, and decode time of each stts entry, for the
+	// binary searches
+	sttsFirst []uint32
+	sttsTime  []uint64
+	cttsFirst []uint32
+	bounds    *presentationBounds // computed by the seeking functions
+	// fragments
+	runs               []fragmentRun
+	fragmentSamples    uint32
+	fragmentDecodeTime uint64 // of the sample following the last sample
+}
+
+// SampleInfo describes a sample of a track. Times are in track time units (see Track.Timescale).
+type SampleInfo struct {
+	Number           uint32 // starting at 1
+	DecodeTime       uint64
+	PresentationTime int64
+	Duration         uint32
+	Offset           uint64 // from the beginning of the file
+	Size             uint32
+	DescriptionIndex uint32 // index of the sample entry in stsd, starting at 1
+	Chunk            uint32 // starting at 1, 0 for the samples of the fragments
+	IsSync           bool
+}
+
+// NewTrack returns a Track for trak, or ErrMissingSampleTable if a mandatory sample table is missing
+func NewTrack(trak *TrakBox) (*Track, error) {
+	if trak == nil || trak.Mdia == nil || trak.Mdia.Mdhd == nil || trak.Mdia.Minf == nil || trak.Mdia.Minf.Stbl == nil {
+		return nil, ErrMissingSampleTable
+	}
+	stbl := trak.Mdia.Minf.Stbl
+	if stbl.Stts == nil || stbl.Stsc == nil || stbl.Stsz == nil || (stbl.Stco == nil && stbl.Co64 == nil) {
+		return nil, ErrMissingSampleTable
+	}
+	t := &Track{Trak: trak, stbl: stbl}
+	var first uint32
+	for i, count := range stbl.Stts.SampleCount {
+		t.sttsFirst = append(t.sttsFirst, first)
+		t.sttsTime = append(t.sttsTime, t.fragmentDecodeTime)
+		first += count
+		// the fragments follow the samples of the sample tables
+		t.fragmentDecodeTime += uint64(count) * uint64(stbl.Stts.SampleTimeDelta[i])
+	}
+	if stbl.Ctts != nil {
+		first = 0
+		for _, count := range stbl.Ctts.SampleCount {
+			t.cttsFirst = append(t.cttsFirst, first)
+			first += count
+		}
+	}
+	return t, nil
+}
+
+// ID returns the track ID
+func (t *Track) ID() uint32 {
+	if t.Trak.Tkhd == nil {
+		return 0
+	}
+	return t.Trak.Tkhd.TrackId
+}
+
+// Timescale returns the number of time units per second of the track
+func (t *Track) Timescale() uint32 {
+	return t.Trak.Mdia.Mdhd.Timescale
+}
+
+// SampleCount returns the number of samples of the track, fragments included
+func (t *Track) SampleCount() uint32 {
+	return t.stblSampleCount() + t.fragmentSamples
+}
+
+// stblSampleCount returns the number of samples defined by the sample tables
+func (t *Track) stblSampleCount() uint32 {
+	if len(t.stbl.Stsz.SampleSize) > 0 {
+		return uint32(len(t.stbl.Stsz.SampleSize))
+	}
+	return t.stbl.Stsz.SampleNumber
+}
+
+func (t *Track) chunkCount() uint32 {
+	if t.stbl.Co64 != nil {
+		return uint32(len(t.stbl.Co64.ChunkOffset))
+	}
+	return uint32(len(t.stbl.Stco.ChunkOffset))
+}
+
+// chunkOffset returns the offset of a chunk (starting at 1)
+func (t *Track) chunkOffset(chunk uint32) uint64 {
+	if t.stbl.Co64 != nil {
+		return t.stbl.Co64.ChunkOffset[chunk-1]
+	}
+	return uint64(t.stbl.Stco.ChunkOffset[chunk-1])
+}
+
+func (t *Track) sampleSize(number uint32) uint32 {
+	if len(t.stbl.Stsz.SampleSize) == 0 {
+		return t.stbl.Stsz.SampleUniformSize
+	}
+	return t.stbl.Stsz.SampleSize[number-1]
+}
+
+// Sample returns the sample n (starting at 1), without iterating over the previous samples
+func (t *Track) Sample(n uint32) (SampleInfo, error) {
+	if n == 0 || n > t.SampleCount() {
+		return SampleInfo{}, ErrInvalidSampleNumber
+	}
+	if n > t.stblSampleCount() {
+		return t.fragmentSample(n)
+	}
+	stbl := t.stbl
+	s := SampleInfo{Number: n, IsSync: true}
+
+	// Decode time and duration
+	i := sort.Search(len(t.sttsFirst), func(i int) bool { return t.sttsFirst[i] >= n }) - 1
+	if i < 0 || n-t.sttsFirst[i] > stbl.Stts.SampleCount[i] {
+		return SampleInfo{}, ErrInconsistentSampleTable
+	}
+	s.Duration = stbl.Stts.SampleTimeDelta[i]
+	s.DecodeTime = t.sttsTime[i] + uint64(n-1-t.sttsFirst[i])*uint64(s.Duration)
+
+	// Composition offset
+	s.PresentationTime = int64(s.DecodeTime)
+	if stbl.Ctts != nil {
+		i = sort.Search(len(t.cttsFirst), func(i int) bool { return t.cttsFirst[i] >= n }) - 1
+		if i < 0 || n-t.cttsFirst[i] > stbl.Ctts.SampleCount[i] {
+			return SampleInfo{}, ErrInconsistentSampleTable
+		}
+		s.PresentationTime += int64(stbl.Ctts.SampleOffset[i])
+	}
+
+	// Chunk and offset
+	stsc := stbl.Stsc
+	chunkCount := t.chunkCount()
+	first := uint64(1) // first sample of the current stsc entry
+	found := false
+	for i, fc := range stsc.FirstChunk {
+		last := chunkCount
+		if i+1 < len(stsc.FirstChunk) && stsc.FirstChunk[i+1]-1 < last {
+			last = stsc.FirstChunk[i+1] - 1
+		}
+		if fc == 0 || last < fc {
+			continue
+		}
+		spc := uint64(stsc.SamplesPerChunk[i])
+		count := uint64(last-fc+1) * spc
+		if uint64(n) < first+count {
+			k := (uint64(n) - first) / spc
+			s.Chunk = fc + uint32(k)
+			s.DescriptionIndex = stsc.SampleDescriptionID[i]
+			s.Offset = t.chunkOffset(s.Chunk)
+			j := uint32(first + k*spc)
+			if len(stbl.Stsz.SampleSize) == 0 {
+				s.Offset += uint64(n-j) * uint64(stbl.Stsz.SampleUniformSize)
+				j = n
+			}
+			for ; j < n; j++ {
+				s.Offset += uint64(t.sampleSize(j))
+			}
+			found = true
+			break
+		}
+		first += count
+	}
+	if !found {
+		return SampleInfo{}, ErrInconsistentSampleTable
+	}
+	s.Size = t.sampleSize(n)
+
+	// Sync samples
+	if stbl.Stss != nil {
+		l := stbl.Stss.SampleNumber
+		i := sort.Search(len(l), func(i int) bool { return l[i] >= n })
+		s.IsSync = i < len(l) && l[i] == n
+	}
+	return s, nil
+}
+
+// Samples returns an iterator over the samples of the track, in decoding order, fragments included :
+//
+//	it := t.Samples()
+//	for it.Next() {
+//		s := it.Sample()
+//		...
+//	}
+//	if it.Err() != nil {
+//		...
+//	}
+func (t *Track) Samples() *SampleIterator {
+	return &SampleIterator{t: t}
+}
+
+// SampleIterator iterates over the samples of a track, walking all the sample tables at once
+type SampleIterator struct {
+	t      *Track
+	sample SampleInfo
+	err    error
+	// stts
+	sttsIndex int
+	sttsLeft  uint32
+	delta     uint32
+	dts       uint64
+	// ctts
+	cttsIndex int
+	cttsLeft  uint32
+	ctsOffset int32
+	// stsc
+	stscIndex int
+	chunk     uint32
+	chunkLeft uint32
+	offset    uint64
+	// stss
+	stssIndex int
+	// fragments
+	runIndex  int
+	runSample int
+	fdts      uint64
+	foffset   uint64
+}
+
+// Next moves to the next sample, and returns false at the end of the track or if an error occurred
+func (it *SampleIterator) Next() bool {
+	if it.err != nil || it.sample.Number >= it.t.SampleCount() {
+		return false
+	}
+	stbl := it.t.stbl
+	n := it.sample.Number + 1
+	if n > it.t.stblSampleCount() {
+		it.nextFragmentSample()
+		return true
+	}
+
+	// Decode time and duration
+	for it.sttsLeft == 0 {
+		if it.sttsIndex >= len(stbl.Stts.SampleCount) {
+			it.err = ErrInconsistentSampleTable
+			return false
+		}
+		it.sttsLeft = stbl.Stts.SampleCount[it.sttsIndex]
+		it.delta = stbl.Stts.SampleTimeDelta[it.sttsIndex]
+		it.sttsIndex++
+	}
+	it.sttsLeft--
+
+	// Composition offset
+	if stbl.Ctts != nil {
+		for it.cttsLeft == 0 {
+			if it.cttsIndex >= len(stbl.Ctts.SampleCount) {
+				it.err = ErrInconsistentSampleTable
+				return false
+			}
+			it.cttsLeft = stbl.Ctts.SampleCount[it.cttsIndex]
+			it.ctsOffset = stbl.Ctts.SampleOffset[it.cttsIndex]
+			it.cttsIndex++
+		}
+		it.cttsLeft--
+	}
+
+	// Chunk and offset
+	stsc := stbl.Stsc
+	for it.chunkLeft == 0 {
+		it.chunk++
+		if it.chunk > it.t.chunkCount() || len(stsc.FirstChunk) == 0 || it.chunk < stsc.FirstChunk[0] {
+			it.err = ErrInconsistentSampleTable
+			return false
+		}
+		for it.stscIndex+1 < len(stsc.FirstChunk) && it.chunk >= stsc.FirstChunk[it.stscIndex+1] {
+			it.stscIndex++
+		}
+		it.chunkLeft = stsc.SamplesPerChunk[it.stscIndex]
+		it.offset = it.t.chunkOffset(it.chunk)
+	}
+	it.chunkLeft--
+
+	// Sync samples
+	sync := true
+	if stbl.Stss != nil {
+		for it.stssIndex < len(stbl.Stss.SampleNumber) && stbl.Stss.SampleNumber[it.stssIndex] < n {
+			it.stssIndex++
+		}
+		sync = it.stssIndex < len(stbl.Stss.SampleNumber) && stbl.Stss.SampleNumber[it.stssIndex] == n
+	}
+
+	it.sample = SampleInfo{
+		Number:           n,
+		DecodeTime:       it.dts,
+		PresentationTime: int64(it.dts) + int64(it.ctsOffset),
+		Duration:         it.delta,
+		Offset:           it.offset,
+		Size:             it.t.sampleSize(n),
+		DescriptionIndex: stsc.SampleDescriptionID[it.stscIndex],
+		Chunk:            it.chunk,
+		IsSync:           sync,
+	}
+	it.dts += uint64(it.delta)
+	it.offset += uint64(it.sample.Size)
+	return true
+}
+
+// nextFragmentSample moves to the next sample of the fragments
+func (it *SampleIterator) nextFragmentSample() {
+	r := &it.t.runs[it.runIndex]
+	if it.runSample == len(r.trun.Samples) {
+		it.runIndex++
+		it.runSample = 0
+		r = &it.t.runs[it.runIndex]
+	}
+	if it.runSample == 0 {
+		it.fdts, it.foffset = r.decodeTime, r.offset
+	}
+	it.sample = r.sample(it.runSample, it.foffset, it.fdts)
+	it.fdts += uint64(it.sample.Duration)
+	it.foffset += uint64(it.sample.Size)
+	it.runSample++
+}
+
+// Sample returns the current sample
+func (it *SampleIterator) Sample() SampleInfo {
+	return it.sample
+}
+
+// Err returns the error that stopped the iteration, nil if the end of the track was reached
+func (it *SampleIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,106 @@
+package mp4
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSampleIterator(t *testing.T) {
+	// 3 chunks of 2, 2 and 1 samples, the last one with another sample description
+	stbl := &StblBox{
+		Stts: &SttsBox{SampleCount: []uint32{2, 3}, SampleTimeDelta: []uint32{100, 50}},
+		Ctts: &CttsBox{SampleCount: []uint32{1, 1, 3}, SampleOffset: []int32{0, 200, 50}},
+		Stsc: &StscBox{FirstChunk: []uint32{1, 3}, SamplesPerChunk: []uint32{2, 1}, SampleDescriptionID: []uint32{1, 2}},
+		Stsz: &StszBox{SampleSize: []uint32{10, 20, 30, 40, 50}, SampleNumber: 5},
+		Stco: &StcoBox{ChunkOffset: []uint32{100, 200, 300}},
+		Stss: &StssBox{SampleNumber: []uint32{1, 4}},
+	}
+	trak := &TrakBox{
+		Tkhd: &TkhdBox{TrackId: 1},
+		Mdia: &MdiaBox{Mdhd: &MdhdBox{Timescale: 1000}, Minf: &MinfBox{Stbl: stbl}},
+	}
+	want := []SampleInfo{
+		{Number: 1, DecodeTime: 0, PresentationTime: 0, Duration: 100, Offset: 100, Size: 10, DescriptionIndex: 1, Chunk: 1, IsSync: true},
+		{Number: 2, DecodeTime: 100, PresentationTime: 300, Duration: 100, Offset: 110, Size: 20, DescriptionIndex: 1, Chunk: 1},
+		{Number: 3, DecodeTime: 200, PresentationTime: 250, Duration: 50, Offset: 200, Size: 30, DescriptionIndex: 1, Chunk: 2},
+		{Number: 4, DecodeTime: 250, PresentationTime: 300, Duration: 50, Offset: 230, Size: 40, DescriptionIndex: 1, Chunk: 2, IsSync: true},
+		{Number: 5, DecodeTime: 300, PresentationTime: 350, Duration: 50, Offset: 300, Size: 50, DescriptionIndex: 2, Chunk: 3},
+	}
+	tr, err := NewTrack(trak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []SampleInfo{}
+	it := tr.Samples()
+	for it.Next() {
+		got = append(got, it.Sample())
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample %d: got %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+
+	// the time to sample table describes only 4 samples
+	stbl.Stts = &SttsBox{SampleCount: []uint32{4}, SampleTimeDelta: []uint32{100}}
+	it = tr.Samples()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 4 || it.Err() != ErrInconsistentSampleTable {
+		t.Errorf("truncated stts: got %d samples and %v, want 4 samples and %v", n, it.Err(), ErrInconsistentSampleTable)
+	}
+
+	stbl.Stsz = nil
+	if _, err := NewTrack(trak); err != ErrMissingSampleTable {
+		t.Errorf("missing stsz: got %v, want %v", err, ErrMissingSampleTable)
+	}
+}
+
+func TestSampleIteratorMedia(t *testing.T) {
+	data, err := os.ReadFile("sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trak := range m.Moov.Trak {
+		tr, err := NewTrack(trak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count uint32
+		var duration uint64
+		it := tr.Samples()
+		for it.Next() {
+			s := it.Sample()
+			count++
+			duration += uint64(s.Duration)
+			if s.Number != count {
+				t.Errorf("track %d: sample %d numbered %d", tr.ID(), count, s.Number)
+			}
+			if s.Offset+uint64(s.Size) > uint64(len(data)) {
+				t.Errorf("track %d: sample %d ends at %d, after the end of the file", tr.ID(), s.Number, s.Offset+uint64(s.Size))
+			}
+		}
+		if it.Err() != nil {
+			t.Fatalf("track %d: %v", tr.ID(), it.Err())
+		}
+		if count != tr.SampleCount() {
+			t.Errorf("track %d: got %d samples, want %d", tr.ID(), count, tr.SampleCount())
+		}
+		if duration != trak.Mdia.Mdhd.Duration {
+			t.Errorf("track %d: got a duration of %d, want %d", tr.ID(), duration, trak.Mdia.Mdhd.Duration)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package mp4
+
+import (
+	"sort"
+)
+
+// Sample flags (trex, tfhd and trun)
+const (
+	SampleIsNonSyncSample = 0x00010000
+)
+
+// fragmentRun holds a track run (trun) of a movie fragment, with its defaults resolved from tfhd and trex
+type fragmentRun struct {
+	trun             *TrunBox
+	first            uint32 // number of the first sample of the run
+	decodeTime       uint64 // of the first sample
+	offset           uint64 // of the first sample, from the beginning of the file
+	descriptionIndex uint32
+	defaultDuration  uint32
+	defaultSize      uint32
+	defaultFlags     uint32
+}
+
+func (r *fragmentRun) duration(i int) uint32 {
+	if compareFlag(BEUint28(r.trun.Flags[:]), SampleDurationPresent) {
+		return r.trun.Samples[i].SampleDuration
+	}
+	return r.defaultDuration
+}
+
+func (r *fragmentRun) size(i int) uint32 {
+	if compareFlag(BEUint28(r.trun.Flags[:]), SampleSizePresent) {
+		return r.trun.Samples[i].SampleSize
+	}
+	return r.defaultSize
+}
+
+func (r *fragmentRun) flags(i int) uint32 {
+	flags := BEUint28(r.trun.Flags[:])
+	if i == 0 && compareFlag(flags, FirstSampleFlagsPresent) {
+		return r.trun.FirstSampleFlags
+	}
+	if compareFlag(flags, SampleFlagsPresent) {
+		return r.trun.Samples[i].SampleFlags
+	}
+	return r.defaultFlags
+}
+
+// compositionOffset returns the composition time offset of the sample i, signed in version 1
+func (r *fragmentRun) compositionOffset(i int) int64 {
+	if !compareFlag(BEUint28(r.trun.Flags[:]), SampleCompositionTimeOffsetsPresent) {
+		return 0
+	}
+	if r.trun.Version == 1 {
+		return int64(int32(r.trun.Samples[i].SampleCompositionTimeOffset))
+	}
+	return int64(r.trun.Samples[i].SampleCompositionTimeOffset)
+}
+
+// sample returns the sample i of the run, offset and decodeTime being the ones of the sample
+func (r *fragmentRun) sample(i int, offset, decodeTime uint64) SampleInfo {
+	return SampleInfo{
+		Number:           r.first + uint32(i),
+		DecodeTime:       decodeTime,
+		PresentationTime: int64(decodeTime) + r.compositionOffset(i),
+		Duration:         r.duration(i),
+		Offset:           offset,
+		Size:             r.size(i),
+		DescriptionIndex: r.descriptionIndex,
+		IsSync:           r.flags(i)&SampleIsNonSyncSample == 0,
+	}
+}
+
+// AddFragments appends the samples of movie fragments (moof) to the track. The samples are numbered after
+// the ones of the sample tables, in the order of the fragments.
+//
+// mvex holds the defaults of the tracks (trex), it can be nil if tfhd or trun define all the sample
+// fields. The defaults of the other tracks are needed to locate the data of the track fragments that
+// follow theirs. The sample offsets are computed from the offsets of the moof boxes set by Decode : for a
+// media split in segments, they are relative to the segment.
+func (t *Track) AddFragments(mvex *MvexBox, moof ...*MoofBox) error {
+	dts := t.fragmentDecodeTime
+	n := t.SampleCount() + 1
+	for _, m := range moof {
+		next := uint64(m.offset) // end of the data of the previous track fragment
+		for _, traf := range m.Traf {
+			if traf.Tfhd == nil {
+				return ErrBadFormat
+			}
+			tfhd := traf.Tfhd
+			mine := tfhd.TrackId == t.ID()
+			flags := BEUint28(tfhd.Flags[:])
+			r := fragmentRun{}
+			if trex := mvex.TrackExtends(tfhd.TrackId); trex != nil {
+				r.descriptionIndex = trex.SampleDescriptionIndex
+				r.defaultDuration = trex.SampleDuration
+				r.defaultSize = trex.SampleSize
+				r.defaultFlags = trex.SampleFlags
+			}
+			if compareFlag(flags, SampleDescriptionIndexPresent) {
+				r.descriptionIndex = tfhd.SampleDescriptionIndex
+			}
+			if compareFlag(flags, DefaultSampleDurationPresent) {
+				r.defaultDuration = tfhd.DefaultSampleDuration
+			}
+			if compareFlag(flags, DefaultSampleSizePresent) {
+				r.defaultSize = tfhd.DefaultSampleSize
+			}
+			if compareFlag(flags, DefaultSampleFlagsPresent) {
+				r.defaultFlags = tfhd.DefaultSampleFlags
+			}
+			base := next
+			if compareFlag(flags, BaseDataOffsetPresent) {
+				base = tfhd.BaseDataOffset
+			} else if compareFlag(flags, DefaultBaseIsMoof) {
+				base = uint64(m.offset)
+			}
+			if mine && traf.Tfdt != nil {
+				dts = traf.Tfdt.BaseMediaDecodeTime
+			}
+			off := base
+			for _, trun := range traf.Trun {
+				if compareFlag(BEUint28(trun.Flags[:]), DataOffsetPresent) {
+					off = uint64(int64(base) + int64(trun.DataOffset))
+				}
+				r.trun = trun
+				r.first = n
+				r.decodeTime = dts
+				r.offset = off
+				for i := range trun.Samples {
+					off += uint64(r.size(i))
+					if mine {
+						dts += uint64(r.duration(i))
+					}
+				}
+				if mine && len(trun.Samples) > 0 {
+					t.runs = append(t.runs, r)
+					n += uint32(len(trun.Samples))
+				}
+			}
+			next = off
+		}
+	}
+	t.fragmentDecodeTime = dts
+	t.bounds = nil
+	t.fragmentSamples = n - 1 - t.stblSampleCount()
+	return nil
+}
+
+// fragmentSample returns the sample n, stored in a fragment
+func (t *Track) fragmentSample(n uint32) (SampleInfo, error) {
+	i := sort.Search(len(t.runs), func(i int) bool { return t.runs[i].first > n }) - 1
+	if i < 0 {
+		return SampleInfo{}, ErrInvalidSampleNumber
+	}
+	r := &t.runs[i]
+	off, dts := r.offset, r.decodeTime
+	for j := 0; j < int(n-r.first); j++ {
+		off += uint64(r.size(j))
+		dts += uint64(r.duration(j))
+	}
+	return r.sample(int(n-r.first), off, dts), nil
+}
@@ -0,0 +1,39 @@
+package mp4
+
+import (
+	"testing"
+)
+
+func TestAddFragmentsOtherTrackDefaults(t *testing.T) {
+	mvex := &MvexBox{Trex: []*TrexBox{
+		{TrackId: 1, SampleDescriptionIndex: 1, SampleDuration: 10, SampleSize: 20},
+		{TrackId: 2, SampleDescriptionIndex: 1, SampleDuration: 10, SampleSize: 50},
+	}}
+	// the samples of the track 2 come first, their sizes being the defaults of trex
+	moof := &MoofBox{
+		Traf: []*TrafBox{
+			{
+				Tfhd: &TfhdBox{TrackId: 2},
+				Trun: []*TrunBox{{Flags: [3]byte{0, 0, DataOffsetPresent}, DataOffset: 200, Samples: []*Sample{{}, {}, {}}}},
+			},
+			{
+				Tfhd: &TfhdBox{TrackId: 1},
+				Trun: []*TrunBox{{Samples: []*Sample{{}, {}}}},
+			},
+		},
+		offset: 1000,
+	}
+	tr := testTrack(t, 0, nil, nil, nil)
+	if err := tr.AddFragments(mvex, moof); err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range []uint64{1350, 1370} {
+		s, err := tr.Sample(uint32(n + 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Offset != want || s.Size != 20 || s.Duration != 10 {
+			t.Errorf("sample %d: offset %d, size %d, duration %d, want offset %d", n+1, s.Offset, s.Size, s.Duration, want)
+		}
+	}
+}
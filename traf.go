@@ -20,6 +20,7 @@ type TrafBox struct {
 	Tfdt  *TfdtBox `json:"tfdt,omitempty"`
 	Meta  *MetaBox `json:"meta,omitempty"`
 	Boxes []Box    `json:",omitempty"`
+	order []string
 }
 
 func (b *TrafBox) Box() Box {
@@ -30,17 +31,39 @@ func (b *TrafBox) Type() string {
 	return "traf"
 }
 
+// boxes returns the boxes of the traf box, in the decoding order
+func (b *TrafBox) boxes() []Box {
+	l := []Box{}
+	if b.Tfhd != nil {
+		l = append(l, b.Tfhd)
+	}
+	if b.Tfdt != nil {
+		l = append(l, b.Tfdt)
+	}
+	for _, t := range b.Trun {
+		l = append(l, t)
+	}
+	if b.Sgpd != nil {
+		l = append(l, b.Sgpd)
+	}
+	if b.Meta != nil {
+		l = append(l, b.Meta)
+	}
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
 func (b *TrafBox) Size() int {
-	l := BoxHeaderSize
-	return l
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
+
 func (b *TrafBox) Encode(w io.Writer) error {
 	err := EncodeHeader(b, w)
 	if err != nil {
 		return err
 	}
-	return err
+	return encodeBoxes(b.boxes(), w)
 }
+
 func (b *TrafBox) Dump() {
 	fmt.Printf("Track Fragment Box\n")
 	if b.Tfhd != nil {
@@ -55,6 +78,9 @@ func (b *TrafBox) Dump() {
 	if b.Meta != nil {
 		b.Meta.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func DecodeTraf(h BoxHeader, r io.Reader) (Box, error) {
@@ -62,8 +88,12 @@ func DecodeTraf(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	t := &TrafBox{}
+	t := &TrafBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			t.Boxes = append(t.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "sgpd":
 			t.Sgpd = b.(*SgpdBox)
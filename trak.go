@@ -15,6 +15,7 @@ type TrakBox struct {
 	Edts  *EdtsBox `json:"edts,omitempty"`
 	Meta  *MetaBox `json:"meta,omitempty"`
 	Boxes []Box    `json:",omitempty"`
+	order []string
 }
 
 func DecodeTrak(h BoxHeader, r io.Reader) (Box, error) {
@@ -22,8 +23,12 @@ func DecodeTrak(h BoxHeader, r io.Reader) (Box, error) {
 	if err != nil {
 		return nil, err
 	}
-	t := &TrakBox{}
+	t := &TrakBox{order: boxTypes(l)}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			t.Boxes = append(t.Boxes, b)
+			continue
+		}
 		switch b.Type() {
 		case "tkhd":
 			t.Tkhd = b.(*TkhdBox)
@@ -48,16 +53,26 @@ func (b *TrakBox) Type() string {
 	return "trak"
 }
 
-func (b *TrakBox) Size() int {
-	sz := b.Tkhd.Size()
-	sz += b.Mdia.Size()
+// boxes returns the boxes of the trak box, in the decoding order
+func (b *TrakBox) boxes() []Box {
+	l := []Box{}
+	if b.Tkhd != nil {
+		l = append(l, b.Tkhd)
+	}
 	if b.Edts != nil {
-		sz += b.Edts.Size()
+		l = append(l, b.Edts)
+	}
+	if b.Mdia != nil {
+		l = append(l, b.Mdia)
 	}
 	if b.Meta != nil {
-		sz += b.Meta.Size()
+		l = append(l, b.Meta)
 	}
-	return sz + BoxHeaderSize
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *TrakBox) Size() int {
+	return BoxHeaderSize + boxesSize(b.boxes())
 }
 
 func (b *TrakBox) Dump() {
@@ -69,6 +84,9 @@ func (b *TrakBox) Dump() {
 	if b.Meta != nil {
 		b.Meta.Dump()
 	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
 }
 
 func (b *TrakBox) Encode(w io.Writer) error {
@@ -76,18 +94,5 @@ func (b *TrakBox) Encode(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	err = b.Tkhd.Encode(w)
-	if err != nil {
-		return err
-	}
-	if b.Edts != nil {
-		err = b.Edts.Encode(w)
-		if err != nil {
-			return err
-		}
-	}
-	if b.Meta != nil {
-		b.Meta.Encode(w)
-	}
-	return b.Mdia.Encode(w)
+	return encodeBoxes(b.boxes(), w)
 }
@@ -17,11 +17,13 @@ type TrexBox struct {
 }
 
 func DecodeTrex(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 24, 24); err != nil {
+		return nil, err
+	}
 	return &TrexBox{
 		Version:                data[0],
 		Flags:                  [3]byte{data[1], data[2], data[3]},
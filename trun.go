@@ -44,20 +44,26 @@ func (b *TrunBox) Type() string {
 }
 
 func (b *TrunBox) Size() int {
-	l := BoxHeaderSize + 12
+	l := BoxHeaderSize + 8
 	flag := BEUint28(b.Flags[:])
-	for _, _ = range b.Samples {
+	if compareFlag(flag, DataOffsetPresent) {
+		l = l + 4
+	}
+	if compareFlag(flag, FirstSampleFlagsPresent) {
+		l = l + 4
+	}
+	for range b.Samples {
 		if compareFlag(flag, SampleDurationPresent) {
-			l = l + 8
+			l = l + 4
 		}
 		if compareFlag(flag, SampleSizePresent) {
-			l = l + 8
+			l = l + 4
 		}
 		if compareFlag(flag, SampleFlagsPresent) {
-			l = l + 8
+			l = l + 4
 		}
 		if compareFlag(flag, SampleCompositionTimeOffsetsPresent) {
-			l = l + 8
+			l = l + 4
 		}
 	}
 	return l
@@ -76,30 +82,31 @@ func (b *TrunBox) Encode(w io.Writer) error {
 	flag := BEUint28(b.Flags[:])
 	if compareFlag(flag, DataOffsetPresent) {
 		binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], uint32(b.DataOffset))
-		startOffset = startOffset + 8
+		startOffset = startOffset + 4
 	}
 	if compareFlag(flag, FirstSampleFlagsPresent) {
 		binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], b.FirstSampleFlags)
-		startOffset = startOffset + 8
+		startOffset = startOffset + 4
 	}
 	for _, s := range b.Samples {
 		if compareFlag(flag, SampleDurationPresent) {
 			binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], s.SampleDuration)
-			startOffset = startOffset + 8
+			startOffset = startOffset + 4
 		}
 		if compareFlag(flag, SampleSizePresent) {
 			binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], s.SampleSize)
-			startOffset = startOffset + 8
+			startOffset = startOffset + 4
 		}
 		if compareFlag(flag, SampleFlagsPresent) {
 			binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], s.SampleFlags)
-			startOffset = startOffset + 8
+			startOffset = startOffset + 4
 		}
 		if compareFlag(flag, SampleCompositionTimeOffsetsPresent) {
 			binary.BigEndian.PutUint32(buf[startOffset:startOffset+4], s.SampleCompositionTimeOffset)
-			startOffset = startOffset + 8
+			startOffset = startOffset + 4
 		}
 	}
+	_, err = w.Write(buf)
 	return err
 }
 func (b *TrunBox) Dump() {
@@ -110,11 +117,28 @@ func (b *TrunBox) Dump() {
 }
 
 func DecodeTrun(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 8, 8); err != nil {
+		return nil, err
+	}
+	size, sampleSize := 8, 0
+	for _, f := range []uint32{DataOffsetPresent, FirstSampleFlagsPresent} {
+		if compareFlag(BEUint28(data[1:4]), f) {
+			size += 4
+		}
+	}
+	for _, f := range []uint32{SampleDurationPresent, SampleSizePresent, SampleFlagsPresent,
+		SampleCompositionTimeOffsetsPresent} {
+		if compareFlag(BEUint28(data[1:4]), f) {
+			sampleSize += 4
+		}
+	}
+	if err = checkEntries(r, uint64(binary.BigEndian.Uint32(data[4:8])), sampleSize, size, data); err != nil {
+		return nil, err
+	}
 	b := &TrunBox{
 		Version:     data[0],
 		Flags:       [3]byte{data[1], data[2], data[3]},
@@ -3,7 +3,6 @@ package mp4
 import (
 	"fmt"
 	"io"
-	"log"
 )
 
 // User Data Box (udta - optional)
@@ -20,13 +19,12 @@ type UdtaBox struct {
 
 //FIXME: Udta can contain several boxes, also actual user data
 func DecodeUdta(h BoxHeader, r io.Reader) (Box, error) {
-	if h.Size-BoxHeaderSize < BoxHeaderSize {
-		data := make([]byte, h.Size-BoxHeaderSize)
-		_, err := r.Read(data)
+	if h.Size != 0 && h.boxSize()-h.headerSize() < BoxHeaderSize {
+		data, err := readBoxData(h, r)
 		if err != nil {
 			return nil, err
 		}
-		log.Printf("udta Dump: %s", data)
+		Logger().Debug("udta not decoded", "data", data)
 		return &UdtaBox{
 			notDecoded: data,
 		}, nil
@@ -38,6 +36,10 @@ func DecodeUdta(h BoxHeader, r io.Reader) (Box, error) {
 	}
 	u := &UdtaBox{}
 	for _, b := range l {
+		if _, ok := b.(*RawBox); ok {
+			// as the unknown boxes
+			continue
+		}
 		switch b.Type() {
 		case "meta":
 			u.Meta = b.(*MetaBox)
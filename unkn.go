@@ -5,6 +5,11 @@ import (
 	"io"
 )
 
+// Unknown Box
+//
+// Status: not decoded
+//
+// Boxes without decoder are kept as is, and encoded without change.
 type UkwnBox struct {
 	Header      BoxHeader
 	PayloadSize uint32
@@ -12,19 +17,14 @@ type UkwnBox struct {
 }
 
 func DecodeUkwnBox(h BoxHeader, r io.Reader) (Box, error) {
-	if lr, limited := r.(*io.LimitedReader); limited {
-		r = lr.R
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
 	}
-	data := make([]byte, h.Size-BoxHeaderSize)
-	n, _ := r.Read(data)
-
 	b := &UkwnBox{
-		Header: BoxHeader{
-			Size: h.Size,
-			Type: h.Type,
-		},
+		Header:      h,
 		Data:        data,
-		PayloadSize: uint32(n),
+		PayloadSize: uint32(len(data)),
 	}
 	return b, nil
 }
@@ -34,7 +34,11 @@ func (b *UkwnBox) Type() string {
 }
 
 func (b *UkwnBox) Size() int {
-	return int(b.PayloadSize)
+	return sizeWithHeader(b.Header.Size == 1, uint64(len(b.Data)))
+}
+
+func (b *UkwnBox) largeHeader() bool {
+	return b.Header.Size == 1
 }
 
 func (b *UkwnBox) Dump() {
@@ -42,7 +46,12 @@ func (b *UkwnBox) Dump() {
 	fmt.Printf(" Data length: %d\n", len(b.Data))
 }
 func (b *UkwnBox) Encode(w io.Writer) error {
-	return nil
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.Data)
+	return err
 }
 
 func (b *UkwnBox) Box() Box {
@@ -0,0 +1,148 @@
+package validate
+
+import (
+	"github.com/otherplace/mp4"
+)
+
+// inside returns true if the sample is inside a span
+func inside(spans []mp4.MdatSpan, s mp4.SampleInfo) bool {
+	for _, sp := range spans {
+		if s.Offset >= sp.Start && s.Offset+uint64(s.Size) <= sp.End {
+			return true
+		}
+	}
+	return false
+}
+
+// chunks checks that the samples of the chunks of a track are inside the mdat boxes
+func (v *validator) chunks(p string, trak *mp4.TrakBox, spans []mp4.MdatSpan) {
+	t, err := mp4.NewTrack(trak)
+	if err != nil || t.SampleCount() == 0 {
+		return
+	}
+	outside := 0
+	var first mp4.SampleInfo
+	it := t.Samples()
+	for it.Next() {
+		s := it.Sample()
+		if !inside(spans, s) {
+			if outside == 0 {
+				first = s
+			}
+			outside++
+		}
+	}
+	// the errors of the iterator are the ones of the sample tables
+	if outside > 0 {
+		v.add(RuleChunkOffset, Error, p+"/mdia/minf/stbl", "%d samples outside of the mdat boxes, the first one being sample %d (chunk %d at offset %d)",
+			outside, first.Number, first.Chunk, first.Offset)
+	}
+}
+
+// fragments checks the movie fragments of a media
+func (v *validator) fragments(moofs []*mp4.MoofBox, spans []mp4.MdatSpan) {
+	for k, moof := range moofs {
+		p := path("", "moof", k)
+		if moof.Mfhd == nil {
+			v.add(RuleMissingBox, Error, p, "no mfhd box")
+		} else {
+			n := moof.Mfhd.SequenceNumber
+			if n <= v.sequence {
+				v.add(RuleFragmentSequence, Error, p+"/mfhd", "sequence number %d, following %d", n, v.sequence)
+			}
+			v.sequence = n
+		}
+		// the track fragments of each track, in the order of the tracks in the moof box
+		ids, trafs := []uint32{}, map[uint32][]int{}
+		for j, traf := range moof.Traf {
+			if traf.Tfhd == nil {
+				v.add(RuleMissingBox, Error, path(p, "traf", j), "no tfhd box")
+				continue
+			}
+			id := traf.Tfhd.TrackId
+			if _, ok := trafs[id]; !ok {
+				ids = append(ids, id)
+			}
+			trafs[id] = append(trafs[id], j)
+		}
+		for _, id := range ids {
+			trak := v.track(id)
+			if trak == nil {
+				v.add(RuleMissingBox, Error, path(p, "traf", trafs[id][0])+"/tfhd", "no track %d in the moov box", id)
+				continue
+			}
+			v.fragment(p, trak, moof, trafs[id], spans)
+		}
+	}
+}
+
+// fragment checks the samples of a track in a movie fragment, trafs being the indexes of its track fragments
+func (v *validator) fragment(p string, trak *mp4.TrakBox, moof *mp4.MoofBox, trafs []int, spans []mp4.MdatSpan) {
+	t, err := mp4.NewTrack(trak)
+	if err != nil {
+		return
+	}
+	n := t.SampleCount()
+	if err = t.AddFragments(v.moov.Mvex, moof); err != nil {
+		return
+	}
+	// the samples of the sample tables are skipped
+	it := t.Samples()
+	for i := uint32(0); i < n && it.Next(); i++ {
+	}
+	id := t.ID()
+	for _, j := range trafs {
+		traf, tp := moof.Traf[j], path(p, "traf", j)
+		count := 0
+		for _, trun := range traf.Trun {
+			count += len(trun.Samples)
+		}
+		outside := 0
+		var first mp4.SampleInfo
+		var duration uint64
+		for k := 0; k < count && it.Next(); k++ {
+			s := it.Sample()
+			if k == 0 {
+				first = s
+			}
+			duration += uint64(s.Duration)
+			if !inside(spans, s) {
+				outside++
+			}
+		}
+		if it.Err() != nil {
+			// the errors of the iterator are the ones of the sample tables
+			return
+		}
+		if outside > 0 {
+			v.add(RuleTrunDataOffset, Error, tp, "%d samples of track %d outside of the mdat boxes", outside, id)
+		}
+
+		// decode times
+		start, known := v.next[id]
+		if traf.Tfdt != nil {
+			if known && traf.Tfdt.BaseMediaDecodeTime != start {
+				v.add(RuleDecodeTime, Error, tp+"/tfdt", "decode time %d, the previous fragment of track %d ending at %d",
+					traf.Tfdt.BaseMediaDecodeTime, id, start)
+			}
+			start = traf.Tfdt.BaseMediaDecodeTime
+		} else if !known {
+			if count == 0 {
+				continue
+			}
+			// following the samples of the sample tables
+			start = first.DecodeTime
+		}
+		v.next[id] = start + duration
+	}
+}
+
+// track returns the trak box of a track, nil if not found
+func (v *validator) track(id uint32) *mp4.TrakBox {
+	for _, trak := range v.moov.Trak {
+		if trak.Tkhd != nil && trak.Tkhd.TrackId == id {
+			return trak
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package validate
+
+import (
+	"github.com/otherplace/mp4"
+)
+
+// movie validates the tracks of the moov box
+func (v *validator) movie() {
+	moov := v.moov
+	if moov.Mvhd == nil {
+		v.add(RuleMissingBox, Error, "moov", "no mvhd box")
+		return
+	}
+	fragmented := moov.Mvex != nil
+	var maxID uint32
+	var maxDuration uint64
+	for i, trak := range moov.Trak {
+		p := trakPath(i)
+		if trak.Tkhd == nil {
+			v.add(RuleMissingBox, Error, p, "no tkhd box")
+			continue
+		}
+		if trak.Tkhd.TrackId > maxID {
+			maxID = trak.Tkhd.TrackId
+		}
+		if trak.Tkhd.Duration > maxDuration {
+			maxDuration = trak.Tkhd.Duration
+		}
+		if !v.sampleTables(p, trak) {
+			continue
+		}
+		v.durations(p, trak)
+		if fragmented && moov.Mvex.TrackExtends(trak.Tkhd.TrackId) == nil {
+			v.add(RuleTrackExtends, Error, path("moov", "mvex", 0), "no trex box for track %d", trak.Tkhd.TrackId)
+		}
+	}
+	next := moov.Mvhd.NextTrackId
+	if next == 0 || (next != 0xffffffff && next <= maxID) {
+		v.add(RuleNextTrackID, Error, "moov/mvhd", "next track ID %d, the largest track ID being %d", next, maxID)
+	}
+	if moov.Mvhd.Duration != maxDuration && !(fragmented && moov.Mvhd.Duration == 0) {
+		v.add(RuleDuration, Warning, "moov/mvhd", "duration %d, the longest track lasting %d", moov.Mvhd.Duration, maxDuration)
+	}
+}
+
+// sampleTables validates the sample tables of a track, and returns false if some are missing
+func (v *validator) sampleTables(p string, trak *mp4.TrakBox) bool {
+	switch {
+	case trak.Mdia == nil:
+		v.add(RuleMissingBox, Error, p, "no mdia box")
+		return false
+	case trak.Mdia.Mdhd == nil:
+		v.add(RuleMissingBox, Error, p+"/mdia", "no mdhd box")
+		return false
+	case trak.Mdia.Minf == nil:
+		v.add(RuleMissingBox, Error, p+"/mdia", "no minf box")
+		return false
+	case trak.Mdia.Minf.Stbl == nil:
+		v.add(RuleMissingBox, Error, p+"/mdia/minf", "no stbl box")
+		return false
+	}
+	p += "/mdia/minf/stbl"
+	stbl := trak.Mdia.Minf.Stbl
+	missing := false
+	for _, b := range []struct {
+		name    string
+		missing bool
+	}{
+		{"stsd", stbl.Stsd == nil},
+		{"stts", stbl.Stts == nil},
+		{"stsc", stbl.Stsc == nil},
+		{"stsz", stbl.Stsz == nil},
+		{"stco or co64", stbl.Stco == nil && stbl.Co64 == nil},
+	} {
+		if b.missing {
+			v.add(RuleMissingBox, Error, p, "no %s box", b.name)
+			missing = true
+		}
+	}
+	if missing {
+		return false
+	}
+
+	count := stbl.Stsz.SampleNumber
+	var total uint64
+	for _, n := range stbl.Stts.SampleCount {
+		total += uint64(n)
+	}
+	if total != uint64(count) {
+		v.add(RuleSampleCount, Error, p+"/stts", "%d samples, stsz describing %d samples", total, count)
+	}
+
+	chunks := chunkCount(stbl)
+	stsc := stbl.Stsc
+	total = 0
+	valid := true
+	for i, first := range stsc.FirstChunk {
+		last := chunks
+		if i+1 < len(stsc.FirstChunk) {
+			last = stsc.FirstChunk[i+1] - 1
+		}
+		if (i == 0 && first != 1) || first == 0 || first > chunks || last < first {
+			v.add(RuleSampleToChunk, Error, p+"/stsc", "entry %d starts at chunk %d, the track having %d chunks", i+1, first, chunks)
+			valid = false
+			break
+		}
+		total += uint64(last-first+1) * uint64(stsc.SamplesPerChunk[i])
+	}
+	if len(stsc.FirstChunk) == 0 && chunks > 0 {
+		v.add(RuleSampleToChunk, Error, p+"/stsc", "no entry, the track having %d chunks", chunks)
+	} else if valid && total != uint64(count) {
+		v.add(RuleSampleCount, Error, p+"/stsc", "%d samples in %d chunks, stsz describing %d samples", total, chunks, count)
+	}
+
+	if stbl.Stss != nil {
+		var prev uint32
+		for i, n := range stbl.Stss.SampleNumber {
+			if n <= prev || n > count {
+				v.add(RuleSyncSample, Error, p+"/stss", "entry %d is sample %d, the track having %d samples", i+1, n, count)
+				break
+			}
+			prev = n
+		}
+	}
+
+	if stbl.Ctts != nil {
+		total = 0
+		for _, n := range stbl.Ctts.SampleCount {
+			total += uint64(n)
+		}
+		if total != uint64(count) {
+			v.add(RuleCompositionOffset, Error, p+"/ctts", "%d samples, stsz describing %d samples", total, count)
+		}
+	}
+	return true
+}
+
+// durations checks the durations of a track against its samples
+func (v *validator) durations(p string, trak *mp4.TrakBox) {
+	mdhd := trak.Mdia.Mdhd
+	stts := trak.Mdia.Minf.Stbl.Stts
+	var total uint64
+	for i, n := range stts.SampleCount {
+		total += uint64(n) * uint64(stts.SampleTimeDelta[i])
+	}
+	fragmented := v.moov.Mvex != nil
+	if mdhd.Duration != total && !(fragmented && mdhd.Duration == 0) {
+		v.add(RuleDuration, Warning, p+"/mdia/mdhd", "duration %d, the samples lasting %d", mdhd.Duration, total)
+	}
+	if mdhd.Timescale == 0 {
+		v.add(RuleDuration, Error, p+"/mdia/mdhd", "timescale 0")
+		return
+	}
+	// in the timescale of the movie, the edit list giving the duration of the presentation
+	var expected uint64
+	if trak.Edts != nil && trak.Edts.Elst != nil {
+		for _, d := range trak.Edts.Elst.SegmentDuration {
+			expected += d
+		}
+	} else {
+		expected = mdhd.Duration * uint64(v.moov.Mvhd.Timescale) / uint64(mdhd.Timescale)
+	}
+	d := trak.Tkhd.Duration
+	if (d > expected+1 || d+1 < expected) && !(fragmented && d == 0) {
+		v.add(RuleDuration, Warning, p+"/tkhd", "duration %d, the media lasting %d in the movie timescale", d, expected)
+	}
+}
+
+func chunkCount(stbl *mp4.StblBox) uint32 {
+	if stbl.Co64 != nil {
+		return uint32(len(stbl.Co64.ChunkOffset))
+	}
+	return uint32(len(stbl.Stco.ChunkOffset))
+}
@@ -0,0 +1,186 @@
+// Package validate checks the consistency of the boxes of a media, beyond what is checked when decoding
+// them : sample tables, durations, data offsets and fragments.
+//
+//	m, err := mp4.DecodeWithOptions(f, mp4.DecodeOptions{Mode: mp4.DecodeLenient})
+//	report := validate.Media(m)
+//	if report.Count(validate.Error) > 0 {
+//		...
+//	}
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/otherplace/mp4"
+)
+
+// Severity of an issue
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText marshals the severity as its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Rules checked
+const (
+	RuleDecode            = "decode"            // a box cannot be decoded (lenient mode warnings)
+	RuleMissingBox        = "missing-box"       // a mandatory box is missing
+	RuleSampleCount       = "sample-count"      // stsz, stts and stsc describe the same samples
+	RuleSampleToChunk     = "sample-to-chunk"   // stsc entries are ordered and reference existing chunks
+	RuleChunkOffset       = "chunk-offset"      // the samples of the chunks are inside a mdat box
+	RuleSyncSample        = "sync-sample"       // stss numbers are ordered and in range
+	RuleCompositionOffset = "ctts-count"        // ctts describes all the samples
+	RuleDuration          = "duration"          // mvhd, tkhd and mdhd durations agree with the samples
+	RuleNextTrackID       = "next-track-id"     // mvhd next track ID is greater than the track IDs
+	RuleTrunDataOffset    = "trun-data-offset"  // the samples of the track runs are inside a mdat box
+	RuleDecodeTime        = "tfdt-continuity"   // each fragment starts where the previous one ended
+	RuleTrackExtends      = "track-extends"     // fragmented tracks have their trex box
+	RuleFragmentSequence  = "fragment-sequence" // mfhd sequence numbers increase
+)
+
+// Issue is a violation of a rule
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Segment  int      `json:"segment,omitempty"` // starting at 1 for the media segments (see Segments)
+	Path     string   `json:"path"`              // of the box, e.g. moov/trak[1]/mdia/minf/stbl/stsz
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	path := i.Path
+	if i.Segment > 0 {
+		path = strings.TrimSuffix(fmt.Sprintf("segment[%d]/%s", i.Segment, path), "/")
+	}
+	if path == "" {
+		return fmt.Sprintf("%s [%s] %s", i.Severity, i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s [%s] %s: %s", i.Severity, i.Rule, path, i.Message)
+}
+
+// Report lists the issues found in a media
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+// Count returns the number of issues of a severity
+func (r *Report) Count(s Severity) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, i := range r.Issues {
+		b.WriteString(i.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Media validates a media : progressive, fragmented, or an init segment
+func Media(m *mp4.MP4) *Report {
+	v := newValidator()
+	v.media(m)
+	return v.report
+}
+
+// Segments validates an init segment and its media segments, the decode times of the fragments being
+// checked across the segments
+func Segments(init *mp4.MP4, segments ...*mp4.MP4) *Report {
+	v := newValidator()
+	v.media(init)
+	for i, s := range segments {
+		v.segment = i + 1
+		v.media(s)
+	}
+	return v.report
+}
+
+// validator holds the state of a validation
+type validator struct {
+	report   *Report
+	moov     *mp4.MoovBox
+	segment  int
+	sequence uint32            // of the last fragment
+	next     map[uint32]uint64 // decode time of the next fragment, by track
+}
+
+func newValidator() *validator {
+	return &validator{
+		report: &Report{Issues: []Issue{}},
+		next:   map[uint32]uint64{},
+	}
+}
+
+func (v *validator) add(rule string, s Severity, path, format string, a ...interface{}) {
+	v.report.Issues = append(v.report.Issues, Issue{
+		Rule:     rule,
+		Severity: s,
+		Segment:  v.segment,
+		Path:     path,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (v *validator) media(m *mp4.MP4) {
+	for _, w := range m.Warnings {
+		v.add(RuleDecode, Error, w.Path, "%v", w.Err)
+	}
+	if m.Moov != nil {
+		v.moov = m.Moov
+		v.movie()
+	} else if v.segment == 0 && len(m.Moof) == 0 {
+		v.add(RuleMissingBox, Error, "", "no moov box")
+	}
+	spans := m.MdatSpans()
+	if m.Moov != nil {
+		for i, trak := range m.Moov.Trak {
+			v.chunks(trakPath(i), trak, spans)
+		}
+	}
+	if len(m.Moof) > 0 {
+		if v.moov == nil {
+			v.add(RuleMissingBox, Error, "", "no moov box for the fragments")
+			return
+		}
+		v.fragments(m.Moof, spans)
+	}
+}
+
+// path returns the path of the box n (starting at 0) of type boxType, contained in the box at path
+func path(parent, boxType string, n int) string {
+	if parent != "" {
+		parent += "/"
+	}
+	if n > 0 {
+		return fmt.Sprintf("%s%s[%d]", parent, boxType, n)
+	}
+	return parent + boxType
+}
+
+func trakPath(i int) string {
+	return path("moov", "trak", i)
+}
@@ -0,0 +1,178 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/otherplace/mp4"
+)
+
+// readFile decodes a media of the sample directory
+func readFile(t *testing.T, name string) *mp4.MP4 {
+	t.Helper()
+	data, err := os.ReadFile("../sample/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mp4.DecodeWithOptions(bytes.NewReader(data), mp4.DecodeOptions{Mode: mp4.DecodeStrict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// stbl returns the sample tables of the track i (starting at 0)
+func stbl(m *mp4.MP4, i int) *mp4.StblBox {
+	return m.Moov.Trak[i].Mdia.Minf.Stbl
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []string // init segment (or single file) and media segments
+		update func(l []*mp4.MP4)
+		want   []string // rule and path of the issues
+	}{
+		{
+			"progressive", []string{"meta.test1.mp4"}, nil,
+			// the duration of the audio track includes its last edit
+			[]string{"duration moov/trak[1]/tkhd"},
+		},
+		{"dash init segment", []string{"dash-init-stream0.m4s"}, nil, nil},
+		{"dash segments", []string{"dash-init-stream3.m4s", "dash-chunk-stream3-00001.m4s"}, nil, nil},
+		{
+			"hls segments", []string{"hls-init.mp4", "hls_sequence_00000.m4s"}, nil,
+			// the next track ID of the sample is the ID of its last track
+			[]string{"next-track-id moov/mvhd"},
+		},
+		{
+			"missing box", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) { stbl(l[0], 0).Stsz = nil },
+			[]string{"missing-box moov/trak/mdia/minf/stbl", "duration moov/trak[1]/tkhd"},
+		},
+		{
+			"sample count", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) { stbl(l[0], 1).Stts.SampleCount[0]++ },
+			[]string{"sample-count moov/trak[1]/mdia/minf/stbl/stts", "duration moov/trak[1]/mdia/mdhd",
+				"duration moov/trak[1]/tkhd"},
+		},
+		{
+			"sample to chunk", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) {
+				stsc := stbl(l[0], 1).Stsc
+				stsc.FirstChunk[len(stsc.FirstChunk)-1] = 1000
+			},
+			[]string{"sample-to-chunk moov/trak[1]/mdia/minf/stbl/stsc", "duration moov/trak[1]/tkhd"},
+		},
+		{
+			"chunk offset", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) { stbl(l[0], 0).Stco.ChunkOffset[0] += 1 << 24 },
+			[]string{"duration moov/trak[1]/tkhd", "chunk-offset moov/trak/mdia/minf/stbl"},
+		},
+		{
+			"sync sample", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) { stbl(l[0], 0).Stss.SampleNumber = []uint32{1, 100} },
+			[]string{"sync-sample moov/trak/mdia/minf/stbl/stss", "duration moov/trak[1]/tkhd"},
+		},
+		{
+			"composition offsets", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) {
+				stbl(l[0], 0).Ctts = &mp4.CttsBox{SampleCount: []uint32{10}, SampleOffset: []int32{0}, EntryCount: 1}
+			},
+			[]string{"ctts-count moov/trak/mdia/minf/stbl/ctts", "duration moov/trak[1]/tkhd"},
+		},
+		{
+			"duration", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) { l[0].Moov.Mvhd.Duration++ },
+			[]string{"duration moov/trak[1]/tkhd", "duration moov/mvhd"},
+		},
+		{
+			"next track ID", []string{"meta.test1.mp4"},
+			func(l []*mp4.MP4) { l[0].Moov.Mvhd.NextTrackId = 2 },
+			[]string{"duration moov/trak[1]/tkhd", "next-track-id moov/mvhd"},
+		},
+		{
+			"track extends", []string{"dash-init-stream0.m4s"},
+			func(l []*mp4.MP4) { l[0].Moov.Mvex.Trex = nil },
+			[]string{"track-extends moov/mvex"},
+		},
+		{
+			"trun data offset", []string{"dash-init-stream3.m4s", "dash-chunk-stream3-00001.m4s"},
+			func(l []*mp4.MP4) { l[1].Moof[0].Traf[0].Trun[0].DataOffset += 1 << 24 },
+			[]string{"trun-data-offset segment[1]/moof/traf"},
+		},
+		{
+			// the same segment twice
+			"decode time", []string{"dash-init-stream3.m4s", "dash-chunk-stream3-00001.m4s", "dash-chunk-stream3-00001.m4s"},
+			nil,
+			[]string{"fragment-sequence segment[2]/moof/mfhd", "tfdt-continuity segment[2]/moof/traf/tfdt"},
+		},
+		{
+			// the track fragment repeated in the moof box
+			"decode time of the track fragments", []string{"dash-init-stream3.m4s", "dash-chunk-stream3-00001.m4s"},
+			func(l []*mp4.MP4) { l[1].Moof[0].Traf = append(l[1].Moof[0].Traf, l[1].Moof[0].Traf[0]) },
+			[]string{"tfdt-continuity segment[1]/moof/traf[1]/tfdt"},
+		},
+		{
+			"fragment sequence", []string{"dash-init-stream3.m4s", "dash-chunk-stream3-00001.m4s"},
+			func(l []*mp4.MP4) { l[1].Moof[0].Mfhd.SequenceNumber = 0 },
+			[]string{"fragment-sequence segment[1]/moof/mfhd"},
+		},
+	}
+	for _, tt := range tests {
+		l := []*mp4.MP4{}
+		for _, name := range tt.files {
+			l = append(l, readFile(t, name))
+		}
+		if tt.update != nil {
+			tt.update(l)
+		}
+		got := []string{}
+		for _, i := range Segments(l[0], l[1:]...).Issues {
+			p := i.Path
+			if i.Segment > 0 {
+				p = fmt.Sprintf("segment[%d]/%s", i.Segment, p)
+			}
+			got = append(got, i.Rule+" "+p)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: issues %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// the boxes skipped by the lenient mode
+	data, err := os.ReadFile("../sample/meta.test1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(data, []byte("stsz")) - 4
+	data[i+16], data[i+17], data[i+18], data[i+19] = 0x7f, 0xff, 0xff, 0xff
+	m, err := mp4.DecodeWithOptions(bytes.NewReader(data), mp4.DecodeOptions{Mode: mp4.DecodeLenient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Media(m)
+	if len(r.Issues) == 0 || r.Issues[0].Rule != RuleDecode || r.Issues[0].Path != "moov/trak/mdia/minf/stbl/stsz" {
+		t.Errorf("lenient decoding: issues %v", r.Issues)
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	tests := []struct {
+		issue Issue
+		want  string
+	}{
+		{Issue{Rule: RuleDuration, Severity: Error, Path: "moov/mvhd", Message: "m"}, "error [duration] moov/mvhd: m"},
+		{Issue{Rule: RuleDecode, Severity: Error, Message: "m"}, "error [decode] m"},
+		{Issue{Rule: RuleDecodeTime, Severity: Error, Segment: 2, Path: "moof", Message: "m"}, "error [tfdt-continuity] segment[2]/moof: m"},
+		{Issue{Rule: RuleDecode, Severity: Error, Segment: 2, Message: "m"}, "error [decode] segment[2]: m"},
+	}
+	for _, tt := range tests {
+		if got := tt.issue.String(); got != tt.want {
+			t.Errorf("%q, want %q", got, tt.want)
+		}
+	}
+}
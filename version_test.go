@@ -0,0 +1,82 @@
+package mp4
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// reencode encodes a box, and decodes it back
+func reencode(t *testing.T, b Box) Box {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := b.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != b.Size() {
+		t.Fatalf("%s: encoded %d bytes, size %d", b.Type(), buf.Len(), b.Size())
+	}
+	return decodeBox(t, buf.Bytes())
+}
+
+func TestVersionPromotion(t *testing.T) {
+	const large = math.MaxUint32 + 1
+	tests := []struct {
+		box  Box
+		want Box
+	}{
+		{
+			&TfdtBox{BaseMediaDecodeTime: large},
+			&TfdtBox{Version: 1, BaseMediaDecodeTime: large},
+		},
+		{
+			&MdhdBox{Timescale: 1000, Duration: large},
+			&MdhdBox{Version: 1, Timescale: 1000, Duration: large},
+		},
+		{
+			&MehdBox{FragmentDuration: large},
+			&MehdBox{Version: 1, FragmentDuration: large},
+		},
+		{
+			&SidxBox{Timescale: 1000, FirstOffset: large, References: []Reference{}},
+			&SidxBox{Version: 1, Timescale: 1000, FirstOffset: large, References: []Reference{}},
+		},
+		{
+			&ElstBox{SegmentDuration: []uint64{10}, MediaTime: []int64{large}, MediaRateInteger: []uint16{1}, MediaRateFraction: []uint16{0}},
+			&ElstBox{Version: 1, SegmentDuration: []uint64{10}, MediaTime: []int64{large}, MediaRateInteger: []uint16{1}, MediaRateFraction: []uint16{0}},
+		},
+		{
+			&ElstBox{SegmentDuration: []uint64{10}, MediaTime: []int64{-1}, MediaRateInteger: []uint16{1}, MediaRateFraction: []uint16{0}},
+			&ElstBox{SegmentDuration: []uint64{10}, MediaTime: []int64{-1}, MediaRateInteger: []uint16{1}, MediaRateFraction: []uint16{0}},
+		},
+	}
+	for _, tt := range tests {
+		got := reencode(t, tt.box)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.box.Type(), got, tt.want)
+		}
+	}
+}
+
+func TestTfraVersionPromotion(t *testing.T) {
+	b := &TfraBox{
+		Flags:   []byte{0, 0, 0},
+		TrackId: 1,
+		Entries: []*TfraEntry{
+			{Time: 0, MoofOffset: 100, TrafNumber: 1, TrunNumber: 1, SampleNumber: 1},
+			{Time: 1000, MoofOffset: math.MaxUint32 + 100, TrafNumber: 1, TrunNumber: 1, SampleNumber: 1},
+		},
+	}
+	got := reencode(t, b).(*TfraBox)
+	if got.Version != 1 || got.Entries[1].MoofOffset != b.Entries[1].MoofOffset {
+		t.Errorf("got version %d, offset %d", got.Version, got.Entries[1].MoofOffset)
+	}
+}
+
+func TestElstEncodeLengths(t *testing.T) {
+	b := &ElstBox{SegmentDuration: []uint64{10, 20}, MediaTime: []int64{0}, MediaRateInteger: []uint16{1}, MediaRateFraction: []uint16{0}}
+	if err := b.Encode(&bytes.Buffer{}); err != ErrBadFormat {
+		t.Errorf("entries of different lengths: %v", err)
+	}
+}
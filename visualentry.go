@@ -0,0 +1,198 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Visual Sample Entry (avc1, avc3, hvc1, hev1, av01, vp09, ... - mandatory for video tracks)
+//
+// Contained in : Sample Description Box (stsd)
+//
+// Status: decoded
+//
+// Describes the format of the samples of a video track : codec (Format), dimensions in pixels, ...
+// The codec configuration is stored in the boxes following the entry fields (avcC for H.264, hvcC for HEVC,
+// av1C for AV1, vpcC for VP8/VP9).
+//
+// HorizResolution and VertResolution are fixed point numbers (16 bits + 16 bits), 72 dpi by default.
+type VisualSampleEntry struct {
+	Format             string
+	DataReferenceIndex uint16
+	Width, Height      uint16
+	HorizResolution    Fixed32
+	VertResolution     Fixed32
+	FrameCount         uint16
+	CompressorName     string
+	Depth              uint16
+	AvcC               *AvcCBox `json:"avcC,omitempty"`
+	HvcC               *HvcCBox `json:"hvcC,omitempty"`
+	Av1C               *Av1CBox `json:"av1C,omitempty"`
+	VpcC               *VpcCBox `json:"vpcC,omitempty"`
+	Boxes              []Box    `json:",omitempty"`
+	fields             []byte   // as read from the media, with the reserved and pre-defined values
+	trailing           []byte
+	order              []string
+}
+
+func DecodeVisualSampleEntry(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 78 {
+		return nil, ErrTruncatedBody
+	}
+	b := &VisualSampleEntry{
+		Format:             h.Type,
+		DataReferenceIndex: decodeSampleEntryHeader(data),
+		Width:              binary.BigEndian.Uint16(data[24:26]),
+		Height:             binary.BigEndian.Uint16(data[26:28]),
+		HorizResolution:    fixed32(data[28:32]),
+		VertResolution:     fixed32(data[32:36]),
+		FrameCount:         binary.BigEndian.Uint16(data[40:42]),
+		Depth:              binary.BigEndian.Uint16(data[74:76]),
+		CompressorName:     compressorName(data),
+		fields:             data[:78],
+	}
+	l, trailing, err := decodeSampleEntryBoxes(r, data[78:])
+	if err != nil {
+		return nil, err
+	}
+	b.trailing = trailing
+	b.order = boxTypes(l)
+	for _, c := range l {
+		if _, ok := c.(*RawBox); ok {
+			b.Boxes = append(b.Boxes, c)
+			continue
+		}
+		switch c.Type() {
+		case "avcC":
+			b.AvcC = c.(*AvcCBox)
+		case "hvcC":
+			b.HvcC = c.(*HvcCBox)
+		case "av1C":
+			b.Av1C = c.(*Av1CBox)
+		case "vpcC":
+			b.VpcC = c.(*VpcCBox)
+		default:
+			b.Boxes = append(b.Boxes, c.Box())
+		}
+	}
+	return b, nil
+}
+
+// compressorName returns the compressor name of the fields of a visual sample entry, "" if its length is
+// invalid
+func compressorName(fields []byte) string {
+	if l := int(fields[42]); l < 32 {
+		return string(fields[43 : 43+l])
+	}
+	return ""
+}
+
+func (b *VisualSampleEntry) Box() Box {
+	return b
+}
+
+func (b *VisualSampleEntry) Type() string {
+	return b.Format
+}
+
+// boxes returns the child boxes, codec configuration first
+func (b *VisualSampleEntry) boxes() []Box {
+	l := []Box{}
+	if b.AvcC != nil {
+		l = append(l, b.AvcC)
+	}
+	if b.HvcC != nil {
+		l = append(l, b.HvcC)
+	}
+	if b.Av1C != nil {
+		l = append(l, b.Av1C)
+	}
+	if b.VpcC != nil {
+		l = append(l, b.VpcC)
+	}
+	return orderedBoxes(b.order, append(l, b.Boxes...))
+}
+
+func (b *VisualSampleEntry) Size() int {
+	return BoxHeaderSize + 78 + sampleEntryBoxesSize(b.boxes(), b.trailing)
+}
+
+// Codec returns the RFC 6381 codec string (e.g. avc1.64001f), or the sample entry format if the
+// codec configuration is unknown
+func (b *VisualSampleEntry) Codec() string {
+	switch {
+	case b.AvcC != nil:
+		return b.AvcC.Codec(b.Format)
+	case b.HvcC != nil:
+		return b.HvcC.Codec(b.Format)
+	case b.Av1C != nil:
+		return b.Av1C.Codec(b.Format)
+	case b.VpcC != nil:
+		return b.VpcC.Codec(b.Format)
+	}
+	return b.Format
+}
+
+func (b *VisualSampleEntry) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 78)
+	if len(b.fields) == len(buf) {
+		copy(buf, b.fields)
+	} else {
+		binary.BigEndian.PutUint16(buf[76:], 0xffff)
+	}
+	encodeSampleEntryHeader(buf, b.DataReferenceIndex)
+	binary.BigEndian.PutUint16(buf[24:], b.Width)
+	binary.BigEndian.PutUint16(buf[26:], b.Height)
+	putFixed32(buf[28:], b.HorizResolution)
+	putFixed32(buf[32:], b.VertResolution)
+	binary.BigEndian.PutUint16(buf[40:], b.FrameCount)
+	if len(b.fields) != len(buf) || b.CompressorName != compressorName(b.fields) {
+		name := b.CompressorName
+		if len(name) > 31 {
+			name = name[:31]
+		}
+		copy(buf[42:74], make([]byte, 32))
+		buf[42] = byte(len(name))
+		copy(buf[43:], name)
+	}
+	binary.BigEndian.PutUint16(buf[74:], b.Depth)
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+	return encodeSampleEntryBoxes(w, b.boxes(), b.trailing)
+}
+
+func (b *VisualSampleEntry) Dump() {
+	fmt.Printf("Visual Sample Entry\n")
+	fmt.Printf("+- Format: %s\n", b.Format)
+	fmt.Printf("+- Codec: %s\n", b.Codec())
+	fmt.Printf("+- WxH: %dx%d\n", b.Width, b.Height)
+	if b.CompressorName != "" {
+		fmt.Printf("+- Compressor: %s\n", b.CompressorName)
+	}
+	if b.AvcC != nil {
+		b.AvcC.Dump()
+	}
+	if b.HvcC != nil {
+		b.HvcC.Dump()
+	}
+	if b.Av1C != nil {
+		b.Av1C.Dump()
+	}
+	if b.VpcC != nil {
+		b.VpcC.Dump()
+	}
+	for _, c := range b.Boxes {
+		c.Dump()
+	}
+}
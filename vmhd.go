@@ -19,11 +19,13 @@ type VmhdBox struct {
 }
 
 func DecodeVmhd(h BoxHeader, r io.Reader) (Box, error) {
-	data := make([]byte, h.Size-BoxHeaderSize)
-	_, err := r.Read(data)
+	data, err := readBoxData(h, r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkFullBoxSize(data, 12, 12); err != nil {
+		return nil, err
+	}
 	b := &VmhdBox{
 		Version:      data[0],
 		Flags:        [3]byte{data[1], data[2], data[3]},
@@ -0,0 +1,123 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// VP Codec Configuration Box (vpcC - mandatory for VP8/VP9 video tracks)
+//
+// Contained in : Visual Sample Entry (vp08, vp09)
+//
+// Status: decoded (version 1), version 0 is not decoded
+//
+// Contains the VPCodecConfigurationRecord (VP Codec ISO Media File Format Binding) : profile, level,
+// bit depth, chroma subsampling and color description.
+type VpcCBox struct {
+	Version                 byte
+	Flags                   [3]byte
+	Profile                 byte
+	Level                   byte
+	BitDepth                byte
+	ChromaSubsampling       byte
+	FullRange               bool
+	ColourPrimaries         byte
+	TransferCharacteristics byte
+	MatrixCoefficients      byte
+	CodecInitializationData []byte `json:",omitempty"`
+	notDecoded              []byte
+}
+
+func DecodeVpcC(h BoxHeader, r io.Reader) (Box, error) {
+	data, err := readBoxData(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, ErrTruncatedBody
+	}
+	b := &VpcCBox{
+		Version: data[0],
+		Flags:   [3]byte{data[1], data[2], data[3]},
+	}
+	if b.Version != 1 {
+		b.notDecoded = data[4:]
+		return b, nil
+	}
+	if len(data) < 12 {
+		return nil, ErrTruncatedBody
+	}
+	b.Profile = data[4]
+	b.Level = data[5]
+	b.BitDepth = data[6] >> 4
+	b.ChromaSubsampling = (data[6] >> 1) & 0x07
+	b.FullRange = data[6]&0x01 != 0
+	b.ColourPrimaries = data[7]
+	b.TransferCharacteristics = data[8]
+	b.MatrixCoefficients = data[9]
+	sz := int(binary.BigEndian.Uint16(data[10:12]))
+	if 12+sz > len(data) {
+		return nil, ErrTruncatedBody
+	}
+	b.CodecInitializationData = data[12 : 12+sz]
+	b.notDecoded = data[12+sz:]
+	return b, nil
+}
+
+func (b *VpcCBox) Box() Box {
+	return b
+}
+
+func (b *VpcCBox) Type() string {
+	return "vpcC"
+}
+
+func (b *VpcCBox) Size() int {
+	if b.Version != 1 {
+		return BoxHeaderSize + 4 + len(b.notDecoded)
+	}
+	return BoxHeaderSize + 12 + len(b.CodecInitializationData) + len(b.notDecoded)
+}
+
+// Codec returns the RFC 6381 codec string (e.g. vp09.00.10.08) for a sample entry format (vp08 or vp09)
+func (b *VpcCBox) Codec(format string) string {
+	return fmt.Sprintf("%s.%02d.%02d.%02d", format, b.Profile, b.Level, b.BitDepth)
+}
+
+func (b *VpcCBox) Encode(w io.Writer) error {
+	err := EncodeHeader(b, w)
+	if err != nil {
+		return err
+	}
+	buf := makebuf(b)
+	buf[0] = b.Version
+	buf[1], buf[2], buf[3] = b.Flags[0], b.Flags[1], b.Flags[2]
+	if b.Version != 1 {
+		copy(buf[4:], b.notDecoded)
+		_, err = w.Write(buf)
+		return err
+	}
+	buf[4] = b.Profile
+	buf[5] = b.Level
+	buf[6] = b.BitDepth<<4 | (b.ChromaSubsampling&0x07)<<1
+	if b.FullRange {
+		buf[6] |= 0x01
+	}
+	buf[7] = b.ColourPrimaries
+	buf[8] = b.TransferCharacteristics
+	buf[9] = b.MatrixCoefficients
+	binary.BigEndian.PutUint16(buf[10:], uint16(len(b.CodecInitializationData)))
+	off := 12 + copy(buf[12:], b.CodecInitializationData)
+	copy(buf[off:], b.notDecoded)
+	_, err = w.Write(buf)
+	return err
+}
+
+func (b *VpcCBox) Dump() {
+	fmt.Printf("VP Codec Configuration Box\n")
+	fmt.Printf("+- Profile: %d\n", b.Profile)
+	fmt.Printf("+- Level: %d\n", b.Level)
+	fmt.Printf("+- Bit depth: %d\n", b.BitDepth)
+	fmt.Printf("+- Chroma subsampling: %d\n", b.ChromaSubsampling)
+}